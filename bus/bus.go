@@ -43,6 +43,10 @@ func NewPatchKey(chainId uint64) String {
 	return String(fmt.Sprintf("patch:%d", chainId))
 }
 
+func NewRetryKey(chainId uint64, txType msg.TxType) String {
+	return String(fmt.Sprintf("retry:%d:%v", chainId, txType))
+}
+
 type TxQueueKey struct {
 	ChainId uint64
 	TxType  msg.TxType
@@ -94,6 +98,11 @@ func NewRedisPatchTxBus(db *redis.Client, chainId uint64) *RedisTxBus {
 	return &RedisTxBus{NewPatchKey(chainId), db}
 }
 
+// Bus holding failed txs to retry apart from the main queue
+func NewRedisRetryTxBus(db *redis.Client, chainId uint64, txType msg.TxType) *RedisTxBus {
+	return &RedisTxBus{NewRetryKey(chainId, txType), db}
+}
+
 func (b *RedisTxBus) Topic() (topic string) {
 	return b.Key.Key()
 }
@@ -102,8 +111,13 @@ func (b *RedisTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
 	return b.PopTimed(ctx, 0)
 }
 
+// Pop waiting at most duration, a nil tx is returned on timeout. Zero duration blocks until a tx arrives.
 func (b *RedisTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
 	res, err := b.db.BLPop(ctx, duration, b.Key.Key()).Result()
+	if err == redis.Nil {
+		// Timed out with the queue empty
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Failed to pop message %v", err)
 	}
@@ -112,11 +126,12 @@ func (b *RedisTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg
 		return nil, nil
 	}
 	tx := new(msg.Tx)
-	err = tx.Decode(res[1])
+	err = tx.Unmarshal([]byte(res[1]))
 	return tx, err
 }
 
 func (b *RedisTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	tx.MarkEnqueued()
 	_, err := b.db.RPush(ctx, GetQueue(tx).Key(), tx.Encode()).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
@@ -137,6 +152,7 @@ func (b *RedisTxBus) Patch(ctx context.Context, tx *msg.Tx) (err error) {
 }
 
 func (b *RedisTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	tx.MarkEnqueued()
 	_, err := b.db.RPush(ctx, b.Key.Key(), tx.Encode()).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
@@ -145,6 +161,7 @@ func (b *RedisTxBus) Push(ctx context.Context, tx *msg.Tx) error {
 }
 
 func (b *RedisTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
+	tx.MarkEnqueued()
 	_, err := b.db.LPush(ctx, GetQueue(tx).Key(), tx.Encode()).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
@@ -78,6 +78,6 @@ func (b *RedisDelayedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score int64, e
 	}
 	score = int64(res.Score)
 	tx = new(msg.Tx)
-	err = tx.Decode(res.Member.(string))
+	err = tx.Unmarshal([]byte(res.Member.(string)))
 	return
 }
@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type kafkaReader interface {
+	FetchMessage(context.Context) (kafka.Message, error)
+	CommitMessages(context.Context, ...kafka.Message) error
+	Stats() kafka.ReaderStats
+	Close() error
+}
+
+type kafkaWriter interface {
+	WriteMessages(context.Context, ...kafka.Message) error
+	Close() error
+}
+
+// Kafka topic of the bus key, colons are not allowed in topic names
+func KafkaTopic(key Key) string {
+	return strings.Replace(key.Key(), ":", ".", -1)
+}
+
+// Tx bus over a kafka topic consumed by a consumer group.
+//
+// Delivery is at least once: the message returned by Pop is committed on the next Pop, by which
+// time the consumer has submitted the tx or pushed it back, so a crash in between redelivers it.
+// As a consequence a KafkaTxBus serves a single consumer, workers should each create their own
+// bus with the same group id to share the topic partitions.
+//
+// Ordering is kept only within a partition. Txs are keyed by the src hash, so pushes of the same
+// tx land in the same partition, while txs on different partitions are consumed in any order.
+// Kafka topics can not be prepended to, PushBack appends the tx to the tail like Push.
+// Tx attempts are carried in the encoded message, so retries keep counting across pushes.
+type KafkaTxBus struct {
+	Key
+	sync.Mutex
+	reader  kafkaReader
+	writer  kafkaWriter
+	pending *kafka.Message // Message popped last and not committed yet
+}
+
+func NewKafkaTxBus(config *config.KafkaConfig, chainId uint64, txType msg.TxType) *KafkaTxBus {
+	key := &TxQueueKey{ChainId: chainId, TxType: txType}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: config.Brokers,
+		GroupID: config.GroupId,
+		Topic:   KafkaTopic(key),
+	})
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(config.Brokers...),
+		Balancer: &kafka.Hash{},
+	}
+	return &KafkaTxBus{Key: key, reader: reader, writer: writer}
+}
+
+func (b *KafkaTxBus) Topic() string {
+	return KafkaTopic(b.Key)
+}
+
+func (b *KafkaTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
+	return b.PopTimed(ctx, 0)
+}
+
+// Pop the next tx waiting at most duration, a nil tx is returned on timeout. Zero duration waits until ctx is done.
+func (b *KafkaTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.pending != nil {
+		err := b.reader.CommitMessages(ctx, *b.pending)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to commit message %v", err)
+		}
+		b.pending = nil
+	}
+
+	fetchCtx := ctx
+	if duration > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	m, err := b.reader.FetchMessage(fetchCtx)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Failed to pop message %v", err)
+	}
+	b.pending = &m
+	tx := new(msg.Tx)
+	err = tx.Unmarshal(m.Value)
+	return tx, err
+}
+
+func (b *KafkaTxBus) write(ctx context.Context, key Key, tx *msg.Tx) error {
+	tx.MarkEnqueued()
+	err := b.writer.WriteMessages(ctx, kafka.Message{
+		Topic: KafkaTopic(key),
+		Key:   []byte(tx.SrcHash),
+		Value: []byte(tx.Encode()),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to push message %v", err)
+	}
+	return nil
+}
+
+func (b *KafkaTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	return b.write(ctx, b.Key, tx)
+}
+
+func (b *KafkaTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	return b.write(ctx, GetQueue(tx), tx)
+}
+
+func (b *KafkaTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
+	return b.write(ctx, GetQueue(tx), tx)
+}
+
+func (b *KafkaTxBus) Patch(ctx context.Context, tx *msg.Tx) error {
+	chain := tx.SrcChainId
+	if tx.Type() == msg.POLY {
+		chain = base.POLY
+	}
+	return b.write(ctx, NewPatchKey(chain), tx)
+}
+
+// Consumer lag of the partitions assigned to this bus
+func (b *KafkaTxBus) Len(ctx context.Context) (uint64, error) {
+	lag := b.reader.Stats().Lag
+	if lag < 0 {
+		return 0, nil
+	}
+	return uint64(lag), nil
+}
+
+func (b *KafkaTxBus) LenOf(ctx context.Context, chain uint64, ty msg.TxType) (uint64, error) {
+	return 0, fmt.Errorf("Kafka tx bus does not track the length of topic %s", KafkaTopic(&TxQueueKey{chain, ty}))
+}
+
+// Close the reader and writer, the message popped last is left uncommitted to be redelivered
+func (b *KafkaTxBus) Close() error {
+	err := b.reader.Close()
+	if werr := b.writer.Close(); err == nil {
+		err = werr
+	}
+	return err
+}
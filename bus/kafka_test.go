@@ -0,0 +1,141 @@
+package bus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// In memory kafka broker with a single partition per topic and one consumer group
+type memKafka struct {
+	sync.Mutex
+	topics    map[string][]kafka.Message
+	committed map[string]int64
+}
+
+func newMemKafka() *memKafka {
+	return &memKafka{topics: map[string][]kafka.Message{}, committed: map[string]int64{}}
+}
+
+func (k *memKafka) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	k.Lock()
+	defer k.Unlock()
+	for _, m := range msgs {
+		m.Offset = int64(len(k.topics[m.Topic]))
+		k.topics[m.Topic] = append(k.topics[m.Topic], m)
+	}
+	return nil
+}
+
+func (k *memKafka) Close() error { return nil }
+
+type memKafkaReader struct {
+	*memKafka
+	topic string
+	next  int64
+}
+
+func (k *memKafka) reader(topic string) *memKafkaReader {
+	k.Lock()
+	defer k.Unlock()
+	return &memKafkaReader{k, topic, k.committed[topic]}
+}
+
+func (r *memKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.Lock()
+		if r.next < int64(len(r.topics[r.topic])) {
+			m := r.topics[r.topic][r.next]
+			r.next++
+			r.Unlock()
+			return m, nil
+		}
+		r.Unlock()
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (r *memKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.Lock()
+	defer r.Unlock()
+	for _, m := range msgs {
+		if m.Offset+1 > r.committed[m.Topic] {
+			r.committed[m.Topic] = m.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *memKafkaReader) Stats() kafka.ReaderStats {
+	r.Lock()
+	defer r.Unlock()
+	return kafka.ReaderStats{Lag: int64(len(r.topics[r.topic])) - r.next}
+}
+
+func TestKafkaTxBus(t *testing.T) {
+	ctx := context.Background()
+	k := newMemKafka()
+	key := &TxQueueKey{ChainId: 2, TxType: msg.SRC}
+	topic := KafkaTopic(key)
+	b := &KafkaTxBus{Key: key, reader: k.reader(topic), writer: k}
+
+	for _, hash := range []string{"a", "b", "c"} {
+		if err := b.Push(ctx, &msg.Tx{SrcHash: hash, SrcChainId: 2, DstChainId: 2, TxType: msg.SRC}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := b.Len(ctx); n != 3 {
+		t.Fatalf("Expect bus length 3, got %d", n)
+	}
+	tx, err := b.Pop(ctx)
+	if err != nil || tx.SrcHash != "a" {
+		t.Fatalf("Unexpected tx %+v %v", tx, err)
+	}
+	if k.committed[topic] != 0 {
+		t.Fatal("Expect popped tx uncommitted before the next pop")
+	}
+
+	// Failed tx pushed back keeps its attempts
+	tx.Attempts++
+	b.PushBack(ctx, tx)
+	tx, _ = b.Pop(ctx)
+	if tx.SrcHash != "b" || k.committed[topic] != 1 {
+		t.Fatalf("Expect tx b popped with tx a committed, got %s committed %d", tx.SrcHash, k.committed[topic])
+	}
+
+	// Consumer crashed with tx b in process, it is redelivered to the new consumer of the group
+	b = &KafkaTxBus{Key: key, reader: k.reader(topic), writer: k}
+	for _, expect := range []string{"b", "c", "a"} {
+		tx, err = b.Pop(ctx)
+		if err != nil || tx.SrcHash != expect {
+			t.Fatalf("Expect tx %s, got %+v %v", expect, tx, err)
+		}
+	}
+	if tx.Attempts != 1 {
+		t.Fatalf("Expect tx attempts kept, got %d", tx.Attempts)
+	}
+
+	tx, err = b.PopTimed(ctx, 10*time.Millisecond)
+	if tx != nil || err != nil {
+		t.Fatalf("Expect empty pop on timeout, got %+v %v", tx, err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = b.Pop(cancelled); err == nil {
+		t.Fatal("Expect pop error on cancelled context")
+	}
+
+	b.PushToChain(ctx, &msg.Tx{SrcHash: "d", DstChainId: 6, TxType: msg.SRC})
+	if len(k.topics[KafkaTopic(&TxQueueKey{ChainId: 6, TxType: msg.SRC})]) != 1 {
+		t.Fatal("Expect tx pushed to the dst chain topic")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Channel backed tx bus of a single queue, for tests and single process deployments.
+// Txs are popped in push order, PushBack appends to the tail as channels can not be prepended to.
+type MemoryTxBus struct {
+	Key
+	txs    chan *msg.Tx
+	block  bool // Whether Push waits for room when the bus is full
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Create a memory tx bus holding at most capacity txs, Push blocks on a full bus if block is set,
+// otherwise it fails with msg.ERR_BUS_FULL
+func NewMemoryTxBus(chainId uint64, txType msg.TxType, capacity int, block bool) *MemoryTxBus {
+	return &MemoryTxBus{
+		Key:    &TxQueueKey{ChainId: chainId, TxType: txType},
+		txs:    make(chan *msg.Tx, capacity),
+		block:  block,
+		closed: make(chan struct{}),
+	}
+}
+
+func (b *MemoryTxBus) Topic() string {
+	return b.Key.Key()
+}
+
+// Pop waits for a tx until ctx is done or the bus is closed, txs left in a closed bus are still popped
+func (b *MemoryTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
+	return b.PopTimed(ctx, 0)
+}
+
+// Pop waiting at most duration, a nil tx is returned on timeout. Zero duration waits without timeout.
+func (b *MemoryTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
+	select {
+	case tx := <-b.txs:
+		return tx, nil
+	default:
+	}
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case tx := <-b.txs:
+		return tx, nil
+	case <-timeout:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.closed:
+		return nil, msg.ERR_BUS_CLOSED
+	}
+}
+
+func (b *MemoryTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	select {
+	case <-b.closed:
+		return msg.ERR_BUS_CLOSED
+	default:
+	}
+	tx.MarkEnqueued()
+	if !b.block {
+		select {
+		case b.txs <- tx:
+			return nil
+		default:
+			return fmt.Errorf("%w, capacity %d", msg.ERR_BUS_FULL, cap(b.txs))
+		}
+	}
+	select {
+	case b.txs <- tx:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.closed:
+		return msg.ERR_BUS_CLOSED
+	}
+}
+
+// Push to the queue of the tx, which has to be the queue of this bus
+func (b *MemoryTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	if key := GetQueue(tx).Key(); key != b.Key.Key() {
+		return fmt.Errorf("Memory tx bus %s can not push to queue %s", b.Key.Key(), key)
+	}
+	return b.Push(ctx, tx)
+}
+
+func (b *MemoryTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
+	return b.PushToChain(ctx, tx)
+}
+
+func (b *MemoryTxBus) Patch(ctx context.Context, tx *msg.Tx) error {
+	return fmt.Errorf("Memory tx bus %s has no patch queue", b.Key.Key())
+}
+
+func (b *MemoryTxBus) Len(context.Context) (uint64, error) {
+	return uint64(len(b.txs)), nil
+}
+
+func (b *MemoryTxBus) LenOf(ctx context.Context, chain uint64, ty msg.TxType) (uint64, error) {
+	if key := (&TxQueueKey{chain, ty}).Key(); key != b.Key.Key() {
+		return 0, fmt.Errorf("Memory tx bus %s does not hold queue %s", b.Key.Key(), key)
+	}
+	return b.Len(ctx)
+}
+
+// Close the bus, pushes fail afterwards and pops fail once the remaining txs are drained
+func (b *MemoryTxBus) Close() {
+	b.once.Do(func() { close(b.closed) })
+}
@@ -0,0 +1,116 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestMemoryTxBus(t *testing.T) {
+	ctx := context.Background()
+	var b TxBus = NewMemoryTxBus(2, msg.SRC, 2, false)
+	for _, hash := range []string{"a", "b"} {
+		if err := b.Push(ctx, &msg.Tx{SrcHash: hash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Push(ctx, &msg.Tx{SrcHash: "c"}); !errors.Is(err, msg.ERR_BUS_FULL) {
+		t.Fatalf("Expect bus full error, got %v", err)
+	}
+	if n, _ := b.Len(ctx); n != 2 {
+		t.Fatalf("Expect bus length 2, got %d", n)
+	}
+	for _, expect := range []string{"a", "b"} {
+		tx, err := b.Pop(ctx)
+		if err != nil || tx.SrcHash != expect {
+			t.Fatalf("Expect tx %s, got %+v %v", expect, tx, err)
+		}
+	}
+	if tx, err := b.PopTimed(ctx, 10*time.Millisecond); tx != nil || err != nil {
+		t.Fatalf("Expect empty pop on timeout, got %+v %v", tx, err)
+	}
+	if err := b.PushToChain(ctx, &msg.Tx{DstChainId: 6, TxType: msg.SRC}); err == nil {
+		t.Fatal("Expect push to other queue rejected")
+	}
+}
+
+func TestMemoryTxBusBlocking(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryTxBus(2, msg.SRC, 1, true)
+	b.Push(ctx, &msg.Tx{SrcHash: "a"})
+
+	pushed := make(chan error)
+	go func() { pushed <- b.Push(ctx, &msg.Tx{SrcHash: "b"}) }()
+	select {
+	case <-pushed:
+		t.Fatal("Expect push blocked on full bus")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if tx, _ := b.Pop(ctx); tx.SrcHash != "a" {
+		t.Fatalf("Unexpected tx %s", tx.SrcHash)
+	}
+	if err := <-pushed; err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := b.Push(cancelled, &msg.Tx{SrcHash: "c"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expect blocked push to end with context, got %v", err)
+	}
+}
+
+func TestMemoryTxBusClose(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryTxBus(2, msg.SRC, 2, true)
+	b.Push(ctx, &msg.Tx{SrcHash: "a"})
+
+	popped := make(chan error)
+	waiting := NewMemoryTxBus(2, msg.SRC, 1, true)
+	go func() {
+		_, err := waiting.Pop(ctx)
+		popped <- err
+	}()
+	waiting.Close()
+	if err := <-popped; !errors.Is(err, msg.ERR_BUS_CLOSED) {
+		t.Fatalf("Expect waiting pop to end on close, got %v", err)
+	}
+
+	b.Close()
+	if err := b.Push(ctx, &msg.Tx{SrcHash: "b"}); !errors.Is(err, msg.ERR_BUS_CLOSED) {
+		t.Fatalf("Expect push to closed bus rejected, got %v", err)
+	}
+	if tx, err := b.Pop(ctx); err != nil || tx.SrcHash != "a" {
+		t.Fatalf("Expect remaining tx drained after close, got %+v %v", tx, err)
+	}
+	if _, err := b.Pop(ctx); !errors.Is(err, msg.ERR_BUS_CLOSED) {
+		t.Fatalf("Expect closed bus error, got %v", err)
+	}
+}
+
+func TestMemoryTxBusEnqueuedAt(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryTxBus(2, msg.SRC, 2, false)
+	before := time.Now().Unix()
+	if err := b.Push(ctx, &msg.Tx{SrcHash: "a", DstChainId: 2, TxType: msg.SRC}); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ := b.Pop(ctx)
+	if tx.EnqueuedAt < before || tx.EnqueuedAt > time.Now().Unix() {
+		t.Fatalf("Expect enqueue time set on push, got %d", tx.EnqueuedAt)
+	}
+
+	// Pushing back for retry keeps the first enqueue time
+	tx.EnqueuedAt -= 60
+	enqueued := tx.EnqueuedAt
+	if err := b.PushBack(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = b.Pop(ctx)
+	if tx.EnqueuedAt != enqueued || tx.BacklogAge() < time.Minute {
+		t.Fatalf("Expect enqueue time kept on push back, got %d", tx.EnqueuedAt)
+	}
+}
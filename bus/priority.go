@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Tx bus holding a redis queue per priority level, drained from the highest level first and FIFO
+// within a level. Level 0 uses the plain tx queue key, so the bus reads the txs of a RedisTxBus too.
+type RedisPriorityTxBus struct {
+	*RedisTxBus
+	levels []int // Positive levels in descending order
+	rules  config.PriorityRules
+}
+
+func NewRedisPriorityTxBus(db *redis.Client, chainId uint64, txType msg.TxType, rules config.PriorityRules) *RedisPriorityTxBus {
+	return &RedisPriorityTxBus{
+		RedisTxBus: NewRedisTxBus(db, chainId, txType),
+		levels:     rules.Levels(),
+		rules:      rules,
+	}
+}
+
+// Highest configured level not above the priority
+func (b *RedisPriorityTxBus) level(priority int) int {
+	for _, level := range b.levels {
+		if level <= priority {
+			return level
+		}
+	}
+	return 0
+}
+
+func (b *RedisPriorityTxBus) levelKey(level int) string {
+	if level == 0 {
+		return b.Key.Key()
+	}
+	return fmt.Sprintf("%s:p%d", b.Key.Key(), level)
+}
+
+// Queue keys from the highest level
+func (b *RedisPriorityTxBus) keys() []string {
+	keys := make([]string, 0, len(b.levels)+1)
+	for _, level := range b.levels {
+		keys = append(keys, b.levelKey(level))
+	}
+	return append(keys, b.levelKey(0))
+}
+
+// Key of the level queue of the tx, assigning the tx priority by the rules if not set yet
+func (b *RedisPriorityTxBus) txKey(tx *msg.Tx) string {
+	if tx.Priority == 0 {
+		tx.Priority = b.rules.Priority(tx)
+	}
+	return b.levelKey(b.level(tx.Priority))
+}
+
+func (b *RedisPriorityTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
+	return b.PopTimed(ctx, 0)
+}
+
+func (b *RedisPriorityTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
+	res, err := b.db.BLPop(ctx, duration, b.keys()...).Result()
+	if err == redis.Nil {
+		// Timed out with the queue empty
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pop message %v", err)
+	}
+	if len(res) < 2 || res[1] == "" || res[1] == "nil" {
+		log.Info("Empty queue", "key", b.Key.Key())
+		return nil, nil
+	}
+	tx := new(msg.Tx)
+	err = tx.Unmarshal([]byte(res[1]))
+	return tx, err
+}
+
+func (b *RedisPriorityTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	tx.MarkEnqueued()
+	_, err := b.db.RPush(ctx, b.txKey(tx), tx.Encode()).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to push message %v", err)
+	}
+	return nil
+}
+
+func (b *RedisPriorityTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	if GetQueue(tx).Key() != b.Key.Key() {
+		return b.RedisTxBus.PushToChain(ctx, tx)
+	}
+	return b.Push(ctx, tx)
+}
+
+func (b *RedisPriorityTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
+	if GetQueue(tx).Key() != b.Key.Key() {
+		return b.RedisTxBus.PushBack(ctx, tx)
+	}
+	tx.MarkEnqueued()
+	_, err := b.db.LPush(ctx, b.txKey(tx), tx.Encode()).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to push message %v", err)
+	}
+	return nil
+}
+
+// Total length of the level queues
+func (b *RedisPriorityTxBus) Len(ctx context.Context) (uint64, error) {
+	var total uint64
+	for _, key := range b.keys() {
+		v, err := b.db.LLen(ctx, key).Result()
+		if err != nil {
+			return 0, fmt.Errorf("Get chain tx queue length error %v", err)
+		}
+		total += uint64(v)
+	}
+	return total, nil
+}
+
+func (b *RedisPriorityTxBus) LenOf(ctx context.Context, chain uint64, ty msg.TxType) (uint64, error) {
+	if (&TxQueueKey{chain, ty}).Key() == b.Key.Key() {
+		return b.Len(ctx)
+	}
+	return b.RedisTxBus.LenOf(ctx, chain, ty)
+}
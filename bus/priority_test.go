@@ -0,0 +1,60 @@
+package bus
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRedisPriorityTxBus(t *testing.T) {
+	server := miniredis.NewMiniRedis()
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	db := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	ctx := context.Background()
+
+	rules := config.PriorityRules{
+		{SrcChains: []uint64{6}, Priority: 5},
+		{MinAmount: big.NewInt(1000), Priority: 10},
+	}
+	b := NewRedisPriorityTxBus(db, 2, msg.SRC, rules)
+	txs := []*msg.Tx{
+		{SrcHash: "a", SrcChainId: 2},
+		{SrcHash: "b", SrcChainId: 6},
+		{SrcHash: "c", SrcChainId: 2, DstAmount: big.NewInt(5000)},
+		{SrcHash: "d", SrcChainId: 2},
+		{SrcHash: "e", SrcChainId: 6},
+		{SrcHash: "f", SrcChainId: 2, Priority: 7},
+	}
+	for _, tx := range txs {
+		if err := b.Push(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := b.Len(ctx); n != 6 {
+		t.Fatalf("Expect bus length 6, got %d", n)
+	}
+	for _, expect := range []string{"c", "b", "e", "f", "a", "d"} {
+		tx, err := b.Pop(ctx)
+		if err != nil || tx.SrcHash != expect {
+			t.Fatalf("Expect tx %s, got %+v %v", expect, tx, err)
+		}
+	}
+
+	// Txs pushed by a plain bus are drained at the lowest level
+	NewRedisTxBus(db, 2, msg.SRC).Push(ctx, &msg.Tx{SrcHash: "g"})
+	b.Push(ctx, &msg.Tx{SrcHash: "h", SrcChainId: 6})
+	for _, expect := range []string{"h", "g"} {
+		if tx, _ := b.Pop(ctx); tx == nil || tx.SrcHash != expect {
+			t.Fatalf("Expect tx %s, got %+v", expect, tx)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Short lived record of recently processed keys, used to drop duplicated txs
+type SeenSet interface {
+	Seen(context.Context, string) (bool, error)
+	Mark(context.Context, string) error
+}
+
+type MemorySeenSet struct {
+	sync.Mutex
+	ttl   time.Duration
+	keys  map[string]time.Time
+	sweep time.Time
+	now   func() time.Time
+}
+
+func NewMemorySeenSet(ttl time.Duration) *MemorySeenSet {
+	return &MemorySeenSet{ttl: ttl, keys: map[string]time.Time{}, now: time.Now}
+}
+
+func (s *MemorySeenSet) Seen(ctx context.Context, key string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	expiry, ok := s.keys[key]
+	if ok && s.now().After(expiry) {
+		delete(s.keys, key)
+		return false, nil
+	}
+	return ok, nil
+}
+
+func (s *MemorySeenSet) Mark(ctx context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+	now := s.now()
+	s.keys[key] = now.Add(s.ttl)
+	// Drop expired keys at most once per ttl
+	if now.Sub(s.sweep) > s.ttl {
+		s.sweep = now
+		for k, expiry := range s.keys {
+			if now.After(expiry) {
+				delete(s.keys, k)
+			}
+		}
+	}
+	return nil
+}
+
+type RedisSeenSet struct {
+	db  *redis.Client
+	ttl time.Duration
+}
+
+func NewRedisSeenSet(db *redis.Client, ttl time.Duration) *RedisSeenSet {
+	return &RedisSeenSet{db: db, ttl: ttl}
+}
+
+func (s *RedisSeenSet) Seen(ctx context.Context, key string) (bool, error) {
+	n, err := s.db.Exists(ctx, String("seen:"+key).Key()).Result()
+	if err != nil {
+		return false, fmt.Errorf("Failed to check seen key %v", err)
+	}
+	return n > 0, nil
+}
+
+func (s *RedisSeenSet) Mark(ctx context.Context, key string) error {
+	_, err := s.db.Set(ctx, String("seen:"+key).Key(), "true", s.ttl).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to mark seen key %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemorySeenSet(t *testing.T) {
+	now := time.Now()
+	s := NewMemorySeenSet(time.Minute)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if seen, _ := s.Seen(ctx, "a"); seen {
+		t.Fatalf("Unexpected seen key")
+	}
+	s.Mark(ctx, "a")
+	if seen, _ := s.Seen(ctx, "a"); !seen {
+		t.Fatalf("Expect key seen after mark")
+	}
+	now = now.Add(2 * time.Minute)
+	if seen, _ := s.Seen(ctx, "a"); seen {
+		t.Fatalf("Expect key expired after ttl")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/polynetwork/bridge-common/base"
@@ -37,6 +38,7 @@ type SortedTxBus interface {
 	Push(context.Context, *msg.Tx, uint64) error
 	Range(context.Context, uint64, int64) ([]*msg.Tx, error)
 	Pop(context.Context) (*msg.Tx, uint64, error)
+	PopTimed(context.Context, time.Duration) (*msg.Tx, uint64, error)
 	Len(context.Context) (uint64, error)
 	Topic() string
 }
@@ -66,13 +68,33 @@ func (b *RedisSortedTxBus) Len(ctx context.Context) (uint64, error) {
 	return uint64(v), nil
 }
 
+// Enqueue times of the queued txs by member, kept apart from the members so the same tx pushed again
+// at another time still collapses into one member of the sorted set
+func (b *RedisSortedTxBus) enqueuedKey() string {
+	return b.Key.Key() + ":enqueued"
+}
+
+// Encode the tx as a sorted set member, without the enqueue time
+func sortedMember(tx *msg.Tx) string {
+	enqueued := tx.EnqueuedAt
+	tx.EnqueuedAt = 0
+	member := tx.Encode()
+	tx.EnqueuedAt = enqueued
+	return member
+}
+
 func (b *RedisSortedTxBus) Push(ctx context.Context, msg *msg.Tx, height uint64) (err error) {
-	_, err = b.db.ZAdd(ctx, b.Key.Key(),
+	msg.MarkEnqueued()
+	member := sortedMember(msg)
+	pipe := b.db.TxPipeline()
+	pipe.HSetNX(ctx, b.enqueuedKey(), member, msg.EnqueuedAt)
+	pipe.ZAdd(ctx, b.Key.Key(),
 		&redis.Z{
 			Score:  float64(height),
-			Member: msg.Encode(),
+			Member: member,
 		},
-	).Result()
+	)
+	_, err = pipe.Exec(ctx)
 	return
 }
 
@@ -87,20 +109,36 @@ func (b *RedisSortedTxBus) Range(ctx context.Context, height uint64, count int64
 	if len(res) == 0 {
 		return
 	}
+	enqueued, err := b.db.HMGet(ctx, b.enqueuedKey(), res...).Result()
+	if err != nil {
+		return
+	}
 	txs = make([]*msg.Tx, len(res))
 	for i, item := range res {
 		tx := new(msg.Tx)
-		e := tx.Decode(item)
+		e := tx.Unmarshal([]byte(item))
 		if e != nil {
 			err = e
 		}
+		if v, ok := enqueued[i].(string); ok {
+			tx.EnqueuedAt, _ = strconv.ParseInt(v, 10, 64)
+		}
 		txs[i] = tx
 	}
 	return
 }
 
 func (b *RedisSortedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score uint64, err error) {
-	res, err := b.db.BZPopMin(ctx, 0, b.Key.Key()).Result()
+	return b.PopTimed(ctx, 0)
+}
+
+// Pop waiting at most duration, a nil tx is returned on timeout. Zero duration blocks until a tx arrives.
+func (b *RedisSortedTxBus) PopTimed(ctx context.Context, duration time.Duration) (tx *msg.Tx, score uint64, err error) {
+	res, err := b.db.BZPopMin(ctx, duration, b.Key.Key()).Result()
+	if err == redis.Nil {
+		// Timed out with the queue empty
+		return nil, 0, nil
+	}
 	if err != nil {
 		return
 	}
@@ -108,7 +146,24 @@ func (b *RedisSortedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score uint64, e
 		return
 	}
 	score = uint64(res.Score)
+	member := res.Member.(string)
 	tx = new(msg.Tx)
-	err = tx.Decode(res.Member.(string))
+	err = tx.Unmarshal([]byte(member))
+	if err != nil {
+		return
+	}
+	b.takeEnqueued(ctx, tx, member)
 	return
 }
+
+// Fill in the enqueue time of the popped member and forget it, members pushed before the enqueue times
+// were kept apart carry their own
+func (b *RedisSortedTxBus) takeEnqueued(ctx context.Context, tx *msg.Tx, member string) {
+	pipe := b.db.TxPipeline()
+	enqueued := pipe.HGet(ctx, b.enqueuedKey(), member)
+	pipe.HDel(ctx, b.enqueuedKey(), member)
+	pipe.Exec(ctx)
+	if at, err := enqueued.Int64(); err == nil {
+		tx.EnqueuedAt = at
+	}
+}
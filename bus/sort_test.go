@@ -0,0 +1,74 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRedisSortedTxBusEnqueuedAt(t *testing.T) {
+	server := miniredis.NewMiniRedis()
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+	b := NewRedisSortedTxBus(redis.NewClient(&redis.Options{Addr: server.Addr()}), 2, msg.SRC)
+
+	if err := b.Push(ctx, &msg.Tx{SrcHash: "a"}, 10); err != nil {
+		t.Fatal(err)
+	}
+	txs, err := b.Range(ctx, 10, 10)
+	if err != nil || len(txs) != 1 || txs[0].EnqueuedAt == 0 {
+		t.Fatalf("Expect tx stamped on push, got %+v err %v", txs, err)
+	}
+
+	// Pushed back txs keep the first enqueue time
+	tx := txs[0]
+	tx.SrcHash = "b"
+	tx.EnqueuedAt -= 100
+	first := tx.EnqueuedAt
+	if err = b.Push(ctx, tx, 20); err != nil {
+		t.Fatal(err)
+	}
+	txs, err = b.Range(ctx, 20, 10)
+	if err != nil || len(txs) != 2 || txs[1].EnqueuedAt != first {
+		t.Fatalf("Expect first enqueue time kept, got %+v err %v", txs, err)
+	}
+}
+
+func TestRedisSortedTxBusDuplicatedPush(t *testing.T) {
+	server := miniredis.NewMiniRedis()
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+	b := NewRedisSortedTxBus(redis.NewClient(&redis.Options{Addr: server.Addr()}), 2, msg.SRC)
+
+	// The same fresh tx pushed at different times stays a single member, keeping the first enqueue time
+	if err := b.Push(ctx, &msg.Tx{SrcHash: "a"}, 10); err != nil {
+		t.Fatal(err)
+	}
+	txs, err := b.Range(ctx, 10, 10)
+	if err != nil || len(txs) != 1 {
+		t.Fatalf("Expect one tx, got %+v err %v", txs, err)
+	}
+	first := txs[0].EnqueuedAt
+	time.Sleep(time.Second)
+	if err = b.Push(ctx, &msg.Tx{SrcHash: "a"}, 10); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := b.Len(ctx); err != nil || size != 1 {
+		t.Fatalf("Expect duplicated push collapsed, got %v err %v", size, err)
+	}
+	txs, err = b.Range(ctx, 10, 10)
+	if err != nil || len(txs) != 1 || txs[0].EnqueuedAt != first {
+		t.Fatalf("Expect first enqueue time %v, got %+v err %v", first, txs, err)
+	}
+}
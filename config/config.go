@@ -48,8 +48,12 @@ type Config struct {
 	Chains map[uint64]*ChainConfig
 
 	// Http
-	Host string
-	Port int
+	Host        string
+	Port        int
+	MetricsPort int // Port the relay process serves its metrics on, 0 to collect without serving
+
+	// Src chain id to the normalization of poly tx ids: reverse, none or strip-prefix, on top of the defaults
+	TxIdNormalization map[uint64]string
 
 	ValidMethods []string
 	validMethods map[string]bool
@@ -152,6 +156,55 @@ type ListenerConfig struct {
 	ListenCheck       int
 	Bus               *BusConfig
 	Defer             int
+	ProofWorkers      int // Max concurrent proof fetches when scanning poly txs, defaults to 1
+
+	// Poly node validation fails when chain height grows less than MinHeightDelta within HeightDeltaWindow seconds
+	MinHeightDelta    uint64
+	HeightDeltaWindow int
+
+	NodeCheckInterval int    // Poly sdk node height check interval in seconds, defaults to 60
+	NodeMaxGap        uint64 // Max height lag of the selected poly node, defaults to 1
+	HeightCacheTTL    int    // Milliseconds LatestHeight results are shared between callers, 0 to disable
+
+	CheckReorg    bool   // Check parent hash linkage of scanned poly blocks and fail scans on reorg
+	FinalityDepth uint64 // Blocks required on top of a poly tx for ValidateFinal to pass
+	FinalityWait  int    // Seconds Compose waits for a poly tx to reach FinalityDepth
+	Confirmations uint64 // Blocks required on top of a poly block before Scan emits its txs, newer txs are held
+
+	// Validate verifies the proof against the cross states root of the poly header, costs a header fetch per tx
+	VerifyStateRoot bool
+
+	Methods []string // Tx methods to emit at scan, all methods if empty
+
+	RateLimit float64 // Max poly node calls per second of the listener, 0 to disable
+	RateBurst int     // Node calls allowed in a burst, defaults to 1
+
+	BreakerThreshold int // Consecutive poly node failures to open the circuit breaker, 0 to disable
+	BreakerCooldown  int // Seconds to fail fast before probing the node again, defaults to 30
+
+	NodeHealth *NodeHealthConfig // Optional quarantine of unhealthy poly nodes
+}
+
+// Poly nodes are quarantined by the health over a window of calls and reintegrated after a probe call succeeds.
+// Quarantine is disabled unless ErrorRate or MaxLatency is set.
+type NodeHealthConfig struct {
+	ErrorRate  float64 // Rate of unreachable node failures in the window to quarantine the node at
+	MaxLatency int     // Average call latency in milliseconds in the window to quarantine the node above
+	Window     int     // Calls to evaluate the node health over, defaults to 20
+	Quarantine int     // Seconds to keep the node out before probing it, defaults to 60
+}
+
+// Whether txs of the method should be emitted by the listener scan
+func (c *ListenerConfig) AllowMethod(method string) bool {
+	if c == nil || len(c.Methods) == 0 {
+		return true
+	}
+	for _, m := range c.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
 }
 
 type PolySubmitterConfig struct {
@@ -159,6 +212,73 @@ type PolySubmitterConfig struct {
 	Nodes   []string
 	Procs   int
 	Wallet  *wallet.Config
+
+	// Optional dedicated wallet for header sync, so header submits and tx imports do not contend on nonce
+	HeaderWallet    *wallet.Config
+	IdleInterval    int // Bus idle poll interval in milliseconds
+	MaxIdleInterval int // Max bus idle poll interval in milliseconds when backing off
+	StopTimeout     int // Seconds to wait for workers to exit on stop, 0 to wait without bound
+	DedupTTL        int // Seconds to remember processed txs to drop duplicates, 0 to disable
+
+	// No signer balance threshold is configured here, as poly serves no balances. The balance monitor is a hook
+	// with no default source, set along with its threshold by Submitter.SetBalanceSource of the embedding relayer.
+
+	NodeCheckInterval int    // Poly sdk node height check interval in seconds, defaults to 60
+	NodeMaxGap        uint64 // Max height lag of the selected poly node, defaults to 1
+
+	DryRun        bool // Validate and log txs and headers without signing or sending them to poly
+	ProofCacheTTL int  // Seconds to cache cross states proofs by height and key, 0 to disable
+	ComposeCache  int  // Poly headers and merkle proofs cached by height for composing txs, defaults to 1000
+
+	SortedSigChains []uint64                // Dst chains requiring poly header sigs sorted by signer address
+	SigEncodings    map[uint64]*SigEncoding // Recovery id encoding of poly header sigs per dst chain, defaults to raw recovery id
+
+	AnchorOffsets map[uint64]*AnchorOffset // Anchor header height offsets per dst chain
+
+	// Failed txs go to a dedicated retry bus drained by RetryProcs workers when the bus is set
+	RetryProcs       int
+	RetryInterval    int // Retry interval in milliseconds after a failed attempt
+	MaxRetryInterval int // Max retry interval in milliseconds when backing off
+
+	// Failed txs pushed back to the tx bus are throttled across workers, and each by its attempts with the retry intervals
+	RequeueRate  float64 // Max txs pushed back per second, 0 to disable
+	RequeueBurst int     // Txs pushed back in a burst, defaults to 1
+
+	BreakerThreshold int // Consecutive poly node failures to open the circuit breaker, 0 to disable
+	BreakerCooldown  int // Seconds to fail fast before probing the node again, defaults to 30
+
+	NodeHealth *NodeHealthConfig // Optional quarantine of unhealthy poly nodes
+
+	// Poly cross chain manager contract address in hex as in the tx notifies, defaults to the native contract.
+	// The poly tx sync listener uses it too unless it sets its own.
+	CCMContract string
+
+	ResendErrors []string // Substrings of poly tx pool errors to resend the tx on, defaults to nonce and duplicate tx errors
+
+	// Imported txs are returned as soon as sent unless ConfirmTimeout is set, header sync txs are always confirmed
+	ConfirmTimeout  int    // Seconds to wait for the imported tx confirmation, 0 to skip the wait
+	ConfirmStrategy string // CONFIRM_BY_BLOCKS or CONFIRM_BY_TIME, defaults to blocks
+	ConfirmDepth    uint64 // Blocks on top of the tx block to wait for with the blocks strategy
+	ConfirmDuration int    // Seconds the tx stays on chain to wait for with the time strategy
+
+	// Workers scale between Procs and MaxProcs by the tx bus depth when MaxProcs is above Procs
+	MaxProcs       int
+	ScaleUpDepth   uint64 // Add a worker when the bus depth is above it
+	ScaleDownDepth uint64 // Remove a worker when the bus depth is below it
+	ScaleInterval  int    // Seconds between scaling checks, defaults to 10
+
+	// Poly tx gas, sdk defaults are used when unset
+	GasPrice           uint64  // Fixed gas price, or the base price scaled in auto mode without a price oracle
+	GasLimit           uint64  // Gas limit of imported txs and header sync txs
+	GasPriceMultiplier float64 // Auto mode scaling the suggested gas price when above 0
+	GasLimitMultiplier float64 // Auto mode scaling the estimated import gas into the gas limit when above 0
+
+	BacklogAgeAlert int    // Seconds since the processing tx was enqueued to warn above, 0 to disable
+	AlertWebhook    string // Url to post alerts of significant failures to, alerts only hit logs if empty
+
+	// Header submits to poly shared by the side chain header syncs of the process, each chain keeps its own retry loop
+	HeaderSyncProcs int     // Side chains submitting headers at the same time, 0 for no limit
+	HeaderSyncRate  float64 // Max header sync txs per second across the chains, 0 to disable
 }
 
 func (c *PolySubmitterConfig) Fill(o *PolySubmitterConfig) *PolySubmitterConfig {
@@ -174,9 +294,218 @@ func (c *PolySubmitterConfig) Fill(o *PolySubmitterConfig) *PolySubmitterConfig
 	} else {
 		o.Wallet.Path = GetConfigPath(WALLET_PATH, o.Wallet.Path)
 	}
+	if o.HeaderWallet == nil {
+		o.HeaderWallet = c.HeaderWallet
+	} else {
+		o.HeaderWallet.Path = GetConfigPath(WALLET_PATH, o.HeaderWallet.Path)
+	}
+	if o.IdleInterval == 0 {
+		o.IdleInterval = c.IdleInterval
+	}
+	if o.MaxIdleInterval == 0 {
+		o.MaxIdleInterval = c.MaxIdleInterval
+	}
+	if o.StopTimeout == 0 {
+		o.StopTimeout = c.StopTimeout
+	}
+	if o.DedupTTL == 0 {
+		o.DedupTTL = c.DedupTTL
+	}
+	if o.NodeCheckInterval == 0 {
+		o.NodeCheckInterval = c.NodeCheckInterval
+	}
+	if o.NodeMaxGap == 0 {
+		o.NodeMaxGap = c.NodeMaxGap
+	}
+	if !o.DryRun {
+		o.DryRun = c.DryRun
+	}
+	if o.ProofCacheTTL == 0 {
+		o.ProofCacheTTL = c.ProofCacheTTL
+	}
+	if o.ComposeCache == 0 {
+		o.ComposeCache = c.ComposeCache
+	}
+	if len(o.SortedSigChains) == 0 {
+		o.SortedSigChains = c.SortedSigChains
+	}
+	if len(o.SigEncodings) == 0 {
+		o.SigEncodings = c.SigEncodings
+	}
+	if len(o.AnchorOffsets) == 0 {
+		o.AnchorOffsets = c.AnchorOffsets
+	}
+	if o.RetryProcs == 0 {
+		o.RetryProcs = c.RetryProcs
+	}
+	if o.RetryInterval == 0 {
+		o.RetryInterval = c.RetryInterval
+	}
+	if o.MaxRetryInterval == 0 {
+		o.MaxRetryInterval = c.MaxRetryInterval
+	}
+	if o.RequeueRate == 0 {
+		o.RequeueRate = c.RequeueRate
+	}
+	if o.RequeueBurst == 0 {
+		o.RequeueBurst = c.RequeueBurst
+	}
+	if o.BreakerThreshold == 0 {
+		o.BreakerThreshold = c.BreakerThreshold
+	}
+	if o.BreakerCooldown == 0 {
+		o.BreakerCooldown = c.BreakerCooldown
+	}
+	if o.NodeHealth == nil {
+		o.NodeHealth = c.NodeHealth
+	}
+	if len(o.ResendErrors) == 0 {
+		o.ResendErrors = c.ResendErrors
+	}
+	if o.ConfirmTimeout == 0 {
+		o.ConfirmTimeout = c.ConfirmTimeout
+	}
+	if o.ConfirmDepth == 0 {
+		o.ConfirmDepth = c.ConfirmDepth
+	}
+	if o.ConfirmStrategy == "" {
+		o.ConfirmStrategy = c.ConfirmStrategy
+	}
+	if o.ConfirmDuration == 0 {
+		o.ConfirmDuration = c.ConfirmDuration
+	}
+	if o.MaxProcs == 0 {
+		o.MaxProcs = c.MaxProcs
+	}
+	if o.ScaleUpDepth == 0 {
+		o.ScaleUpDepth = c.ScaleUpDepth
+	}
+	if o.ScaleDownDepth == 0 {
+		o.ScaleDownDepth = c.ScaleDownDepth
+	}
+	if o.ScaleInterval == 0 {
+		o.ScaleInterval = c.ScaleInterval
+	}
+	if o.GasPrice == 0 {
+		o.GasPrice = c.GasPrice
+	}
+	if o.GasLimit == 0 {
+		o.GasLimit = c.GasLimit
+	}
+	if o.GasPriceMultiplier == 0 {
+		o.GasPriceMultiplier = c.GasPriceMultiplier
+	}
+	if o.GasLimitMultiplier == 0 {
+		o.GasLimitMultiplier = c.GasLimitMultiplier
+	}
+	if o.BacklogAgeAlert == 0 {
+		o.BacklogAgeAlert = c.BacklogAgeAlert
+	}
+	if o.AlertWebhook == "" {
+		o.AlertWebhook = c.AlertWebhook
+	}
+	if o.CCMContract == "" {
+		o.CCMContract = c.CCMContract
+	}
+	if o.HeaderSyncProcs == 0 {
+		o.HeaderSyncProcs = c.HeaderSyncProcs
+	}
+	if o.HeaderSyncRate == 0 {
+		o.HeaderSyncRate = c.HeaderSyncRate
+	}
 	return o
 }
 
+// Whether the dst chain requires poly header sigs sorted by signer
+func (c *PolySubmitterConfig) SortSigs(chainId uint64) bool {
+	for _, id := range c.SortedSigChains {
+		if id == chainId {
+			return true
+		}
+	}
+	return false
+}
+
+// Poly header sig v value encoding of the dst chain
+func (c *PolySubmitterConfig) SigEncoding(chainId uint64) *SigEncoding {
+	return c.SigEncodings[chainId]
+}
+
+const (
+	SIG_V_LEGACY = "legacy" // v = recovery id + 27
+	SIG_V_EIP155 = "eip155" // v = recovery id + ChainId * 2 + 35
+)
+
+// Poly tx confirmation strategies, networks with irregular block times confirm by time
+const (
+	CONFIRM_BY_BLOCKS = "blocks" // Wait for ConfirmDepth blocks on top of the tx block
+	CONFIRM_BY_TIME   = "time"   // Wait for the tx to stay on chain for ConfirmDuration seconds
+)
+
+// Check the confirmation strategy is known and its duration fits the header sync confirm timeout in seconds
+func (c *PolySubmitterConfig) CheckConfirmStrategy(headerTimeout int) error {
+	switch c.ConfirmStrategy {
+	case "", CONFIRM_BY_BLOCKS:
+	case CONFIRM_BY_TIME:
+		if c.ConfirmDuration <= 0 {
+			return fmt.Errorf("confirm duration required by the %s confirm strategy", CONFIRM_BY_TIME)
+		}
+		if c.ConfirmTimeout > 0 && c.ConfirmDuration >= c.ConfirmTimeout {
+			return fmt.Errorf("confirm duration %ds not below confirm timeout %ds", c.ConfirmDuration, c.ConfirmTimeout)
+		}
+		if c.ConfirmDuration >= headerTimeout {
+			return fmt.Errorf("confirm duration %ds not below header sync confirm timeout %ds", c.ConfirmDuration, headerTimeout)
+		}
+	default:
+		return fmt.Errorf("unknown confirm strategy %q", c.ConfirmStrategy)
+	}
+	return nil
+}
+
+type SigEncoding struct {
+	Scheme  string
+	ChainId uint64 // Chain id used by the eip155 scheme
+}
+
+// Default anchor header height offsets
+const (
+	ANCHOR_EPOCH_OFFSET       = 2
+	ANCHOR_EPOCH_START_OFFSET = 1
+)
+
+// Anchor header height offsets of a dst chain, zero values take the defaults
+type AnchorOffset struct {
+	Epoch      uint32 // Above the tx height when the tx block switches the poly epoch
+	EpochStart uint32 // Above the dst chain poly epoch start height when the tx is below it
+}
+
+// Anchor header height offsets of the dst chain with the defaults applied
+func (c *PolySubmitterConfig) AnchorOffset(chainId uint64) AnchorOffset {
+	offset := AnchorOffset{Epoch: ANCHOR_EPOCH_OFFSET, EpochStart: ANCHOR_EPOCH_START_OFFSET}
+	if c == nil || c.AnchorOffsets[chainId] == nil {
+		return offset
+	}
+	if o := c.AnchorOffsets[chainId]; o.Epoch > 0 {
+		offset.Epoch = o.Epoch
+	}
+	if o := c.AnchorOffsets[chainId]; o.EpochStart > 0 {
+		offset.EpochStart = o.EpochStart
+	}
+	return offset
+}
+
+// The anchor header root should cover the poly header following the tx block, which is signed by the
+// keepers of the new epoch at epoch boundaries
+func (o AnchorOffset) Validate() error {
+	if o.Epoch < ANCHOR_EPOCH_OFFSET {
+		return fmt.Errorf("Epoch anchor offset %d should be at least %d", o.Epoch, ANCHOR_EPOCH_OFFSET)
+	}
+	if o.EpochStart < ANCHOR_EPOCH_START_OFFSET {
+		return fmt.Errorf("Epoch start anchor offset %d should be at least %d", o.EpochStart, ANCHOR_EPOCH_START_OFFSET)
+	}
+	return nil
+}
+
 type SubmitterConfig struct {
 	ChainId     uint64
 	Nodes       []string
@@ -203,6 +532,13 @@ type BusConfig struct {
 		DB         int
 		MaxRetries int
 	}
+	Kafka      *KafkaConfig  // Optional kafka backend of the tx bus
+	Priorities PriorityRules // Rules assigning tx priorities, drained high first by priority aware buses
+}
+
+type KafkaConfig struct {
+	Brokers []string
+	GroupId string // Consumer group sharing the tx topic partitions
 }
 
 func (c *BusConfig) Init() {
@@ -214,13 +550,26 @@ func (c *BusConfig) Init() {
 }
 
 type HeaderSyncConfig struct {
-	Batch   int
-	Timeout int
-	Buffer  int
-	Enabled bool
-	Poly    *PolySubmitterConfig
+	Batch         int
+	MaxBatchBytes int // Max total bytes of headers in a batch, 0 for no limit
+	Timeout       int
+	Buffer        int
+	Enabled       bool
+	VerifyNodes   bool // Cross check side chain header hash across all poly nodes
+	DedupWindow   int  // Recent header heights to skip duplicated headers within, 0 to disable
+	Poly          *PolySubmitterConfig
 	*ListenerConfig
 	Bus *BusConfig
+
+	// Header sync poly tx gas overriding the poly submitter config
+	GasPrice uint64
+	GasLimit uint64
+
+	Codec string // Codec compressing header data passed to the submitter, gzip or snappy, raw if empty
+
+	ForkRetryLimit int // Retries with backoff on fork-like header submit errors before rolling back, 0 to roll back at once
+
+	StopHeight uint64 // Side chain height to stop the header sync at once submitted, 0 to sync forever
 }
 
 type SrcTxSyncConfig struct {
@@ -236,6 +585,7 @@ type SrcTxCommitConfig struct {
 	Procs           int
 	Enabled         bool
 	Bus             *BusConfig
+	RetryBus        *BusConfig // Optional bus of the failed txs drained by Poly.RetryProcs, redis of Bus if not configured
 	Poly            *PolySubmitterConfig
 	Filter          *FilterConfig
 }
@@ -272,6 +622,11 @@ func (c *Config) Init() (err error) {
 		c.Bus.Init()
 	}
 
+	err = msg.SetTxIdNormalization(c.TxIdNormalization)
+	if err != nil {
+		return
+	}
+
 	if c.Poly != nil {
 		err = c.Poly.Init(c.Bus)
 		if err != nil {
@@ -309,10 +664,16 @@ func (c *PolyChainConfig) Init(bus *BusConfig) (err error) {
 		if len(c.PolyTxSync.Nodes) == 0 {
 			c.PolyTxSync.Nodes = c.Nodes
 		}
+		if c.PolyTxSync.CCMContract == "" {
+			c.PolyTxSync.CCMContract = c.CCMContract
+		}
 	}
 	if c.Wallet != nil {
 		c.Wallet.Path = GetConfigPath(WALLET_PATH, c.Wallet.Path)
 	}
+	if c.HeaderWallet != nil {
+		c.HeaderWallet.Path = GetConfigPath(WALLET_PATH, c.HeaderWallet.Path)
+	}
 	if c.ExtraWallets != nil {
 		c.ExtraWallets.Path = GetConfigPath(WALLET_PATH, c.ExtraWallets.Path)
 	}
@@ -371,6 +732,13 @@ func (c *ChainConfig) Init(chain uint64, bus *BusConfig, poly *PolyChainConfig)
 		c.SrcTxCommit.Bus = bus
 	}
 	c.SrcTxCommit.Poly = poly.PolySubmitterConfig.Fill(c.SrcTxCommit.Poly)
+	if retry := c.SrcTxCommit.RetryBus; retry != nil && retry.Redis == nil {
+		if retry.Config == nil && c.SrcTxCommit.Bus != nil {
+			retry.Redis = c.SrcTxCommit.Bus.Redis
+		} else {
+			retry.Init()
+		}
+	}
 	if c.SrcTxCommit.Filter == nil {
 		c.SrcTxCommit.Filter = c.SrcFilter
 	}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Tx priority rule, empty conditions match any tx
+type PriorityRule struct {
+	SrcChains []uint64 // Src chains to match
+	DstChains []uint64 // Dst chains to match
+	MinAmount *big.Int // Min dst amount to match, txs without dst amount do not match
+	Priority  int
+}
+
+func (r *PriorityRule) Match(tx *msg.Tx) bool {
+	return matchChain(r.SrcChains, tx.SrcChainId) && matchChain(r.DstChains, tx.DstChainId) &&
+		(r.MinAmount == nil || tx.DstAmount != nil && tx.DstAmount.Cmp(r.MinAmount) >= 0)
+}
+
+func matchChain(chains []uint64, chain uint64) bool {
+	if len(chains) == 0 {
+		return true
+	}
+	for _, id := range chains {
+		if id == chain {
+			return true
+		}
+	}
+	return false
+}
+
+type PriorityRules []*PriorityRule
+
+// Priority of the first matching rule, 0 if none matches
+func (r PriorityRules) Priority(tx *msg.Tx) int {
+	for _, rule := range r {
+		if rule.Match(tx) {
+			return rule.Priority
+		}
+	}
+	return 0
+}
+
+// Distinct positive priorities in descending order
+func (r PriorityRules) Levels() (levels []int) {
+	seen := map[int]bool{}
+	for _, rule := range r {
+		if rule.Priority > 0 && !seen[rule.Priority] {
+			seen[rule.Priority] = true
+			levels = append(levels, rule.Priority)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(levels)))
+	return
+}
@@ -3,9 +3,11 @@ module github.com/polynetwork/poly-relayer
 go 1.15
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.3
 	github.com/btcsuite/btcd v0.22.1
 	github.com/ethereum/go-ethereum v1.10.7
 	github.com/go-redis/redis/v8 v8.11.3
+	github.com/golang/snappy v0.0.3
 	github.com/joeqian10/neo-gogogo v1.4.0
 	github.com/kr/pretty v0.3.0 // indirect
 	github.com/onflow/cadence v0.23.3-patch.1
@@ -23,15 +25,19 @@ require (
 	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/rogpeppe/go-internal v1.8.0 // indirect
 	github.com/rs/cors v1.8.0 // indirect
+	github.com/segmentio/kafka-go v0.4.23
 	github.com/spf13/afero v1.8.0 // indirect
 	github.com/spf13/cobra v1.3.0 // indirect
 	github.com/spf13/viper v1.10.1 // indirect
 	github.com/starcoinorg/starcoin-go v0.0.0-20220105024102-530daedc128b
 	github.com/urfave/cli/v2 v2.3.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
 	go.uber.org/goleak v1.1.11-0.20210813005559-691160354723 // indirect
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa
 	golang.org/x/net v0.0.0-20211123203042-d83791d6bcd9 // indirect
 	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
-	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba // indirect
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 	google.golang.org/grpc v1.46.2
 )
@@ -290,6 +290,28 @@ func main() {
 					},
 				},
 			},
+			&cli.Command{
+				Name:   relayer.RECONCILE,
+				Usage:  "Push back src txs missing from poly in range",
+				Action: command(relayer.RECONCILE),
+				Flags: []cli.Flag{
+					&cli.Uint64Flag{
+						Name:     "chain",
+						Usage:    "src chain",
+						Required: true,
+					},
+					&cli.Uint64Flag{
+						Name:     "from",
+						Usage:    "reconcile start height",
+						Required: true,
+					},
+					&cli.Uint64Flag{
+						Name:     "to",
+						Usage:    "reconcile end height",
+						Required: true,
+					},
+				},
+			},
 			&cli.Command{
 				Name:   relayer.SCAN_POLY_TX,
 				Usage:  "Scan poly txs in range",
@@ -17,7 +17,10 @@
 
 package msg
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ERR_INVALID_TX            = errors.New("Invalid TX")
@@ -25,18 +28,53 @@ var (
 	ERR_PROOF_UNAVAILABLE     = errors.New("Tx proof unavailable")
 	ERR_HEADER_INCONSISTENT   = errors.New("Header inconsistent")
 	ERR_HEADER_MISSING        = errors.New("Header missing")
+	ERR_HEADER_FORK           = errors.New("Header fork")
+	ERR_HEADER_MALFORMED      = errors.New("Header malformed")
 	ERR_TX_EXEC_FAILURE       = errors.New("Tx exec failure")
 	ERR_FEE_CHECK_FAILURE     = errors.New("Tx fee check failure")
 	ERR_HEADER_SUBMIT_FAILURE = errors.New("Header submit failure")
+	ERR_AUDIT_PATH_INVALID    = errors.New("Invalid audit path")
+	ERR_EMPTY_SIGDATA         = errors.New("Poly header without sigs")
 	ERR_TX_EXEC_ALWAYS_FAIL   = errors.New("Tx exec always fail")
 	ERR_LOW_BALANCE           = errors.New("Insufficient balance")
 	ERR_PAID_FEE_TOO_LOW      = errors.New("Paid fee too low")
 	ERR_Tx_VERIFYMERKLEPROOF  = errors.New("Tx verifyMerkleProof err")
+	ERR_STOP_TIMEOUT          = errors.New("Stop timeout")
+	ERR_NODES_INCONSISTENT    = errors.New("Nodes inconsistent")
+	ERR_EPOCH_KEEPERS_MISSING = errors.New("Dst chain poly keepers not provided")
+	ERR_QUORUM_NOT_REACHED    = errors.New("Node quorum not reached")
+	ERR_INSUFFICIENT_SIGS     = errors.New("Insufficient poly header sigs")
+	ERR_REORG                 = errors.New("Chain reorg detected")
+	ERR_BREAKER_OPEN          = errors.New("Node circuit breaker open")
+	ERR_MERKLE_VALUE_MISSING  = errors.New("Valid ToMerkleValue not found")
+	ERR_TX_UNCONFIRMED        = errors.New("Tx unconfirmed")
+	ERR_BUS_FULL              = errors.New("Tx bus full")
+	ERR_BUS_CLOSED            = errors.New("Tx bus closed")
+	ERR_TX_SCHEMA_UNSUPPORTED = errors.New("Unsupported tx schema version")
+	ERR_HEADER_CODEC_UNKNOWN  = errors.New("Unknown header codec")
+	ERR_REQUEST_REPLAYED      = errors.New("Request replayed")
 
-	ERR_TX_VOILATION     = errors.New("Possible cross chain voilation")
-	ERR_TX_PROOF_MISSING = errors.New("Possible cross chain proof missing")
+	ERR_TX_VOILATION      = errors.New("Possible cross chain voilation")
+	ERR_TX_PROOF_MISSING  = errors.New("Possible cross chain proof missing")
+	ERR_MISSING_DST_PROXY = errors.New("Dst proxy not provided, check proxy mapping config")
+	ERR_TX_NOT_FINAL      = errors.New("Poly tx not final")
 
 	ERR_COIN_STORE_NOT_PUBLISHED = errors.New("Account hasn't registered CoinStore for CoinType")
 	ERR_TREASURY_NOT_EXIST       = errors.New("Asset not exist in lock proxy")
 	ERR_SEQUENCE_NUMBER_INVALID  = errors.New("Sequence number is invalid")
+
+	ERR_TX_ID_NORMALIZATION_UNKNOWN = errors.New("Unknown tx id normalization")
 )
+
+// Chain reorg detected while scanning, blocks from Height on should be rescanned
+type ReorgError struct {
+	Height uint64
+}
+
+func (e *ReorgError) Error() string {
+	return fmt.Sprintf("%v at height %d", ERR_REORG, e.Height)
+}
+
+func (e *ReorgError) Unwrap() error {
+	return ERR_REORG
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package msg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+// Codecs of header data, raw data carries no codec
+const (
+	HEADER_CODEC_GZIP   = "gzip"
+	HEADER_CODEC_SNAPPY = "snappy"
+)
+
+// Compress the header data with the codec, headers without data or already compressed are kept as is
+func (h *Header) Compress(codec string) (err error) {
+	if codec == "" || h.Codec != "" || h.Data == nil {
+		return
+	}
+	var data []byte
+	switch codec {
+	case HEADER_CODEC_GZIP:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err = w.Write(h.Data); err != nil {
+			return
+		}
+		if err = w.Close(); err != nil {
+			return
+		}
+		data = buf.Bytes()
+	case HEADER_CODEC_SNAPPY:
+		data = snappy.Encode(nil, h.Data)
+	default:
+		return fmt.Errorf("%w %s", ERR_HEADER_CODEC_UNKNOWN, codec)
+	}
+	h.Data, h.Codec = data, codec
+	return
+}
+
+// Decompress the header data by the codec flag of the header, raw headers are kept as is
+func (h *Header) Decompress() (err error) {
+	var data []byte
+	switch h.Codec {
+	case "":
+		return
+	case HEADER_CODEC_GZIP:
+		var r *gzip.Reader
+		r, err = gzip.NewReader(bytes.NewReader(h.Data))
+		if err == nil {
+			data, err = ioutil.ReadAll(r)
+		}
+	case HEADER_CODEC_SNAPPY:
+		data, err = snappy.Decode(nil, h.Data)
+	default:
+		return fmt.Errorf("%w %s", ERR_HEADER_CODEC_UNKNOWN, h.Codec)
+	}
+	if err != nil {
+		return fmt.Errorf("Decompress %s header at height %d error %v", h.Codec, h.Height, err)
+	}
+	h.Data, h.Codec = data, ""
+	return
+}
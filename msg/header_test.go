@@ -0,0 +1,41 @@
+package msg
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestHeaderCodec(t *testing.T) {
+	data := bytes.Repeat([]byte("poly header "), 100)
+	for _, codec := range []string{"", HEADER_CODEC_GZIP, HEADER_CODEC_SNAPPY} {
+		h := &Header{Height: 1, Data: append([]byte{}, data...)}
+		if err := h.Compress(codec); err != nil {
+			t.Fatalf("Codec %q compress error %v", codec, err)
+		}
+		if h.Codec != codec || (codec != "" && len(h.Data) >= len(data)) {
+			t.Fatalf("Codec %q unexpected compressed header %s size %d", codec, h.Codec, len(h.Data))
+		}
+		if err := h.Decompress(); err != nil {
+			t.Fatalf("Codec %q decompress error %v", codec, err)
+		}
+		if h.Codec != "" || !bytes.Equal(h.Data, data) {
+			t.Fatalf("Codec %q round trip mismatch", codec)
+		}
+	}
+
+	// Headers only updating the sync height carry no data
+	h := &Header{Height: 1}
+	if err := h.Compress(HEADER_CODEC_GZIP); err != nil || h.Codec != "" || h.Data != nil {
+		t.Fatalf("Expect empty header kept, got %+v %v", h, err)
+	}
+	if err := (&Header{Data: data}).Compress("lz4"); !errors.Is(err, ERR_HEADER_CODEC_UNKNOWN) {
+		t.Fatalf("Expect unknown codec error, got %v", err)
+	}
+	if err := (&Header{Data: data, Codec: "lz4"}).Decompress(); !errors.Is(err, ERR_HEADER_CODEC_UNKNOWN) {
+		t.Fatalf("Expect unknown codec error, got %v", err)
+	}
+	if err := (&Header{Data: data, Codec: HEADER_CODEC_GZIP}).Decompress(); err == nil {
+		t.Fatal("Expect corrupted header error")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -41,6 +42,7 @@ type Header struct {
 	Height uint64
 	Hash   []byte
 	Data   []byte
+	Codec  string // Codec of the compressed data, raw data if empty
 }
 
 type PolyComposer func(*Tx) error
@@ -52,6 +54,7 @@ type SrcComposer interface {
 type Tx struct {
 	TxType   TxType
 	Attempts int
+	Priority int `json:",omitempty"` // Txs of higher priority are relayed first by priority aware buses
 
 	TxId        string                `json:",omitempty"`
 	MerkleValue *common.ToMerkleValue `json:"-"`
@@ -59,6 +62,7 @@ type Tx struct {
 
 	SrcHash        string `json:",omitempty"`
 	SrcHeight      uint64 `json:",omitempty"`
+	SrcTime        int64  `json:",omitempty"` // Src block timestamp in seconds
 	SrcChainId     uint64 `json:",omitempty"`
 	SrcProof       []byte `json:"-"`
 	SrcProofHex    string `json:",omitempty"`
@@ -88,6 +92,7 @@ type Tx struct {
 	DstSender               interface{}           `json:"-"`
 	DstPolyEpochStartHeight uint32                `json:",omitempty"`
 	DstPolyKeepers          []byte                `json:"-"`
+	ForceAnchorHeight       uint32                `json:",omitempty"` // Use the anchor header at the height instead of the derived one
 	DstData                 []byte                `json:"-"`
 	DstProxy                string                `json:",omitempty"`
 	SkipCheckFee            bool                  `json:",omitempty"`
@@ -101,6 +106,12 @@ type Tx struct {
 	// aptos
 	ToAssetAddress string `json:",omitempty"`
 
+	TraceParent string `json:",omitempty"` // W3C traceparent of the trace to continue when relaying the tx
+
+	EnqueuedAt int64 `json:",omitempty"` // Unix time in seconds the tx was first pushed to a tx bus
+
+	PolyExtraStates map[int]interface{} `json:",omitempty"` // Poly notify states beyond the standard makeProof fields by index
+
 	Extra interface{} `json:"-"`
 }
 
@@ -108,6 +119,21 @@ func (tx *Tx) Type() TxType {
 	return tx.TxType
 }
 
+// Mark the tx enqueued now unless marked already, so txs pushed back keep their first enqueue time
+func (tx *Tx) MarkEnqueued() {
+	if tx.EnqueuedAt == 0 {
+		tx.EnqueuedAt = time.Now().Unix()
+	}
+}
+
+// Time since the tx was first enqueued, zero if not marked
+func (tx *Tx) BacklogAge() time.Duration {
+	if tx.EnqueuedAt <= 0 {
+		return 0
+	}
+	return time.Since(time.Unix(tx.EnqueuedAt, 0))
+}
+
 func (tx *Tx) Encode() string {
 	if len(tx.SrcProof) > 0 && len(tx.SrcProofHex) == 0 {
 		tx.SrcProofHex = hex.EncodeToString(tx.SrcProof)
@@ -155,6 +181,9 @@ func (tx *Tx) CapturePatchParams(o *Tx) *Tx {
 		if o.DstSender != nil {
 			tx.DstSender = o.DstSender
 		}
+		if o.ForceAnchorHeight > 0 {
+			tx.ForceAnchorHeight = o.ForceAnchorHeight
+		}
 	}
 	return tx
 }
@@ -170,6 +199,11 @@ func (tx *Tx) SkipFee() bool {
 	return false
 }
 
+// Key identifies the same cross chain tx across repeated scans, src txs are keyed before the poly hash is filled in
+func (tx *Tx) IdempotencyKey() string {
+	return fmt.Sprintf("%d:%s:%s", tx.SrcChainId, strings.ToLower(tx.TxId), strings.ToLower(tx.PolyHash))
+}
+
 func (tx *Tx) GetTxId() (id [32]byte, err error) {
 	bytes, err := hex.DecodeString(tx.TxId)
 	if err != nil {
@@ -273,3 +307,72 @@ func EncodeTxId(id []byte) string {
 	}
 	return hex.EncodeToString(index.Bytes())
 }
+
+// Count of the standard makeProof notify states
+const MAKE_PROOF_STATES = 6
+
+// Whether the notify states carry the makeProof method at any position
+func IsMakeProof(states []interface{}) bool {
+	return makeProofIndex(states) >= 0
+}
+
+func makeProofIndex(states []interface{}) int {
+	for i, state := range states {
+		if method, ok := state.(string); ok && method == "makeProof" {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseMakeProofStates decodes the states of a poly makeProof notify, which take the form of
+// [method, src chain id, dst chain id, tx id, poly height, cross states key]. TxId is normalized
+// to the form of the src chain listeners, see NormalizeTxId. Trailing states emitted by newer poly
+// versions are kept in PolyExtraStates.
+func ParseMakeProofStates(states []interface{}) (tx *Tx, err error) {
+	if i := makeProofIndex(states); i != 0 {
+		if i > 0 {
+			return nil, fmt.Errorf("makeProof method at index %d instead of 0, states may be reordered", i)
+		}
+		return nil, fmt.Errorf("makeProof states without makeProof method")
+	}
+	if len(states) < MAKE_PROOF_STATES {
+		return nil, fmt.Errorf("makeProof states expect length of %d, got %d", MAKE_PROOF_STATES, len(states))
+	}
+	srcChain, ok := states[1].(float64)
+	if !ok {
+		return nil, fmt.Errorf("makeProof states with invalid src chain id %v", states[1])
+	}
+	dstChain, ok := states[2].(float64)
+	if !ok || dstChain == 0 {
+		return nil, fmt.Errorf("makeProof states with invalid dst chain id %v", states[2])
+	}
+	txId, ok := states[3].(string)
+	if !ok {
+		return nil, fmt.Errorf("makeProof states with invalid tx id %v", states[3])
+	}
+	height, ok := states[4].(float64)
+	if !ok {
+		return nil, fmt.Errorf("makeProof states with invalid poly height %v", states[4])
+	}
+	key, ok := states[5].(string)
+	if !ok {
+		return nil, fmt.Errorf("makeProof states with invalid key %v", states[5])
+	}
+	tx = &Tx{
+		TxType:     POLY,
+		TxId:       txId,
+		SrcChainId: uint64(srcChain),
+		DstChainId: uint64(dstChain),
+		PolyHeight: uint32(height),
+		PolyKey:    key,
+	}
+	for i := MAKE_PROOF_STATES; i < len(states); i++ {
+		if tx.PolyExtraStates == nil {
+			tx.PolyExtraStates = map[int]interface{}{}
+		}
+		tx.PolyExtraStates[i] = states[i]
+	}
+	tx.TxId = NormalizeTxId(tx.SrcChainId, tx.TxId)
+	return
+}
@@ -0,0 +1,47 @@
+package msg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+)
+
+func TestParseMakeProofStates(t *testing.T) {
+	cases := []struct {
+		name   string
+		states []interface{}
+		tx     *Tx
+	}{
+		{"eth", []interface{}{"makeProof", float64(2), float64(6), "0a0b", float64(100), "key"},
+			&Tx{TxType: POLY, TxId: "0a0b", SrcChainId: 2, DstChainId: 6, PolyHeight: 100, PolyKey: "key"}},
+		{"neo reversed", []interface{}{"makeProof", float64(base.NEO), float64(2), "0a0b", float64(100), "key"},
+			&Tx{TxType: POLY, TxId: "0b0a", SrcChainId: base.NEO, DstChainId: 2, PolyHeight: 100, PolyKey: "key"}},
+		{"extended", []interface{}{"makeProof", float64(2), float64(6), "0a0b", float64(100), "key", "fee", float64(1)},
+			&Tx{TxType: POLY, TxId: "0a0b", SrcChainId: 2, DstChainId: 6, PolyHeight: 100, PolyKey: "key",
+				PolyExtraStates: map[int]interface{}{6: "fee", 7: float64(1)}}},
+		{"reordered", []interface{}{float64(2), "makeProof", float64(6), "0a0b", float64(100), "key", "fee"}, nil},
+		{"short", []interface{}{"makeProof", float64(2), float64(6)}, nil},
+		{"method", []interface{}{"btcTxToRelay", float64(2), float64(6), "0a0b", float64(100), "key"}, nil},
+		{"src chain", []interface{}{"makeProof", "2", float64(6), "0a0b", float64(100), "key"}, nil},
+		{"zero dst chain", []interface{}{"makeProof", float64(2), float64(0), "0a0b", float64(100), "key"}, nil},
+		{"tx id", []interface{}{"makeProof", float64(2), float64(6), nil, float64(100), "key"}, nil},
+		{"height", []interface{}{"makeProof", float64(2), float64(6), "0a0b", "100", "key"}, nil},
+		{"key", []interface{}{"makeProof", float64(2), float64(6), "0a0b", float64(100), 1}, nil},
+	}
+	for _, c := range cases {
+		tx, err := ParseMakeProofStates(c.states)
+		if c.tx == nil {
+			if err == nil {
+				t.Fatalf("Case %s expect error, got %+v", c.name, tx)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Case %s unexpected error %v", c.name, err)
+		}
+		if !reflect.DeepEqual(tx, c.tx) {
+			t.Fatalf("Case %s expect %+v, got %+v", c.name, c.tx, tx)
+		}
+	}
+}
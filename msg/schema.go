@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package msg
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Schema version of the tx records written by Marshal
+const TX_SCHEMA_VERSION = 1
+
+// Leading byte of the binary tx records, which never starts a json legacy record
+const TX_BINARY_MAGIC byte = 0x7f
+
+// Versioned tx record, the legacy records written by Encode carry neither field
+type txRecord struct {
+	Version int
+	Tx      json.RawMessage
+}
+
+// Marshal the tx into a json record with the schema version embedded. Buses decode records with Unmarshal,
+// so writers can move from Encode to Marshal once all the readers are upgraded.
+func (tx *Tx) Marshal() ([]byte, error) {
+	return json.Marshal(&txRecord{Version: TX_SCHEMA_VERSION, Tx: json.RawMessage(tx.Encode())})
+}
+
+// Unmarshal a json tx record of any known schema version, including the legacy unversioned records
+func (tx *Tx) Unmarshal(data []byte) (err error) {
+	record := new(txRecord)
+	err = json.Unmarshal(data, record)
+	if err != nil {
+		return fmt.Errorf("Decode tx record error %v", err)
+	}
+	if record.Version == 0 && record.Tx == nil {
+		return tx.Decode(string(data))
+	}
+	return tx.decodeVersion(record.Version, record.Tx)
+}
+
+// Marshal the tx into a binary record of the magic byte, the schema version and the tx body
+func (tx *Tx) MarshalBinary() ([]byte, error) {
+	return append([]byte{TX_BINARY_MAGIC, TX_SCHEMA_VERSION}, tx.Encode()...), nil
+}
+
+// Unmarshal a binary tx record, data without the magic byte is taken as a legacy json record
+func (tx *Tx) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != TX_BINARY_MAGIC {
+		return tx.Unmarshal(data)
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("Binary tx record too short")
+	}
+	return tx.decodeVersion(int(data[1]), data[2:])
+}
+
+func (tx *Tx) decodeVersion(version int, body []byte) error {
+	switch version {
+	case 1:
+		return tx.Decode(string(body))
+	}
+	return fmt.Errorf("%w %d", ERR_TX_SCHEMA_UNSUPPORTED, version)
+}
@@ -0,0 +1,49 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+func TestTxSchema(t *testing.T) {
+	param := &common.MakeTxParam{TxHash: []byte{1}, CrossChainID: []byte{2}, FromContractAddress: []byte{3},
+		ToChainID: 6, ToContractAddress: []byte{4}, Method: "unlock", Args: []byte{5}}
+	sink := pcom.NewZeroCopySink(nil)
+	param.Serialization(sink)
+	tx := &Tx{TxType: SRC, TxId: "0a0b", SrcHash: "a", SrcChainId: 2, DstChainId: 6, Priority: 1,
+		SrcParam: fmt.Sprintf("%x", sink.Bytes()), SrcProof: []byte{9}}
+
+	legacy := []byte(tx.Encode())
+	record, err := tx.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, _ := tx.MarshalBinary()
+	cases := map[string]func(*Tx) error{
+		"legacy":        func(o *Tx) error { return o.Unmarshal(legacy) },
+		"legacy binary": func(o *Tx) error { return o.UnmarshalBinary(legacy) },
+		"v1":            func(o *Tx) error { return o.Unmarshal(record) },
+		"v1 binary":     func(o *Tx) error { return o.UnmarshalBinary(binary) },
+	}
+	for name, decode := range cases {
+		o := new(Tx)
+		if err := decode(o); err != nil {
+			t.Fatalf("Case %s decode error %v", name, err)
+		}
+		if o.TxId != tx.TxId || o.SrcProofHex != "09" || o.Priority != 1 || !reflect.DeepEqual(o.Param, param) {
+			t.Fatalf("Case %s unexpected tx %+v", name, o)
+		}
+	}
+
+	if err := new(Tx).Unmarshal([]byte(`{"Version":2,"Tx":{}}`)); !errors.Is(err, ERR_TX_SCHEMA_UNSUPPORTED) {
+		t.Fatalf("Expect unsupported schema error, got %v", err)
+	}
+	if err := new(Tx).UnmarshalBinary([]byte{TX_BINARY_MAGIC, 2, '{', '}'}); !errors.Is(err, ERR_TX_SCHEMA_UNSUPPORTED) {
+		t.Fatalf("Expect unsupported binary schema error, got %v", err)
+	}
+}
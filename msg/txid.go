@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package msg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/util"
+)
+
+// Normalizations of the tx id in poly makeProof notifies to the form of the src chain listeners
+const (
+	TX_ID_REVERSE      = "reverse"
+	TX_ID_NONE         = "none"
+	TX_ID_STRIP_PREFIX = "strip-prefix"
+)
+
+var (
+	txIdLock sync.RWMutex
+	txIds    = defaultTxIdNormalization()
+)
+
+// Src chains whose tx id is byte reversed in poly
+func defaultTxIdNormalization() map[uint64]string {
+	return map[uint64]string{
+		base.NEO:  TX_ID_REVERSE,
+		base.NEO3: TX_ID_REVERSE,
+		base.ONT:  TX_ID_REVERSE,
+	}
+}
+
+// SetTxIdNormalization overrides the tx id normalization of the src chains on top of the defaults
+func SetTxIdNormalization(normalization map[uint64]string) error {
+	table := defaultTxIdNormalization()
+	for chain, mode := range normalization {
+		switch mode {
+		case TX_ID_REVERSE, TX_ID_NONE, TX_ID_STRIP_PREFIX:
+			table[chain] = mode
+		default:
+			return fmt.Errorf("%w %s for chain %d", ERR_TX_ID_NORMALIZATION_UNKNOWN, mode, chain)
+		}
+	}
+	txIdLock.Lock()
+	txIds = table
+	txIdLock.Unlock()
+	return nil
+}
+
+// NormalizeTxId converts the tx id between the poly and src chain forms, chains not configured are kept as is
+func NormalizeTxId(chainId uint64, txId string) string {
+	txIdLock.RLock()
+	mode := txIds[chainId]
+	txIdLock.RUnlock()
+	switch mode {
+	case TX_ID_REVERSE:
+		return util.ReverseHex(txId)
+	case TX_ID_STRIP_PREFIX:
+		return strings.TrimPrefix(strings.TrimPrefix(txId, "0x"), "0X")
+	}
+	return txId
+}
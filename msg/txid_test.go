@@ -0,0 +1,62 @@
+package msg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+)
+
+func TestNormalizeTxId(t *testing.T) {
+	defer SetTxIdNormalization(nil)
+
+	// Defaults reverse NEO, NEO3 and ONT tx ids and keep the others
+	cases := []struct {
+		chain uint64
+		txId  string
+	}{
+		{base.NEO, "0b0a"},
+		{base.NEO3, "0b0a"},
+		{base.ONT, "0b0a"},
+		{base.ETH, "0a0b"},
+	}
+	for _, c := range cases {
+		if txId := NormalizeTxId(c.chain, "0a0b"); txId != c.txId {
+			t.Fatalf("Expect default tx id %s for chain %d, got %s", c.txId, c.chain, txId)
+		}
+	}
+
+	err := SetTxIdNormalization(map[uint64]string{base.ONT: TX_ID_NONE, base.ETH: TX_ID_REVERSE, 100: TX_ID_STRIP_PREFIX})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases = []struct {
+		chain uint64
+		txId  string
+	}{
+		{base.NEO, "0b0a"},
+		{base.ONT, "0a0b"},
+		{base.ETH, "0b0a"},
+		{100, "0a0b"},
+	}
+	for _, c := range cases {
+		if txId := NormalizeTxId(c.chain, "0a0b"); txId != c.txId {
+			t.Fatalf("Expect tx id %s for chain %d, got %s", c.txId, c.chain, txId)
+		}
+	}
+	if txId := NormalizeTxId(100, "0x0a0b"); txId != "0a0b" {
+		t.Fatalf("Expect prefix stripped, got %s", txId)
+	}
+	tx, err := ParseMakeProofStates([]interface{}{"makeProof", float64(base.ETH), float64(6), "0a0b", float64(100), "key"})
+	if err != nil || tx.TxId != "0b0a" {
+		t.Fatalf("Expect configured normalization applied to makeProof states, got %v %v", tx, err)
+	}
+
+	err = SetTxIdNormalization(map[uint64]string{base.ETH: "upper"})
+	if !errors.Is(err, ERR_TX_ID_NORMALIZATION_UNKNOWN) {
+		t.Fatalf("Expect unknown normalization error, got %v", err)
+	}
+	if txId := NormalizeTxId(base.ETH, "0a0b"); txId != "0b0a" {
+		t.Fatal("Expect normalization kept after invalid config")
+	}
+}
@@ -50,32 +50,33 @@ import (
 )
 
 const (
-	SET_HEADER_HEIGHT = "setheaderblock"
-	SET_TX_HEIGHT     = "settxblock"
-	RELAY_TX          = "submit"
-	STATUS            = "status"
-	HTTP              = "http"
-	PATCH             = "patch"
-	SKIP              = "skip"
-	CHECK_SKIP        = "checkskip"
-	CREATE_ACCOUNT    = "createaccount"
-	UPDATE_ACCOUNT    = "updateaccount"
-	ENCRYPT_FILE      = "encryptfile"
-	DECRYPT_FILE      = "decryptfile"
-	CHECK_WALLET      = "wallet"
-	ADD_SIDECHAIN     = "addsidechain"
-	SYNC_GENESIS      = "syncgenesis"
-	CREATE_GENESIS    = "creategenesis"
-	SIGN_POLY_TX   	  = "signpolytx"
-	SEND_POLY_TX      = "sendpolytx"
-	APPROVE_SIDECHAIN = "approvesidechain"
-	INIT_GENESIS      = "initgenesis"
-	SYNC_HEADER       = "syncheader"
-	GET_SIDE_CHAIN    = "getsidechain"
-	SCAN_POLY_TX      = "scanpolytx"
-	VALIDATE          = "validate"
-	VALIDATE_BLOCK    = "validateblock"
+	SET_HEADER_HEIGHT    = "setheaderblock"
+	SET_TX_HEIGHT        = "settxblock"
+	RELAY_TX             = "submit"
+	STATUS               = "status"
+	HTTP                 = "http"
+	PATCH                = "patch"
+	SKIP                 = "skip"
+	CHECK_SKIP           = "checkskip"
+	CREATE_ACCOUNT       = "createaccount"
+	UPDATE_ACCOUNT       = "updateaccount"
+	ENCRYPT_FILE         = "encryptfile"
+	DECRYPT_FILE         = "decryptfile"
+	CHECK_WALLET         = "wallet"
+	ADD_SIDECHAIN        = "addsidechain"
+	SYNC_GENESIS         = "syncgenesis"
+	CREATE_GENESIS       = "creategenesis"
+	SIGN_POLY_TX         = "signpolytx"
+	SEND_POLY_TX         = "sendpolytx"
+	APPROVE_SIDECHAIN    = "approvesidechain"
+	INIT_GENESIS         = "initgenesis"
+	SYNC_HEADER          = "syncheader"
+	GET_SIDE_CHAIN       = "getsidechain"
+	SCAN_POLY_TX         = "scanpolytx"
+	VALIDATE             = "validate"
+	VALIDATE_BLOCK       = "validateblock"
 	SET_VALIDATOR_HEIGHT = "setvalidatorblock"
+	RECONCILE            = "reconcile"
 )
 
 var _Handlers = map[string]func(*cli.Context) error{}
@@ -107,6 +108,7 @@ func init() {
 	_Handlers[VALIDATE] = Validate
 	_Handlers[VALIDATE_BLOCK] = ValidateBlock
 	_Handlers[SET_VALIDATOR_HEIGHT] = SetTxValidatorHeight
+	_Handlers[RECONCILE] = ReconcileTxs
 }
 
 func CheckWallet(ctx *cli.Context) (err error) {
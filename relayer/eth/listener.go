@@ -241,13 +241,27 @@ func (l *Listener) Scan(height uint64) (txs []*msg.Tx, err error) {
 	}
 
 	txs = []*msg.Tx{}
+	var blockTime int64
 	for events.Next() {
 		ev := events.Event
+		if blockTime == 0 {
+			hdr, e := l.sdk.Node().HeaderByNumber(context.Background(), big.NewInt(int64(height)))
+			if e != nil {
+				log.Warn("Failed to fetch block time for src txs", "chain", l.name, "height", height, "err", e)
+				blockTime = -1
+			} else {
+				blockTime = int64(hdr.Time)
+			}
+		}
 		param := &ccom.MakeTxParam{}
 		err = param.Deserialization(pcom.NewZeroCopySource([]byte(ev.Rawdata)))
 		if err != nil {
 			return
 		}
+		if !l.config.AllowMethod(param.Method) {
+			log.Debug("Skipping src tx of disallowed method", "chain", l.name, "hash", ev.Raw.TxHash.String(), "method", param.Method)
+			continue
+		}
 		tx := &msg.Tx{
 			TxType:     msg.SRC,
 			TxId:       msg.EncodeTxId(ev.TxId),
@@ -260,6 +274,9 @@ func (l *Listener) Scan(height uint64) (txs []*msg.Tx, err error) {
 			DstProxy:   common.BytesToAddress(ev.ToContract).String(),
 			SrcAddress: ev.Sender.String(),
 		}
+		if blockTime > 0 {
+			tx.SrcTime = blockTime
+		}
 		l.Compose(tx)
 		txs = append(txs, tx)
 	}
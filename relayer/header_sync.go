@@ -231,8 +231,13 @@ LOOP:
 		header, hash, err := h.listener.Header(h.height)
 		log.Debug("Header sync fetched block header", "height", h.height, "chain", h.config.ChainId, "err", err)
 		if err == nil {
+			hdr := msg.Header{Data: header, Height: h.height, Hash: hash}
+			err = hdr.Compress(h.config.Codec)
+			if err != nil {
+				log.Error("Failed to compress header, sending raw header", "chain", h.config.ChainId, "height", h.height, "err", err)
+			}
 			select {
-			case ch <- msg.Header{Data: header, Height: h.height, Hash: hash}:
+			case ch <- hdr:
 			case <-h.Done():
 				break LOOP
 			}
@@ -34,6 +34,7 @@ import (
 	"github.com/polynetwork/poly-relayer/bus"
 	"github.com/polynetwork/poly-relayer/config"
 	"github.com/polynetwork/poly-relayer/msg"
+	po "github.com/polynetwork/poly-relayer/relayer/poly"
 )
 
 var (
@@ -56,6 +57,9 @@ func Http(ctx *cli.Context) (err error) {
 	// Init patcher
 	_PATCHER = bus.NewRedisPatchTxBus(bus.New(config.CONFIG.Bus.Redis), 0)
 	_SKIP = bus.NewRedisSkipCheck(bus.New(config.CONFIG.Bus.Redis))
+	if !submit {
+		po.InitMetrics()
+	}
 	err = SetupController()
 	if err != nil {
 		return
@@ -64,7 +68,6 @@ func Http(ctx *cli.Context) (err error) {
 	if submit {
 		http.HandleFunc("/api/v1/submit", controller.SubmitTx)
 	} else {
-		metrics.Init("relayer")
 		go recordMetrics()
 		http.HandleFunc("/api/v1/patch", PatchTx)
 		http.HandleFunc("/api/v1/skip", SkipTx)
@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/polynetwork/bridge-common/log"
+)
+
+// Alert levels
+const (
+	ALERT_WARN  = "warn"
+	ALERT_ERROR = "error"
+)
+
+// Alerter receives significant relayer failures, so they can be routed to chat or paging services
+type Alerter interface {
+	Notify(level, msg string, fields map[string]interface{})
+}
+
+// Alerter dropping the alerts
+type NopAlerter struct{}
+
+func (NopAlerter) Notify(level, msg string, fields map[string]interface{}) {}
+
+// Alerter posting the alerts as json to the webhook url
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (a *WebhookAlerter) Notify(level, msg string, fields map[string]interface{}) {
+	body, err := json.Marshal(map[string]interface{}{
+		"level": level, "msg": msg, "fields": fields, "time": time.Now().Unix(),
+	})
+	if err != nil {
+		log.Error("Failed to encode alert", "msg", msg, "err", err)
+		return
+	}
+	res, err := a.Client.Post(a.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Error("Failed to post alert", "url", a.URL, "msg", msg, "err", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		log.Error("Alert webhook rejected alert", "url", a.URL, "msg", msg, "status", res.StatusCode)
+	}
+}
+
+// Set the receiver of significant failures, overriding the configured webhook
+func (s *Submitter) SetAlerter(alerter Alerter) {
+	s.alerter = alerter
+}
+
+func (s *Submitter) alert(level, msg string, fields map[string]interface{}) {
+	if s.alerter == nil {
+		return
+	}
+	s.alerter.Notify(level, msg, fields)
+}
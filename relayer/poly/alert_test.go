@@ -0,0 +1,103 @@
+package poly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type testAlerter struct {
+	alerts []string
+}
+
+func (a *testAlerter) Notify(level, msg string, fields map[string]interface{}) {
+	a.alerts = append(a.alerts, level+": "+msg)
+}
+
+func TestAlerts(t *testing.T) {
+	alerter := new(testAlerter)
+	s := &Submitter{name: "test", alerter: alerter}
+
+	// Dead letter
+	if !s.submitFailed(&msg.Tx{}, errors.New("http post request failed")) || len(alerter.alerts) != 0 {
+		t.Fatalf("Expect retried tx not alerted, got %v", alerter.alerts)
+	}
+	if s.submitFailed(&msg.Tx{}, errors.New("side chain 2 not registered")) {
+		t.Fatal("Expect tx dropped")
+	}
+
+	// Low balance alerted once crossing the threshold
+	s.signer = new(sdk.Account)
+	s.SetBalanceSource(&testBalance{balance: 10}, 50, 0)
+	s.checkBalance(s.checkBalance(false))
+
+	// Node quarantine
+	s.nodes = newNodeSelector(&config.NodeHealthConfig{ErrorRate: 0.5, Window: 2})
+	s.nodes.alert = s.alert
+	node := new(poly.Client)
+	for i := 0; i < 2; i++ {
+		s.nodes.Report(node, time.Millisecond, errors.New("http post request failed"))
+	}
+
+	// Header sync rollback on fork
+	s.sync = &config.HeaderSyncConfig{}
+	s.sdk = testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 100, nil
+		case "getheaderbyheight":
+			return fmt.Sprintf("%x", (&types.Header{}).ToArray()), nil
+		case "sendrawtransaction":
+			return nil, errors.New("parent header not exist")
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	s.signer = sdk.NewAccount()
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	if err := s.submitHeadersWithLoop(base.ETH, [][]byte{{1}}, nil); err != msg.ERR_HEADER_INCONSISTENT {
+		t.Fatalf("Expect rollback on fork, got %v", err)
+	}
+
+	expected := []string{
+		"error: Dropping src tx failed to submit to poly",
+		"warn: Poly signer balance low",
+		"warn: Quarantining unhealthy poly node",
+		"error: Header sync rolling back on possible fork",
+	}
+	if fmt.Sprint(alerter.alerts) != fmt.Sprint(expected) {
+		t.Fatalf("Expect alerts %v, got %v", expected, alerter.alerts)
+	}
+}
+
+func TestWebhookAlerter(t *testing.T) {
+	alerts := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alert := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&alert)
+		alerts <- alert
+	}))
+	defer server.Close()
+	s := new(Submitter)
+	s.alert(ALERT_WARN, "dropped", nil) // No-op without alerter
+	s.SetAlerter(NewWebhookAlerter(server.URL))
+	s.alert(ALERT_WARN, "Poly signer balance low", map[string]interface{}{"balance": 10})
+	alert := <-alerts
+	fields, _ := alert["fields"].(map[string]interface{})
+	if alert["level"] != ALERT_WARN || alert["msg"] != "Poly signer balance low" || fields["balance"] != float64(10) {
+		t.Fatalf("Unexpected alert posted %v", alert)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type BreakerState int
+
+const (
+	BREAKER_CLOSED BreakerState = iota
+	BREAKER_OPEN
+	BREAKER_HALF_OPEN
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BREAKER_OPEN:
+		return "open"
+	case BREAKER_HALF_OPEN:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Circuit breaker of poly node calls, opens after consecutive failures and lets a single
+// probe call through after the cooldown. A nil breaker allows all calls.
+type breaker struct {
+	sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     BreakerState
+	opened    time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func newBreaker(name string, threshold, cooldown int) *breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	duration := 30 * time.Second
+	if cooldown > 0 {
+		duration = time.Duration(cooldown) * time.Second
+	}
+	return &breaker{name: name, threshold: threshold, cooldown: duration, now: time.Now}
+}
+
+func (b *breaker) State() BreakerState {
+	if b == nil {
+		return BREAKER_CLOSED
+	}
+	b.Lock()
+	defer b.Unlock()
+	return b.state
+}
+
+// Check if a call can be made now, fails fast while the breaker is open
+func (b *breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.state == BREAKER_OPEN && b.now().Sub(b.opened) >= b.cooldown {
+		b.transit(BREAKER_HALF_OPEN)
+	}
+	switch b.state {
+	case BREAKER_OPEN:
+		return fmt.Errorf("%w, %s retry after %s", msg.ERR_BREAKER_OPEN, b.name, b.opened.Add(b.cooldown).Sub(b.now()))
+	case BREAKER_HALF_OPEN:
+		if b.probing {
+			return fmt.Errorf("%w, %s probing", msg.ERR_BREAKER_OPEN, b.name)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Report the result of an allowed call
+func (b *breaker) Done(ok bool) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.probing = false
+	if ok {
+		b.failures = 0
+		b.transit(BREAKER_CLOSED)
+		return
+	}
+	b.failures++
+	if b.state == BREAKER_HALF_OPEN || b.failures >= b.threshold {
+		b.opened = b.now()
+		b.transit(BREAKER_OPEN)
+	}
+}
+
+// Run the node call through the breaker
+func (b *breaker) Call(call func() error) error {
+	err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = call()
+	b.Done(!nodeFailure(err))
+	return err
+}
+
+func (b *breaker) transit(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	log.Warn("Poly node circuit breaker state changed", "name", b.name, "from", b.state, "to", state, "failures", b.failures)
+	b.state = state
+	record(int(state), "%s.poly_node_breaker", b.name)
+}
+
+// Whether the error tells the node is unreachable, errors returned by a responding node do not count
+func nodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	info := err.Error()
+	return strings.Contains(info, "http post request") ||
+		strings.Contains(info, "read rpc response body") ||
+		strings.Contains(info, "json.Unmarshal JsonRpcResponse")
+}
@@ -0,0 +1,76 @@
+package poly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	psdk "github.com/polynetwork/poly-go-sdk"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker("test", 3, 10)
+	clock := time.Unix(1600000000, 0)
+	b.now = func() time.Time { return clock }
+	down := errors.New("http post request: connection refused")
+	fail := func() error { return down }
+	pass := func() error { return nil }
+
+	// Opens after consecutive failures only
+	b.Call(fail)
+	b.Call(fail)
+	b.Call(pass)
+	for i := 0; i < 3; i++ {
+		b.Call(fail)
+	}
+	if b.State() != BREAKER_OPEN {
+		t.Fatalf("Expect breaker open, got %s", b.State())
+	}
+	called := false
+	err := b.Call(func() error { called = true; return nil })
+	if called || !errors.Is(err, msg.ERR_BREAKER_OPEN) {
+		t.Fatalf("Expect fail fast while open, got %v", err)
+	}
+
+	// Single probe after cooldown, failed probe opens again
+	clock = clock.Add(10 * time.Second)
+	if err = b.Allow(); err != nil || b.State() != BREAKER_HALF_OPEN {
+		t.Fatalf("Expect probe allowed when half open, got %v %s", err, b.State())
+	}
+	if err = b.Allow(); !errors.Is(err, msg.ERR_BREAKER_OPEN) {
+		t.Fatalf("Expect one probe at a time, got %v", err)
+	}
+	b.Done(false)
+	if b.State() != BREAKER_OPEN {
+		t.Fatalf("Expect breaker reopened on failed probe, got %s", b.State())
+	}
+
+	// Successful probe closes the breaker
+	clock = clock.Add(10 * time.Second)
+	if err = b.Call(pass); err != nil || b.State() != BREAKER_CLOSED {
+		t.Fatalf("Expect breaker closed on successful probe, got %v %s", err, b.State())
+	}
+
+	// Disabled breaker allows all calls
+	var nb *breaker
+	if nb = newBreaker("test", 0, 0); nb.Call(fail) != down || nb.State() != BREAKER_CLOSED {
+		t.Fatal("Expect disabled breaker to pass calls through")
+	}
+}
+
+func TestNodeFailure(t *testing.T) {
+	rpc := psdk.NewPolySdk()
+	rpc.NewRpcClient().SetAddress("http://127.0.0.1:1")
+	_, err := (&poly.Client{Rpc: rpc}).GetCurrentBlockHeight()
+	if !nodeFailure(err) {
+		t.Fatalf("Expect unreachable node as failure: %v", err)
+	}
+	node := testPolyNode(t, func(string, []interface{}) (interface{}, error) { return nil, errors.New("tx already done") })
+	_, err = node.GetCurrentBlockHeight()
+	if err == nil || nodeFailure(err) {
+		t.Fatalf("Expect node rpc error not as failure: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/poly/core/types"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+const (
+	COMPOSE_CACHE_SIZE = 1000
+	EPOCH_CACHE_TTL    = 30 * time.Second // Dst chain poly epoch start heights change only at epoch transitions
+)
+
+type proofKey struct {
+	height uint32
+	root   uint32
+}
+
+// Poly blocks are final once produced, so headers and merkle proofs by height can be
+// reused across txs sharing the same blocks and across retries of the same tx.
+type composeCache struct {
+	sync.Mutex
+	size    int
+	headers map[uint32]*types.Header
+	proofs  map[proofKey]string
+	order   []interface{} // Insertion order for eviction
+}
+
+func newComposeCache(size int) *composeCache {
+	if size <= 0 {
+		size = COMPOSE_CACHE_SIZE
+	}
+	return &composeCache{
+		size:    size,
+		headers: map[uint32]*types.Header{},
+		proofs:  map[proofKey]string{},
+	}
+}
+
+func (c *composeCache) header(height uint32) (hdr *types.Header, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	hdr, ok = c.headers[height]
+	return
+}
+
+func (c *composeCache) putHeader(height uint32, hdr *types.Header) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.headers[height]; ok {
+		return
+	}
+	c.headers[height] = hdr
+	c.push(height)
+}
+
+func (c *composeCache) proof(height, root uint32) (proof string, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	proof, ok = c.proofs[proofKey{height, root}]
+	return
+}
+
+func (c *composeCache) putProof(height, root uint32, proof string) {
+	c.Lock()
+	defer c.Unlock()
+	key := proofKey{height, root}
+	if _, ok := c.proofs[key]; ok {
+		return
+	}
+	c.proofs[key] = proof
+	c.push(key)
+}
+
+func (c *composeCache) push(key interface{}) {
+	c.order = append(c.order, key)
+	for len(c.order) > c.size {
+		switch k := c.order[0].(type) {
+		case uint32:
+			delete(c.headers, k)
+		case proofKey:
+			delete(c.proofs, k)
+		}
+		c.order = c.order[1:]
+	}
+}
+
+type proofEntry struct {
+	param     *ccom.ToMerkleValue
+	auditPath string
+	expire    time.Time
+}
+
+// Cross states proofs by height and key, the cached merkle values are shared and should not be modified
+type proofCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[string]proofEntry
+	sweep   time.Time
+	now     func() time.Time
+}
+
+func newProofCache(ttl time.Duration) *proofCache {
+	return &proofCache{ttl: ttl, entries: map[string]proofEntry{}, now: time.Now}
+}
+
+// Get the cached proof or fetch it when missing or expired
+func (c *proofCache) get(height uint32, key string, fetch func() (*ccom.ToMerkleValue, string, error)) (param *ccom.ToMerkleValue, auditPath string, err error) {
+	k := fmt.Sprintf("%d:%s", height, key)
+	c.Lock()
+	entry, ok := c.entries[k]
+	now := c.now()
+	c.Unlock()
+	if ok && now.Before(entry.expire) {
+		return entry.param, entry.auditPath, nil
+	}
+
+	param, auditPath, err = fetch()
+	if err != nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if now.After(c.sweep) {
+		for k, e := range c.entries {
+			if now.After(e.expire) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweep = now.Add(c.ttl)
+	}
+	c.entries[k] = proofEntry{param, auditPath, now.Add(c.ttl)}
+	return
+}
+
+type epochEntry struct {
+	height uint32
+	expire time.Time
+}
+
+// Dst chain poly epoch start heights by chain id
+type epochCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[uint64]epochEntry
+	now     func() time.Time
+}
+
+func newEpochCache(ttl time.Duration) *epochCache {
+	return &epochCache{ttl: ttl, entries: map[uint64]epochEntry{}, now: time.Now}
+}
+
+// Get the cached epoch start height or fetch it when missing or expired
+func (c *epochCache) get(chainId uint64, fetch func() (uint32, error)) (height uint32, err error) {
+	c.Lock()
+	entry, ok := c.entries[chainId]
+	now := c.now()
+	c.Unlock()
+	if ok && now.Before(entry.expire) {
+		return entry.height, nil
+	}
+	height, err = fetch()
+	if err != nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.entries[chainId] = epochEntry{height, now.Add(c.ttl)}
+	return
+}
+
+// Latest poly height shared by the callers within the ttl, so bursty callers send one node call per interval
+type heightCache struct {
+	sync.Mutex
+	ttl    time.Duration
+	height uint64
+	expire time.Time
+	now    func() time.Time
+}
+
+// Height cache with the ttl in milliseconds, nil to disable
+func newHeightCache(ttl int) *heightCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &heightCache{ttl: time.Duration(ttl) * time.Millisecond, now: time.Now}
+}
+
+// Get the cached height or fetch it when expired or forced, concurrent callers wait for the same fetch.
+// A nil cache always fetches.
+func (c *heightCache) get(force bool, fetch func() (uint64, error)) (uint64, error) {
+	if c == nil {
+		return fetch()
+	}
+	c.Lock()
+	defer c.Unlock()
+	now := c.now()
+	if !force && now.Before(c.expire) {
+		return c.height, nil
+	}
+	height, err := fetch()
+	if err != nil {
+		return 0, err
+	}
+	c.height, c.expire = height, now.Add(c.ttl)
+	return height, nil
+}
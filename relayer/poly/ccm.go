@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	"github.com/polynetwork/poly/native/service/cross_chain_manager"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+	"github.com/polynetwork/poly/native/service/utils"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Poly cross chain manager contract address in hex as in the tx notifies, the native contract unless configured,
+// for poly test networks or forks deploying the contract elsewhere
+func ccmAddress(contract string) string {
+	contract = strings.ToLower(strings.TrimPrefix(contract, "0x"))
+	if contract == "" {
+		return poly.CCM_ADDRESS
+	}
+	return contract
+}
+
+func checkCCMAddress(contract string) error {
+	_, err := pcom.AddressFromHexString(ccmAddress(contract))
+	if err != nil {
+		return fmt.Errorf("Invalid poly CCM contract %s, %v", contract, err)
+	}
+	return nil
+}
+
+// Unsigned ImportOuterTransfer tx of the composed src tx to the cross chain manager contract
+func newImportTx(node *poly.Client, contract string, tx *msg.Tx, account []byte) (*types.Transaction, error) {
+	address, err := pcom.AddressFromHexString(contract)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid poly CCM contract %s, %v", contract, err)
+	}
+	param := &ccom.EntranceParam{
+		SourceChainID:         tx.SrcChainId,
+		Height:                uint32(tx.SrcProofHeight),
+		Proof:                 tx.SrcProof,
+		RelayerAddress:        account,
+		Extra:                 tx.SrcEvent,
+		HeaderOrCrossChainMsg: tx.SrcStateRoot,
+	}
+	sink := pcom.NewZeroCopySink(nil)
+	param.Serialization(sink)
+	return node.Native.NewNativeInvokeTransaction(sdk.TX_VERSION, address, cross_chain_manager.IMPORT_OUTER_TRANSFER_NAME, sink.Bytes())
+}
+
+// Check the done tx record of the cross chain id in the poly cross chain manager contract
+func doneTx(node *poly.Client, contract string, srcChainId uint64, ccId []byte) (bool, error) {
+	key := append(append([]byte(ccom.DONE_TX), utils.GetUint64Bytes(srcChainId)...), ccId...)
+	data, err := node.GetStorage(contract, key)
+	if err != nil {
+		return false, err
+	}
+	return len(data) != 0, nil
+}
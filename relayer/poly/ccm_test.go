@@ -0,0 +1,79 @@
+package poly
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/core/types"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestCustomCCM(t *testing.T) {
+	useTestConfig(t)
+	const custom = "0a00000000000000000000000000000000000000"
+	value := &ccom.ToMerkleValue{FromChainID: 2, MakeTxParam: &ccom.MakeTxParam{Method: "unlock"}}
+	var storage string
+	polySDK := testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		case "getsmartcodeevent":
+			notify := []interface{}{}
+			for i, address := range []string{poly.CCM_ADDRESS, custom} {
+				notify = append(notify, map[string]interface{}{"ContractAddress": address,
+					"States": []interface{}{"makeProof", 2, 6, fmt.Sprintf("%04x", i), 100, fmt.Sprintf("key%d", i)}})
+			}
+			event := map[string]interface{}{"TxHash": fmt.Sprintf("%064x", 1), "State": 1, "Notify": notify}
+			if _, ok := params[0].(string); ok {
+				return event, nil
+			}
+			return []interface{}{event}, nil
+		case "getcrossstatesproof":
+			if params[1] != "key1" {
+				return nil, fmt.Errorf("unexpected proof key %v", params[1])
+			}
+			return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+		case "getstorage":
+			storage = params[0].(string)
+			return "", nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+
+	// Listener matches the notifies of the configured contract only
+	l := new(Listener)
+	if err := l.Init(&config.ListenerConfig{CCMContract: "0x" + custom}, polySDK); err != nil {
+		t.Fatal(err)
+	}
+	txs, err := l.Scan(100)
+	if err != nil || len(txs) != 1 || txs[0].TxId != "0001" {
+		t.Fatalf("Expect tx of the custom contract scanned, got %+v err %v", txs, err)
+	}
+
+	// Submitter fetches the proof and checks the done tx on the configured contract
+	s := &Submitter{sdk: polySDK, config: &config.PolySubmitterConfig{DryRun: true, CCMContract: custom}, signer: new(sdk.Account)}
+	param, _, _, err := s.GetPolyParams(&msg.Tx{PolyHash: "a", PolyHeight: 100})
+	if err != nil || param.FromChainID != 2 {
+		t.Fatalf("Expect merkle value of the custom contract notify, got %v", err)
+	}
+	s.composer = new(testComposer)
+	if err = s.submit(&msg.Tx{SrcChainId: base.ETH, SrcHash: "src"}); err != nil || storage != custom {
+		t.Fatalf("Expect done tx checked on the custom contract, got %s err %v", storage, err)
+	}
+	if _, err = newImportTx(polySDK.Node(), custom, &msg.Tx{SrcChainId: base.ETH}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = l.Init(&config.ListenerConfig{CCMContract: "xyz"}, polySDK); err == nil {
+		t.Fatal("Expect invalid contract rejected")
+	}
+}
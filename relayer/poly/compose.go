@@ -2,8 +2,10 @@ package poly
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -17,17 +19,27 @@ import (
 	scom "github.com/polynetwork/poly-go-sdk/common"
 	pcom "github.com/polynetwork/poly/common"
 	"github.com/polynetwork/poly/core/types"
+	"github.com/polynetwork/poly/merkle"
 	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
 
 	"github.com/polynetwork/poly-relayer/config"
 	"github.com/polynetwork/poly-relayer/msg"
 )
 
+// Fetch the cross states proof from the primary poly node
 func (s *Submitter) GetProof(height uint32, key string) (param *ccom.ToMerkleValue, auditPath string, evt *scom.SmartContactEvent, err error) {
-	return s.getProof(s.sdk.Node(), height, key)
+	if s.proofs == nil {
+		return s.GetProofFromNode(s.sdk.Node(), height, key)
+	}
+	param, auditPath, err = s.proofs.get(height, key, func() (*ccom.ToMerkleValue, string, error) {
+		value, path, _, err := s.GetProofFromNode(s.sdk.Node(), height, key)
+		return value, path, err
+	})
+	return
 }
 
-func (s *Submitter) getProof(node *poly.Client, height uint32, key string) (param *ccom.ToMerkleValue, auditPath string, evt *scom.SmartContactEvent, err error) {
+// Fetch and decode the cross states proof from the specified poly node
+func (s *Submitter) GetProofFromNode(node *poly.Client, height uint32, key string) (param *ccom.ToMerkleValue, auditPath string, evt *scom.SmartContactEvent, err error) {
 	proof, err := node.GetCrossStatesProof(height, key)
 	if err != nil {
 		err = fmt.Errorf("GetProof: GetCrossStatesProof key %s, error %v", key, err)
@@ -47,6 +59,29 @@ func (s *Submitter) getProof(node *poly.Client, height uint32, key string) (para
 	return
 }
 
+// Check the audit path decodes completely to the merkle value, so truncated paths are not sent to the dst chain
+func checkAuditPath(path string, param *ccom.ToMerkleValue) error {
+	raw, err := hex.DecodeString(path)
+	if err != nil {
+		return fmt.Errorf("%w, decode error %v", msg.ERR_AUDIT_PATH_INVALID, err)
+	}
+	value, pos, _, _ := msg.ParseAuditPath(raw)
+	sink := pcom.NewZeroCopySink(nil)
+	sink.WriteVarBytes(value)
+	if len(value) == 0 || int(sink.Size())+len(pos)*(pcom.UINT256_SIZE+1) != len(raw) {
+		return fmt.Errorf("%w, path of %d bytes truncated", msg.ERR_AUDIT_PATH_INVALID, len(raw))
+	}
+	if param == nil {
+		return fmt.Errorf("%w, merkle value missing", msg.ERR_AUDIT_PATH_INVALID)
+	}
+	expected := pcom.NewZeroCopySink(nil)
+	param.Serialization(expected)
+	if !bytes.Equal(value, expected.Bytes()) {
+		return fmt.Errorf("%w, path value differs from the merkle value", msg.ERR_AUDIT_PATH_INVALID)
+	}
+	return nil
+}
+
 func (s *Submitter) GetPolyParams(tx *msg.Tx) (param *ccom.ToMerkleValue, path string, evt *scom.SmartContactEvent, err error) {
 	if tx.PolyHash == "" {
 		err = fmt.Errorf("ComposeTx: Invalid poly hash")
@@ -69,23 +104,33 @@ func (s *Submitter) GetPolyParams(tx *msg.Tx) (param *ccom.ToMerkleValue, path s
 		return
 	}
 
+	// Proof fetch failures of the makeProof notify are returned for retry instead of reporting not found
+	var proofErr error
 	for _, notify := range evt.Notify {
-		if notify.ContractAddress == poly.CCM_ADDRESS {
+		if notify.ContractAddress == s.ccm() {
 			states := notify.States.([]interface{})
 			if len(states) > 5 {
 				method, _ := states[0].(string)
 				if method == "makeProof" {
-					param, path, evt, err = s.GetProof(tx.PolyHeight, states[5].(string))
+					param, path, _, err = s.GetProof(tx.PolyHeight, states[5].(string))
 					if err != nil {
-						log.Error("GetPolyParams: param.Deserialization error", "err", err)
+						log.Error("GetPolyParams: failed to fetch proof of makeProof notify", "poly_hash", tx.PolyHash, "err", err)
+						proofErr = err
 					} else {
+						PolyParamsOutcomes.Inc("found")
 						return
 					}
 				}
 			}
 		}
 	}
-	err = fmt.Errorf("Valid ToMerkleValue not found")
+	if proofErr != nil {
+		PolyParamsOutcomes.Inc("proof_error")
+		err = fmt.Errorf("GetPolyParams: fetch proof of poly tx %s error %w", tx.PolyHash, proofErr)
+		return
+	}
+	PolyParamsOutcomes.Inc("not_found")
+	err = fmt.Errorf("%w poly tx %s", msg.ERR_MERKLE_VALUE_MISSING, tx.PolyHash)
 	return
 }
 
@@ -99,19 +144,34 @@ func (s *Submitter) ComposeTx(tx *msg.Tx) (err error) {
 		}
 	*/
 
+	logger := s.txLog(tx)
+	logger.Debug("Composing poly tx", "dst_chain", tx.DstChainId, "poly_height", tx.PolyHeight)
+	ctx, span := s.startTxSpan(tx, "ComposeTx")
+	defer func() { endSpan(span, err) }()
+
 	if tx.PolyHeight == 0 {
 		tx.PolyHeight, err = s.sdk.Node().GetBlockHeightByTxHash(tx.PolyHash)
 		if err != nil {
 			return
 		}
 	}
-	tx.PolyHeader, err = s.sdk.Node().GetHeaderByHeight(tx.PolyHeight + 1)
+	_, fetch := s.startSpan(ctx, "GetHeader")
+	tx.PolyHeader, err = s.GetHeader(tx.PolyHeight + 1)
+	endSpan(fetch, err)
 	if err != nil {
 		return err
 	}
 
 	if tx.DstChainId != base.ONT {
-		err = s.ComposePolyHeaderProof(tx)
+		if tx.DstPolyEpochStartHeight == 0 && s.epochs != nil {
+			tx.DstPolyEpochStartHeight, err = s.epochs.get(tx.DstChainId, func() (uint32, error) {
+				return s.epochHeight(tx.DstChainId)
+			})
+			if err != nil {
+				return fmt.Errorf("Fetch dst chain %d poly epoch start height error %v", tx.DstChainId, err)
+			}
+		}
+		err = s.composePolyHeaderProof(ctx, tx)
 		if err != nil {
 			return
 		}
@@ -121,6 +181,14 @@ func (s *Submitter) ComposeTx(tx *msg.Tx) (err error) {
 	if err != nil {
 		return err
 	}
+	err = checkAuditPath(tx.AuditPath, tx.MerkleValue)
+	if err != nil {
+		return fmt.Errorf("ComposeTx: poly tx %s %w", tx.PolyHash, err)
+	}
+	tx.AuditPath, err = s.proofEncoder(tx.DstChainId).EncodeAuditPath(tx.AuditPath)
+	if err != nil {
+		return fmt.Errorf("Encode audit path for chain %d error %v", tx.DstChainId, err)
+	}
 
 	if tx.MerkleValue.MakeTxParam == nil || !config.CONFIG.AllowMethod(tx.MerkleValue.MakeTxParam.Method) {
 		method := "missing param"
@@ -132,6 +200,8 @@ func (s *Submitter) ComposeTx(tx *msg.Tx) (err error) {
 
 	tx.SrcProxy = common.BytesToAddress(tx.MerkleValue.MakeTxParam.FromContractAddress).String()
 	tx.DstProxy = common.BytesToAddress(tx.MerkleValue.MakeTxParam.ToContractAddress).String()
+	logger.Debug("Composed poly tx", "dst_chain", tx.DstChainId, "poly_height", tx.PolyHeight,
+		"anchored", tx.AnchorHeader != nil, "src_proxy", tx.SrcProxy, "dst_proxy", tx.DstProxy)
 
 	if tx.DstChainId != base.ONT {
 		return s.CollectSigs(tx)
@@ -140,42 +210,188 @@ func (s *Submitter) ComposeTx(tx *msg.Tx) (err error) {
 }
 
 func (s *Submitter) ComposePolyHeaderProof(tx *msg.Tx) (err error) {
-	var anchorHeight uint32
-	if tx.PolyHeight < tx.DstPolyEpochStartHeight {
-		anchorHeight = tx.DstPolyEpochStartHeight + 1
-	} else {
-		isEpoch, _, err := s.CheckEpoch(tx, tx.PolyHeader)
-		if err != nil {
-			return err
-		}
-		if isEpoch {
-			anchorHeight = tx.PolyHeight + 2
+	return s.composePolyHeaderProof(context.Background(), tx)
+}
+
+func (s *Submitter) composePolyHeaderProof(ctx context.Context, tx *msg.Tx) (err error) {
+	if reusableAnchor(tx) {
+		s.txLog(tx).Debug("Reusing anchor proof of prior compose", "anchor_height", tx.AnchorHeader.Height)
+		return
+	}
+	tx.AnchorHeader, tx.AnchorProof = nil, ""
+
+	anchorHeight, err := anchorHeight(tx, s.config.AnchorOffset(tx.DstChainId), func() (epoch bool, err error) {
+		if !epochSwitch(tx.PolyHeader) {
+			// The poly header keeps the keepers of its parent, no dst keepers or payload decoding needed
+			return false, nil
 		}
+		_, span := s.startSpan(ctx, "CheckEpoch")
+		epoch, err = s.checkEpoch(tx)
+		endSpan(span, err)
+		return
+	})
+	if err != nil {
+		return
 	}
 
 	if anchorHeight > 0 {
-		tx.AnchorHeader, err = s.sdk.Node().GetHeaderByHeight(anchorHeight)
+		_, span := s.startSpan(ctx, "GetHeader")
+		tx.AnchorHeader, err = s.GetHeader(anchorHeight)
+		endSpan(span, err)
 		if err != nil {
 			return err
 		}
-		proof, err := s.sdk.Node().GetMerkleProof(tx.PolyHeight+1, anchorHeight)
+		_, span = s.startSpan(ctx, "GetMerkleProof")
+		tx.AnchorProof, err = s.GetMerkleProof(tx.PolyHeight+1, anchorHeight)
+		endSpan(span, err)
 		if err != nil {
 			return err
 		}
-		tx.AnchorProof = proof.AuditPath
 	}
 	return
 }
 
+// Whether the poly header switches the poly keepers, only such headers can change the epoch
+func epochSwitch(hdr *types.Header) bool {
+	return hdr == nil || hdr.NextBookkeeper != pcom.ADDRESS_EMPTY
+}
+
+// Check the anchor of a prior compose still proves the poly header, so retries skip fetching it again.
+// The anchor should be above the poly header, after the dst epoch start and at the forced height if any.
+func reusableAnchor(tx *msg.Tx) bool {
+	if tx.AnchorHeader == nil || tx.AnchorProof == "" || tx.PolyHeader == nil {
+		return false
+	}
+	height := tx.AnchorHeader.Height
+	if height <= tx.PolyHeight+1 || height <= tx.DstPolyEpochStartHeight {
+		return false
+	}
+	if tx.ForceAnchorHeight > 0 && height != tx.ForceAnchorHeight {
+		return false
+	}
+	proof, err := hex.DecodeString(tx.AnchorProof)
+	if err != nil {
+		return false
+	}
+	value, err := merkle.MerkleProve(proof, tx.AnchorHeader.BlockRoot[:])
+	if err != nil {
+		return false
+	}
+	hash := tx.PolyHeader.Hash()
+	return bytes.Equal(value, hash[:])
+}
+
+// AnchorHeight decides the anchor header height for the dst chain to verify the poly header against,
+// zero if the poly header can be verified with the dst chain keepers directly.
+func AnchorHeight(tx *msg.Tx, isEpoch func() (bool, error)) (height uint32, err error) {
+	offset := config.AnchorOffset{Epoch: config.ANCHOR_EPOCH_OFFSET, EpochStart: config.ANCHOR_EPOCH_START_OFFSET}
+	return anchorHeight(tx, offset, isEpoch)
+}
+
+func anchorHeight(tx *msg.Tx, offset config.AnchorOffset, isEpoch func() (bool, error)) (height uint32, err error) {
+	if tx.ForceAnchorHeight > 0 {
+		// Anchor header root should cover the poly header at PolyHeight+1
+		if tx.ForceAnchorHeight <= tx.PolyHeight+1 {
+			return 0, fmt.Errorf("Forced anchor height %d should be above poly header height %d", tx.ForceAnchorHeight, tx.PolyHeight+1)
+		}
+		return tx.ForceAnchorHeight, nil
+	}
+	if err = offset.Validate(); err != nil {
+		return
+	}
+	if tx.PolyHeight < tx.DstPolyEpochStartHeight {
+		return tx.DstPolyEpochStartHeight + offset.EpochStart, nil
+	}
+	epoch, err := isEpoch()
+	if err != nil {
+		return
+	}
+	if epoch {
+		height = tx.PolyHeight + offset.Epoch
+	}
+	return
+}
+
+// Get poly header by height with cache
+func (s *Submitter) GetHeader(height uint32) (hdr *types.Header, err error) {
+	hdr, ok := s.cache().header(height)
+	if ok {
+		return
+	}
+	hdr, err = s.sdk.Node().GetHeaderByHeight(height)
+	if err == nil && hdr != nil {
+		s.cache().putHeader(height, hdr)
+	}
+	return
+}
+
+// Get poly merkle proof audit path of block height against root height with cache
+func (s *Submitter) GetMerkleProof(height, root uint32) (path string, err error) {
+	path, ok := s.cache().proof(height, root)
+	if ok {
+		return
+	}
+	proof, err := s.sdk.Node().GetMerkleProof(height, root)
+	if err != nil {
+		return
+	}
+	path = proof.AuditPath
+	s.cache().putProof(height, root, path)
+	return
+}
+
+func (s *Submitter) cache() *composeCache {
+	s.cacheOnce.Do(func() {
+		if s.composeCache == nil {
+			size := 0
+			if s.config != nil {
+				size = s.config.ComposeCache
+			}
+			s.composeCache = newComposeCache(size)
+		}
+	})
+	return s.composeCache
+}
+
+// Set the resolver to fetch dst chain poly keepers when they are not provided with the tx
+func (s *Submitter) SetKeepersResolver(resolver func(dstChainId uint64) ([]byte, error)) {
+	s.keepers = resolver
+}
+
+// Set the resolver to fetch the dst chain poly epoch start height when it is not provided with the tx,
+// e.g. from the dst chain CCD contract. Resolved heights are cached per dst chain for EPOCH_CACHE_TTL.
+func (s *Submitter) SetEpochResolver(resolver func(dstChainId uint64) (uint32, error)) {
+	s.epochHeight = resolver
+	s.epochs = newEpochCache(EPOCH_CACHE_TTL)
+}
+
+// Check epoch change against dst chain keepers, fetching the keepers lazily when missing
+func (s *Submitter) checkEpoch(tx *msg.Tx) (epoch bool, err error) {
+	epoch, _, err = s.CheckEpoch(tx, tx.PolyHeader)
+	if !errors.Is(err, msg.ERR_EPOCH_KEEPERS_MISSING) {
+		return
+	}
+	if s.keepers == nil {
+		log.Warn("Dst chain poly keepers not provided, assuming no epoch change", "poly_hash", tx.PolyHash, "dst_chain", tx.DstChainId)
+		return false, nil
+	}
+	tx.DstPolyKeepers, err = s.keepers(tx.DstChainId)
+	if err != nil {
+		return false, fmt.Errorf("Fetch dst chain %d poly keepers error %v", tx.DstChainId, err)
+	}
+	epoch, _, err = s.CheckEpoch(tx, tx.PolyHeader)
+	return
+}
+
 func (s *Submitter) CheckEpoch(tx *msg.Tx, hdr *types.Header) (epoch bool, pubKeys []byte, err error) {
 	if tx.DstChainId == base.NEO {
 		return
 	}
-	if len(tx.DstPolyKeepers) == 0 {
-		// err = fmt.Errorf("Dst chain poly keeper not provided")
+	if hdr.NextBookkeeper == pcom.ADDRESS_EMPTY {
 		return
 	}
-	if hdr.NextBookkeeper == pcom.ADDRESS_EMPTY {
+	if len(tx.DstPolyKeepers) == 0 {
+		err = fmt.Errorf("%w, dst chain %d poly height %d", msg.ERR_EPOCH_KEEPERS_MISSING, tx.DstChainId, hdr.Height)
 		return
 	}
 	info := &vconf.VbftBlockInfo{}
@@ -0,0 +1,753 @@
+package poly
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	"github.com/polynetwork/poly/merkle"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestAnchorHeight(t *testing.T) {
+	epoch := func(v bool) func() (bool, error) {
+		return func() (bool, error) { return v, nil }
+	}
+	cases := []struct {
+		polyHeight, dstStart uint32
+		epoch                bool
+		anchor               uint32
+	}{
+		{100, 200, false, 201}, // Dst keepers ahead of the tx height
+		{100, 200, true, 201},
+		{200, 200, false, 0},
+		{200, 200, true, 202}, // Epoch switch right at the tx block
+		{300, 200, false, 0},
+		{300, 200, true, 302},
+	}
+	for i, c := range cases {
+		tx := &msg.Tx{PolyHeight: c.polyHeight, DstPolyEpochStartHeight: c.dstStart}
+		anchor, err := AnchorHeight(tx, epoch(c.epoch))
+		if err != nil || anchor != c.anchor {
+			t.Fatalf("Case %d expect anchor %v, got %v err %v", i, c.anchor, anchor, err)
+		}
+	}
+
+	_, err := AnchorHeight(&msg.Tx{PolyHeight: 300}, func() (bool, error) { return false, fmt.Errorf("bad payload") })
+	if err == nil {
+		t.Fatalf("Expect epoch check error")
+	}
+
+	// Forced anchor skips the derivation
+	unexpected := func() (bool, error) { return false, fmt.Errorf("epoch checked") }
+	anchor, err := AnchorHeight(&msg.Tx{PolyHeight: 100, DstPolyEpochStartHeight: 200, ForceAnchorHeight: 150}, unexpected)
+	if err != nil || anchor != 150 {
+		t.Fatalf("Expect forced anchor, got %v err %v", anchor, err)
+	}
+	for _, height := range []uint32{50, 100, 101} {
+		_, err = AnchorHeight(&msg.Tx{PolyHeight: 100, ForceAnchorHeight: height}, unexpected)
+		if err == nil || err.Error() == "epoch checked" {
+			t.Fatalf("Expect forced anchor %d rejected, got %v", height, err)
+		}
+	}
+}
+
+func TestComposeCache(t *testing.T) {
+	c := newComposeCache(3)
+	for h := uint32(1); h <= 3; h++ {
+		c.putHeader(h, &types.Header{Height: h})
+	}
+	if hdr, ok := c.header(2); !ok || hdr.Height != 2 {
+		t.Fatalf("Expect cached header")
+	}
+	c.putProof(1, 3, "path")
+	if _, ok := c.header(1); ok {
+		t.Fatalf("Expect oldest header evicted")
+	}
+	if path, ok := c.proof(1, 3); !ok || path != "path" {
+		t.Fatalf("Expect cached proof")
+	}
+	if _, ok := c.proof(1, 4); ok {
+		t.Fatalf("Unexpected proof for another root")
+	}
+}
+
+func TestGetHeaderCache(t *testing.T) {
+	var fetches int64
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{ComposeCache: 2},
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 1000, nil
+			case "getheaderbyheight":
+				atomic.AddInt64(&fetches, 1)
+				return hex.EncodeToString((&types.Header{Height: uint32(params[0].(float64))}).ToArray()), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	atomic.StoreInt64(&fetches, 0)
+
+	// Txs of the same poly block share the header
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if hdr, err := s.GetHeader(101); err != nil || hdr.Height != 101 {
+				t.Errorf("Unexpected header %v err %v", hdr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt64(&fetches); n < 1 || n > 8 {
+		t.Fatalf("Unexpected header fetches %d", n)
+	}
+	atomic.StoreInt64(&fetches, 0)
+	s.GetHeader(101)
+	if atomic.LoadInt64(&fetches) != 0 {
+		t.Fatal("Expect cached header reused")
+	}
+
+	// Configured size bounds the cache
+	s.GetHeader(102)
+	s.GetHeader(103)
+	s.GetHeader(101)
+	if n := atomic.LoadInt64(&fetches); n != 3 {
+		t.Fatalf("Expect evicted header fetched again, got %d fetches", n)
+	}
+}
+
+func BenchmarkComposeCache(b *testing.B) {
+	c := newComposeCache(COMPOSE_CACHE_SIZE)
+	hdr := &types.Header{}
+	for i := 0; i < b.N; i++ {
+		h := uint32(i % 2000)
+		if _, ok := c.header(h); !ok {
+			c.putHeader(h, hdr)
+		}
+	}
+}
+
+func TestCheckEpochKeepersMissing(t *testing.T) {
+	s := &Submitter{}
+	tx := &msg.Tx{DstChainId: 2, PolyHeight: 100}
+	hdr := &types.Header{Height: 101, NextBookkeeper: pcom.Address{1}, ConsensusPayload: []byte("{")}
+
+	// No keepers needed if the header does not switch epoch
+	epoch, _, err := s.CheckEpoch(tx, &types.Header{Height: 101})
+	if epoch || err != nil {
+		t.Fatalf("Unexpected epoch check result %v %v", epoch, err)
+	}
+
+	_, _, err = s.CheckEpoch(tx, hdr)
+	if !errors.Is(err, msg.ERR_EPOCH_KEEPERS_MISSING) {
+		t.Fatalf("Expect keepers missing error, got %v", err)
+	}
+
+	// Without resolver, keep composing as no epoch change
+	tx.PolyHeader = hdr
+	epoch, err = s.checkEpoch(tx)
+	if epoch || err != nil {
+		t.Fatalf("Unexpected epoch check result %v %v", epoch, err)
+	}
+
+	s.SetKeepersResolver(func(chain uint64) ([]byte, error) { return []byte{byte(chain)}, nil })
+	_, err = s.checkEpoch(tx)
+	if len(tx.DstPolyKeepers) != 1 || tx.DstPolyKeepers[0] != 2 {
+		t.Fatalf("Expect dst keepers populated by resolver, got %x", tx.DstPolyKeepers)
+	}
+	if err == nil || errors.Is(err, msg.ERR_EPOCH_KEEPERS_MISSING) {
+		t.Fatalf("Expect epoch check to proceed with resolved keepers, got %v", err)
+	}
+}
+
+func TestComposeEpochFastPath(t *testing.T) {
+	s := &Submitter{}
+	var resolved int
+	s.SetKeepersResolver(func(chain uint64) ([]byte, error) {
+		resolved++
+		return []byte{1}, nil
+	})
+
+	// Headers not switching the keepers need no epoch check
+	tx := &msg.Tx{DstChainId: base.ETH, PolyHeight: 300, DstPolyEpochStartHeight: 200, PolyHeader: &types.Header{Height: 301}}
+	if err := s.composePolyHeaderProof(context.Background(), tx); err != nil || tx.AnchorHeader != nil || resolved != 0 {
+		t.Fatalf("Expect no anchor nor keepers lookup, got anchor %v keepers lookups %d err %v", tx.AnchorHeader, resolved, err)
+	}
+
+	// The epoch is checked at the switching header
+	tx.PolyHeader = &types.Header{Height: 301, NextBookkeeper: pcom.Address{1}, ConsensusPayload: []byte("{")}
+	if err := s.composePolyHeaderProof(context.Background(), tx); err == nil || resolved != 1 {
+		t.Fatalf("Expect epoch checked with the dst keepers, got keepers lookups %d err %v", resolved, err)
+	}
+}
+
+func TestProofCache(t *testing.T) {
+	c := newProofCache(time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	fetch := func() (*ccom.ToMerkleValue, string, error) {
+		calls++
+		return &ccom.ToMerkleValue{TxHash: []byte{byte(calls)}, FromChainID: 2}, fmt.Sprintf("path%d", calls), nil
+	}
+	fresh, freshPath, err := c.get(100, "key", fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, cachedPath, err := c.get(100, "key", fetch)
+	if err != nil || calls != 1 {
+		t.Fatalf("Expect cached proof, fetched %d times, err %v", calls, err)
+	}
+	if !reflect.DeepEqual(fresh, cached) || freshPath != cachedPath {
+		t.Fatalf("Cached proof differs from fresh one")
+	}
+
+	c.get(101, "key", fetch)
+	c.get(100, "other", fetch)
+	if calls != 3 {
+		t.Fatalf("Expect proofs cached by height and key, fetched %d times", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	_, path, _ := c.get(100, "key", fetch)
+	if calls != 4 || path != "path4" {
+		t.Fatalf("Expect expired proof refetched, fetched %d times", calls)
+	}
+	if len(c.entries) != 1 {
+		t.Fatalf("Expired proofs not swept, size %d", len(c.entries))
+	}
+
+	fail := errors.New("rpc")
+	_, _, err = c.get(200, "key", func() (*ccom.ToMerkleValue, string, error) { return nil, "", fail })
+	if !errors.Is(err, fail) {
+		t.Fatalf("Expect fetch error, got %v", err)
+	}
+	if _, ok := c.entries["200:key"]; ok {
+		t.Fatal("Failed fetch should not be cached")
+	}
+}
+
+func BenchmarkProofCache(b *testing.B) {
+	c := newProofCache(time.Minute)
+	fetch := func() (*ccom.ToMerkleValue, string, error) { return new(ccom.ToMerkleValue), "", nil }
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.get(uint32(i%COMPOSE_CACHE_SIZE), "key", fetch)
+			i++
+		}
+	})
+}
+
+func TestGetProofFromNode(t *testing.T) {
+	value := func(chain uint64) *ccom.ToMerkleValue {
+		return &ccom.ToMerkleValue{
+			TxHash:      []byte{1},
+			FromChainID: chain,
+			MakeTxParam: &ccom.MakeTxParam{ToContractAddress: []byte{2}, Method: "unlock"},
+		}
+	}
+	var queried []interface{}
+	a := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		queried = params
+		return map[string]string{"AuditPath": testAuditPath(value(2))}, nil
+	})
+	b := testProofNode(t, value(6))
+	down := testPolyNode(t, func(string, []interface{}) (interface{}, error) { return nil, errors.New("unavailable") })
+
+	s := new(Submitter)
+	param, _, _, err := s.GetProofFromNode(a, 100, "key")
+	if err != nil || param.FromChainID != 2 || param.MakeTxParam.Method != "unlock" {
+		t.Fatalf("Unexpected proof from node a %+v %v", param, err)
+	}
+	if len(queried) != 2 || queried[0] != float64(100) || queried[1] != "key" {
+		t.Fatalf("Unexpected proof query %v", queried)
+	}
+	param, _, _, err = s.GetProofFromNode(b, 100, "key")
+	if err != nil || param.FromChainID != 6 {
+		t.Fatalf("Unexpected proof from node b %+v %v", param, err)
+	}
+	if _, _, _, err = s.GetProofFromNode(down, 100, "key"); err == nil {
+		t.Fatal("Expect proof error from unavailable node")
+	}
+}
+
+func TestGetPolyParams(t *testing.T) {
+	value := &ccom.ToMerkleValue{FromChainID: 2, MakeTxParam: &ccom.MakeTxParam{Method: "unlock"}}
+	var notifies []interface{}
+	proofUp := true
+	s := &Submitter{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			hdr := &types.Header{Height: uint32(params[0].(float64))}
+			return hex.EncodeToString(hdr.ToArray()), nil
+		case "getsmartcodeevent":
+			return map[string]interface{}{"TxHash": params[0], "State": 1, "Notify": notifies}, nil
+		case "getcrossstatesproof":
+			if !proofUp {
+				return nil, errors.New("proof unavailable")
+			}
+			return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+	makeProof := map[string]interface{}{
+		"ContractAddress": poly.CCM_ADDRESS,
+		"States":          []interface{}{"makeProof", "", "", "", "", "key"},
+	}
+	other := map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS, "States": []interface{}{"btcTxToRelay"}}
+
+	notifies = []interface{}{other}
+	notFound := PolyParamsOutcomes.Count("not_found")
+	_, _, _, err := s.GetPolyParams(&msg.Tx{PolyHash: "a", PolyHeight: 100})
+	if !errors.Is(err, msg.ERR_MERKLE_VALUE_MISSING) || PolyParamsOutcomes.Count("not_found") != notFound+1 {
+		t.Fatalf("Expect merkle value missing without makeProof notify, got %v", err)
+	}
+
+	notifies = []interface{}{other, makeProof}
+	proofUp = false
+	proofErrors := PolyParamsOutcomes.Count("proof_error")
+	_, _, _, err = s.GetPolyParams(&msg.Tx{PolyHash: "b", PolyHeight: 100})
+	if err == nil || errors.Is(err, msg.ERR_MERKLE_VALUE_MISSING) || PolyParamsOutcomes.Count("proof_error") != proofErrors+1 {
+		t.Fatalf("Expect transient proof error, got %v", err)
+	}
+
+	proofUp = true
+	found := PolyParamsOutcomes.Count("found")
+	param, _, _, err := s.GetPolyParams(&msg.Tx{PolyHash: "c", PolyHeight: 100})
+	if err != nil || param.FromChainID != 2 || PolyParamsOutcomes.Count("found") != found+1 {
+		t.Fatalf("Unexpected merkle value %+v %v", param, err)
+	}
+}
+
+func TestCheckAuditPath(t *testing.T) {
+	value := &ccom.ToMerkleValue{FromChainID: 2, MakeTxParam: &ccom.MakeTxParam{Method: "unlock"}}
+	sink := pcom.NewZeroCopySink(nil)
+	value.Serialization(sink)
+	path := pcom.NewZeroCopySink(nil)
+	path.WriteVarBytes(sink.Bytes())
+	for i := 0; i < 2; i++ {
+		path.WriteByte(byte(i))
+		path.WriteHash(pcom.Uint256{byte(i + 1)})
+	}
+	raw := path.Bytes()
+
+	if err := checkAuditPath(hex.EncodeToString(raw), value); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkAuditPath(testAuditPath(value), value); err != nil {
+		t.Fatalf("Expect path without hashes valid, got %v", err)
+	}
+	for _, size := range []int{len(raw) - 1, len(raw) - 34, 3, 0} {
+		if err := checkAuditPath(hex.EncodeToString(raw[:size]), value); !errors.Is(err, msg.ERR_AUDIT_PATH_INVALID) {
+			t.Fatalf("Expect invalid path truncated to %d bytes, got %v", size, err)
+		}
+	}
+	other := &ccom.ToMerkleValue{FromChainID: 3, MakeTxParam: &ccom.MakeTxParam{Method: "unlock"}}
+	if err := checkAuditPath(hex.EncodeToString(raw), other); !errors.Is(err, msg.ERR_AUDIT_PATH_INVALID) {
+		t.Fatalf("Expect merkle value mismatch, got %v", err)
+	}
+}
+
+func TestCollectSigs(t *testing.T) {
+	hdr := &types.Header{Height: 100}
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	var signers []common.Address
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		sig, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Ontology secp256k1 sig format: scheme, v + 27, r, s
+		data := append([]byte{byte(signature.SHA256withECDSA), sig[64] + 27}, sig[:64]...)
+		hdr.SigData = append(hdr.SigData, data)
+		hdr.Bookkeepers = append(hdr.Bookkeepers, &key.PublicKey)
+		signers = append(signers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	recovered := func(sigs []byte) (list []common.Address) {
+		for i := 0; i < len(sigs); i += 65 {
+			pub, err := crypto.SigToPub(digest[:], sigs[i:i+65])
+			if err != nil {
+				t.Fatal(err)
+			}
+			list = append(list, crypto.PubkeyToAddress(*pub))
+		}
+		return
+	}
+
+	s := &Submitter{config: &config.PolySubmitterConfig{SortedSigChains: []uint64{2}}}
+	tx := &msg.Tx{DstChainId: 6, PolyHeader: hdr}
+	if err := s.CollectSigs(tx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recovered(tx.PolySigs), signers) {
+		t.Fatal("Expect sigs in header order")
+	}
+
+	tx = &msg.Tx{DstChainId: 2, PolyHeader: hdr}
+	if err := s.CollectSigs(tx); err != nil {
+		t.Fatal(err)
+	}
+	list := recovered(tx.PolySigs)
+	if len(list) != 4 || !sort.SliceIsSorted(list, func(i, j int) bool { return bytes.Compare(list[i][:], list[j][:]) < 0 }) {
+		t.Fatalf("Expect sigs sorted by signer, got %v", list)
+	}
+
+	// 4 bookkeepers require 3 sigs
+	hdr.SigData = hdr.SigData[:3]
+	if err := s.CollectSigs(&msg.Tx{PolyHeader: hdr}); err != nil {
+		t.Fatal(err)
+	}
+	hdr.SigData = hdr.SigData[:2]
+	if err := s.CollectSigs(&msg.Tx{PolyHeader: hdr}); !errors.Is(err, msg.ERR_INSUFFICIENT_SIGS) {
+		t.Fatalf("Expect insufficient sigs error, got %v", err)
+	}
+}
+
+func TestCollectSigsDedup(t *testing.T) {
+	hdr := &types.Header{Height: 100}
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	var (
+		sigs    [][]byte
+		signers []common.Address
+	)
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		sig, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs = append(sigs, append([]byte{byte(signature.SHA256withECDSA), sig[64] + 27}, sig[:64]...))
+		hdr.Bookkeepers = append(hdr.Bookkeepers, &key.PublicKey)
+		signers = append(signers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+
+	s := &Submitter{}
+	hdr.SigData = [][]byte{sigs[0], sigs[1], sigs[1], sigs[2], sigs[0], sigs[3]}
+	tx := &msg.Tx{PolyHeader: hdr}
+	if err := s.CollectSigs(tx); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.PolySigs) != 4*65 {
+		t.Fatalf("Expect 4 sigs after dedup, got %d bytes", len(tx.PolySigs))
+	}
+	for i, signer := range signers {
+		pub, err := crypto.SigToPub(digest[:], tx.PolySigs[i*65:(i+1)*65])
+		if err != nil || crypto.PubkeyToAddress(*pub) != signer {
+			t.Fatalf("Expect sig %d from signer %s in header order", i, signer.Hex())
+		}
+	}
+
+	// Duplicates do not count towards the required sigs
+	hdr.SigData = [][]byte{sigs[0], sigs[0], sigs[1]}
+	if err := s.CollectSigs(&msg.Tx{PolyHeader: hdr}); !errors.Is(err, msg.ERR_INSUFFICIENT_SIGS) {
+		t.Fatalf("Expect insufficient sigs error, got %v", err)
+	}
+}
+
+func TestCollectSigsEmpty(t *testing.T) {
+	s := &Submitter{}
+	empty := &types.Header{Height: 100}
+	if err := s.CollectSigs(&msg.Tx{PolyHeader: empty}); !errors.Is(err, msg.ERR_EMPTY_SIGDATA) {
+		t.Fatalf("Expect empty sig data error, got %v", err)
+	}
+
+	// The anchor header signs the proof when anchored
+	signed := new(types.Header)
+	raw, _ := hex.DecodeString(testSignedHeader(t, 101))
+	if err := signed.Deserialization(pcom.NewZeroCopySource(raw)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CollectSigs(&msg.Tx{PolyHeader: signed}); err != nil {
+		t.Fatal(err)
+	}
+	tx := &msg.Tx{PolyHeader: signed, AnchorHeader: &types.Header{Height: 200}, AnchorProof: "proof"}
+	if err := s.CollectSigs(tx); !errors.Is(err, msg.ERR_EMPTY_SIGDATA) || !strings.Contains(err.Error(), "200") {
+		t.Fatalf("Expect empty sig data error of the anchor header, got %v", err)
+	}
+}
+
+func TestCollectSigsEncoding(t *testing.T) {
+	hdr := &types.Header{Height: 100}
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	var recids []byte
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		sig, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr.SigData = append(hdr.SigData, append([]byte{byte(signature.SHA256withECDSA), sig[64] + 27}, sig[:64]...))
+		recids = append(recids, sig[64])
+	}
+	s := &Submitter{config: &config.PolySubmitterConfig{SigEncodings: map[uint64]*config.SigEncoding{
+		2: {Scheme: config.SIG_V_EIP155, ChainId: 10},
+		6: {Scheme: config.SIG_V_LEGACY},
+		7: {Scheme: config.SIG_V_EIP155, ChainId: 200},
+	}}}
+	for chain, offset := range map[uint64]byte{3: 0, 6: 27, 2: 55} {
+		tx := &msg.Tx{DstChainId: chain, PolyHeader: hdr}
+		if err := s.CollectSigs(tx); err != nil {
+			t.Fatal(err)
+		}
+		for i, recid := range recids {
+			if v := tx.PolySigs[i*65+64]; v != recid+offset {
+				t.Fatalf("Chain %d expect sig %d v %d, got %d", chain, i, recid+offset, v)
+			}
+		}
+	}
+	if err := s.CollectSigs(&msg.Tx{DstChainId: 7, PolyHeader: hdr}); err == nil {
+		t.Fatal("Expect error on v value overflow")
+	}
+}
+
+// Poly header signed by the count of bookkeepers
+func testSigHeader(tb testing.TB, height uint32, count int) *types.Header {
+	hdr := &types.Header{Height: height}
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	for i := 0; i < count; i++ {
+		key, _ := crypto.GenerateKey()
+		sig, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		hdr.SigData = append(hdr.SigData, append([]byte{byte(signature.SHA256withECDSA), sig[64] + 27}, sig[:64]...))
+		hdr.Bookkeepers = append(hdr.Bookkeepers, &key.PublicKey)
+	}
+	return hdr
+}
+
+func TestCollectSigsBatch(t *testing.T) {
+	shared, other := testSigHeader(t, 100, 4), testSigHeader(t, 200, 4)
+	s := &Submitter{config: &config.PolySubmitterConfig{
+		SortedSigChains: []uint64{2},
+		SigEncodings:    map[uint64]*config.SigEncoding{6: {Scheme: config.SIG_V_LEGACY}},
+	}}
+	txs := func() []*msg.Tx {
+		return []*msg.Tx{
+			{PolyHash: "a", DstChainId: 2, PolyHeader: shared},
+			{PolyHash: "b", DstChainId: 6, PolyHeader: shared},
+			{PolyHash: "c", DstChainId: 3, PolyHeader: other},
+			{PolyHash: "d", DstChainId: 2, PolyHeader: other, AnchorHeader: shared, AnchorProof: "proof"},
+			{PolyHash: "e", DstChainId: 3, PolyHeader: shared},
+		}
+	}
+	single, batch := txs(), txs()
+	for _, tx := range single {
+		if err := s.CollectSigs(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CollectSigsBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i := range single {
+		if !bytes.Equal(single[i].PolySigs, batch[i].PolySigs) {
+			t.Fatalf("Batch sigs of tx %s differ from the per tx ones", single[i].PolyHash)
+		}
+	}
+
+	// Batch stops at the first tx failed
+	batch = txs()
+	batch[2].PolyHeader = &types.Header{Height: 300}
+	if err := s.CollectSigsBatch(batch); !errors.Is(err, msg.ERR_EMPTY_SIGDATA) || !strings.Contains(err.Error(), "poly tx c ") {
+		t.Fatalf("Expect empty sig data of tx c, got %v", err)
+	}
+	if batch[1].PolySigs == nil || batch[3].PolySigs != nil {
+		t.Fatal("Expect sigs collected till the failed tx")
+	}
+}
+
+func BenchmarkCollectSigs(b *testing.B) {
+	hdr := testSigHeader(b, 100, 7)
+	s := &Submitter{config: &config.PolySubmitterConfig{SortedSigChains: []uint64{2}}}
+	txs := make([]*msg.Tx, 50)
+	for i := range txs {
+		txs[i] = &msg.Tx{DstChainId: 2, PolyHeader: hdr}
+	}
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, tx := range txs {
+				if err := s.CollectSigs(tx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := s.CollectSigsBatch(txs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestEpochResolver(t *testing.T) {
+	useTestConfig(t)
+	value := &ccom.ToMerkleValue{MakeTxParam: &ccom.MakeTxParam{Method: "unlock"}}
+	s := &Submitter{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return testSignedHeader(t, uint32(params[0].(float64))), nil
+		case "getcrossstatesproof", "getmerkleproof":
+			return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+	calls := map[uint64]int{}
+	s.SetEpochResolver(func(chainId uint64) (uint32, error) {
+		calls[chainId]++
+		if chainId == 7 {
+			return 0, errors.New("dst node down")
+		}
+		return 200, nil
+	})
+	now := time.Now()
+	s.epochs.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		tx := &msg.Tx{PolyHash: "a", PolyKey: "key", PolyHeight: 100, DstChainId: 2}
+		if err := s.ComposeTx(tx); err != nil {
+			t.Fatal(err)
+		}
+		if tx.DstPolyEpochStartHeight != 200 || tx.AnchorHeader == nil || tx.AnchorHeader.Height != 201 {
+			t.Fatalf("Expect resolved epoch start height anchoring the proof, got %d", tx.DstPolyEpochStartHeight)
+		}
+	}
+	if calls[2] != 1 {
+		t.Fatalf("Expect epoch start height cached, resolved %d times", calls[2])
+	}
+	now = now.Add(EPOCH_CACHE_TTL + time.Second)
+	s.ComposeTx(&msg.Tx{PolyHash: "a", PolyKey: "key", PolyHeight: 100, DstChainId: 2})
+	if calls[2] != 2 {
+		t.Fatalf("Expect epoch start height resolved again after ttl, resolved %d times", calls[2])
+	}
+
+	// Provided heights skip the resolver
+	s.ComposeTx(&msg.Tx{PolyHash: "a", PolyKey: "key", PolyHeight: 100, DstChainId: 3, DstPolyEpochStartHeight: 50})
+	if calls[3] != 0 {
+		t.Fatal("Unexpected resolving with epoch start height provided")
+	}
+	if err := s.ComposeTx(&msg.Tx{PolyHash: "a", PolyKey: "key", PolyHeight: 100, DstChainId: 7}); err == nil {
+		t.Fatal("Expect resolver error")
+	}
+}
+
+func TestAnchorOffsets(t *testing.T) {
+	conf := &config.PolySubmitterConfig{AnchorOffsets: map[uint64]*config.AnchorOffset{
+		base.BSC: {Epoch: 3, EpochStart: 2},
+		base.ETH: {Epoch: 4},
+	}}
+	epoch := func(v bool) func() (bool, error) {
+		return func() (bool, error) { return v, nil }
+	}
+	cases := []struct {
+		chain                uint64
+		polyHeight, dstStart uint32
+		epoch                bool
+		anchor               uint32
+	}{
+		{base.BSC, 100, 200, false, 202},
+		{base.BSC, 300, 200, true, 303}, // Epoch switch at the tx block
+		{base.BSC, 300, 200, false, 0},
+		{base.ETH, 100, 200, false, 201},
+		{base.ETH, 300, 200, true, 304},
+		{base.HECO, 300, 200, true, 302}, // Defaults
+	}
+	for i, c := range cases {
+		tx := &msg.Tx{PolyHeight: c.polyHeight, DstPolyEpochStartHeight: c.dstStart, DstChainId: c.chain}
+		anchor, err := anchorHeight(tx, conf.AnchorOffset(c.chain), epoch(c.epoch))
+		if err != nil || anchor != c.anchor {
+			t.Fatalf("Case %d expect anchor %v, got %v err %v", i, c.anchor, anchor, err)
+		}
+	}
+
+	// Anchor header not above the poly header following the tx block
+	_, err := anchorHeight(&msg.Tx{PolyHeight: 300}, config.AnchorOffset{Epoch: 1, EpochStart: 1}, epoch(true))
+	if err == nil {
+		t.Fatal("Expect epoch anchor offset below 2 rejected")
+	}
+	conf.AnchorOffsets[base.OK] = &config.AnchorOffset{Epoch: 1}
+	if err = new(Submitter).Init(conf); err == nil || !strings.Contains(err.Error(), "anchor offset") {
+		t.Fatalf("Expect invalid anchor offset rejected on init, got %v", err)
+	}
+}
+
+func TestAnchorReuse(t *testing.T) {
+	s := &Submitter{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return testSignedHeader(t, uint32(params[0].(float64))), nil
+		case "getmerkleproof":
+			return map[string]string{"Type": "MerkleProof", "AuditPath": "00"}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+	// Anchor header with the block root covering the poly header only
+	anchor := func(height uint32, hdr *types.Header) (*types.Header, string) {
+		hash := hdr.Hash()
+		path := pcom.NewZeroCopySink(nil)
+		path.WriteVarBytes(hash[:])
+		return &types.Header{Height: height, BlockRoot: merkle.HashLeaf(hash[:])}, hex.EncodeToString(path.Bytes())
+	}
+	polyHeader := &types.Header{Height: 101}
+	tx := &msg.Tx{PolyHeight: 100, PolyHeader: polyHeader, DstPolyEpochStartHeight: 200}
+	tx.AnchorHeader, tx.AnchorProof = anchor(201, polyHeader)
+	cached := tx.AnchorHeader
+	if err := s.ComposePolyHeaderProof(tx); err != nil || tx.AnchorHeader != cached {
+		t.Fatalf("Expect anchor proof reused, err %v", err)
+	}
+
+	// Anchors not proving the poly header or at other heights are fetched again
+	cases := []func(*msg.Tx){
+		func(tx *msg.Tx) { tx.PolyHeader = &types.Header{Height: 102} },
+		func(tx *msg.Tx) { tx.AnchorProof = "00" },
+		func(tx *msg.Tx) { tx.DstPolyEpochStartHeight = 201 },
+		func(tx *msg.Tx) { tx.ForceAnchorHeight = 205 },
+	}
+	for i, change := range cases {
+		tx := &msg.Tx{PolyHeight: 100, PolyHeader: polyHeader, DstPolyEpochStartHeight: 200}
+		tx.AnchorHeader, tx.AnchorProof = anchor(201, polyHeader)
+		cached := tx.AnchorHeader
+		change(tx)
+		if err := s.ComposePolyHeaderProof(tx); err != nil || tx.AnchorHeader == cached || tx.AnchorProof != "00" {
+			t.Fatalf("Case %d expect anchor proof fetched again, err %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Scanned poly txs held till their blocks have depth blocks on top, so the listener emits confirmed txs
+// without relying on the scheduler defer. A nil gate emits the txs as scanned.
+type confirmGate struct {
+	sync.Mutex
+	depth uint64
+	txs   []*msg.Tx // Held txs ordered by block height
+}
+
+func newConfirmGate(depth uint64) *confirmGate {
+	if depth == 0 {
+		return nil
+	}
+	return &confirmGate{depth: depth}
+}
+
+// Hold the txs scanned at height and return the held txs confirmed by the latest height. Txs held for the height
+// and above are replaced, as a rescan or a reorg rewind brings the blocks again.
+func (g *confirmGate) Hold(height uint64, txs []*msg.Tx, latest uint64) []*msg.Tx {
+	if g == nil {
+		return txs
+	}
+	g.Lock()
+	defer g.Unlock()
+	held := g.txs[:0]
+	for _, tx := range g.txs {
+		if uint64(tx.PolyHeight) < height {
+			held = append(held, tx)
+		}
+	}
+	held = append(held, txs...)
+	sort.SliceStable(held, func(i, j int) bool { return held[i].PolyHeight < held[j].PolyHeight })
+
+	n := sort.Search(len(held), func(i int) bool { return !g.confirmed(held[i], latest) })
+	confirmed := append([]*msg.Tx(nil), held[:n]...)
+	g.txs = append(held[:0:0], held[n:]...)
+	if len(g.txs) > 0 {
+		log.Debug("Holding poly txs till confirmed", "size", len(g.txs), "latest", latest, "confirmations", g.depth)
+	}
+	return confirmed
+}
+
+// Height safe to checkpoint once the height is scanned, below the held txs, as they are lost on a restart
+func (g *confirmGate) Checkpoint(height uint64) uint64 {
+	if g == nil {
+		return height
+	}
+	g.Lock()
+	defer g.Unlock()
+	if len(g.txs) > 0 && uint64(g.txs[0].PolyHeight) <= height {
+		return uint64(g.txs[0].PolyHeight) - 1
+	}
+	return height
+}
+
+func (g *confirmGate) confirmed(tx *msg.Tx, latest uint64) bool {
+	return uint64(tx.PolyHeight)+g.depth <= latest
+}
@@ -0,0 +1,129 @@
+package poly
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+func TestScanConfirmations(t *testing.T) {
+	latest := int64(10)
+	sdk := testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return atomic.LoadInt64(&latest) + 1, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		case "getsmartcodeevent":
+			height := int64(params[0].(float64))
+			notify := []interface{}{map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS,
+				"States": []interface{}{"makeProof", 2, 6, fmt.Sprintf("%04x", height), height, "key"}}}
+			return []interface{}{map[string]interface{}{"TxHash": fmt.Sprintf("%064x", height), "State": 1, "Notify": notify}}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	l := new(Listener)
+	if err := l.Init(&config.ListenerConfig{Confirmations: 3}, sdk); err != nil {
+		t.Fatal(err)
+	}
+	expect := func(height uint64, heights ...uint32) {
+		txs, err := l.Scan(height)
+		if err != nil {
+			t.Fatalf("Scan block %d error %v", height, err)
+		}
+		if len(txs) != len(heights) {
+			t.Fatalf("Expect txs of blocks %v at scan of block %d, got %d txs", heights, height, len(txs))
+		}
+		for i, tx := range txs {
+			if tx.PolyHeight != heights[i] {
+				t.Fatalf("Expect tx of block %d, got block %d", heights[i], tx.PolyHeight)
+			}
+		}
+	}
+
+	checkpoint := func(height, mark uint64) {
+		if h := l.Checkpoint(height); h != mark {
+			t.Fatalf("Expect checkpoint %d after scanning block %d, got %d", mark, height, h)
+		}
+	}
+
+	// Blocks deep enough are emitted, newer ones held and kept above the checkpoint
+	expect(7, 7)
+	checkpoint(7, 7)
+	expect(8)
+	checkpoint(8, 7)
+	expect(9)
+	checkpoint(9, 7)
+	atomic.StoreInt64(&latest, 11)
+	expect(10, 8)
+	checkpoint(10, 8)
+
+	// Rescan replaces the held txs from the block on
+	atomic.StoreInt64(&latest, 13)
+	expect(9, 9)
+	expect(10, 10)
+	if len(l.confirms.txs) != 0 {
+		t.Fatalf("Expect no held txs, got %d", len(l.confirms.txs))
+	}
+}
+
+func TestScanStreamConfirmations(t *testing.T) {
+	latest := int64(10)
+	sdk := testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return atomic.LoadInt64(&latest) + 1, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		case "getblocktxsbyheight":
+			height := int64(params[0].(float64))
+			return map[string]interface{}{"Hash": fmt.Sprintf("%064x", 0), "Height": height, "Transactions": []string{fmt.Sprintf("%064x", height)}}, nil
+		case "getsmartcodeevent":
+			var height int64
+			fmt.Sscanf(params[0].(string), "%x", &height)
+			notify := []interface{}{map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS,
+				"States": []interface{}{"makeProof", 2, 6, fmt.Sprintf("%04x", height), height, "key"}}}
+			return map[string]interface{}{"TxHash": params[0], "State": 1, "Notify": notify}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	l := new(Listener)
+	if err := l.Init(&config.ListenerConfig{Confirmations: 3}, sdk); err != nil {
+		t.Fatal(err)
+	}
+	l.nodes = newNodeSelector(&config.NodeHealthConfig{ErrorRate: 0.5, Window: 100, Quarantine: 60})
+	expect := func(height uint64, heights ...uint32) {
+		txs, errs := l.ScanStream(height)
+		var got []uint32
+		for tx := range txs {
+			got = append(got, tx.PolyHeight)
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("Scan block %d error %v", height, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(heights) {
+			t.Fatalf("Expect txs of blocks %v at scan of block %d, got %v", heights, height, got)
+		}
+	}
+
+	// Streamed blocks go through the same confirm gate as the batch scan
+	expect(7, 7)
+	expect(8)
+	expect(9)
+	atomic.StoreInt64(&latest, 11)
+	expect(10, 8)
+	atomic.StoreInt64(&latest, 13)
+	expect(9, 9)
+	expect(10, 10)
+
+	// Node calls of the stream are reported to the node selector
+	if health := l.NodeHealth(); len(health) != 1 || health[0].Calls < 12 {
+		t.Fatalf("Expect stream node calls reported, got %+v", health)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"bytes"
+
+	"github.com/polynetwork/poly/core/types"
+)
+
+// ProofEncoder encodes the poly proofs and header sigs of txs in the native form of the dst chain
+type ProofEncoder interface {
+	// Encode the eth compatible header sigs, given in the order to submit
+	EncodeSigs(hdr *types.Header, sigs [][]byte) ([]byte, error)
+	// Encode the hex audit path of the cross chain merkle value
+	EncodeAuditPath(path string) (string, error)
+}
+
+// Encoder for the EVM dst chains, concatenating the sigs and keeping the audit path as is
+type evmProofEncoder struct{}
+
+func (evmProofEncoder) EncodeSigs(hdr *types.Header, sigs [][]byte) ([]byte, error) {
+	return bytes.Join(sigs, nil), nil
+}
+
+func (evmProofEncoder) EncodeAuditPath(path string) (string, error) {
+	return path, nil
+}
+
+// Set the proof encoder of the dst chain, should be called before starting the submitter
+func (s *Submitter) SetProofEncoder(chainId uint64, encoder ProofEncoder) {
+	if s.encoders == nil {
+		s.encoders = map[uint64]ProofEncoder{}
+	}
+	s.encoders[chainId] = encoder
+}
+
+// Proof encoder of the dst chain, EVM encoding if not set
+func (s *Submitter) proofEncoder(chainId uint64) ProofEncoder {
+	if encoder, ok := s.encoders[chainId]; ok && encoder != nil {
+		return encoder
+	}
+	return evmProofEncoder{}
+}
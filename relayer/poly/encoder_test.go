@@ -0,0 +1,80 @@
+package poly
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Non-EVM encoding with a sig count prefix and the recovery id dropped
+type stubProofEncoder struct {
+	fail bool
+}
+
+func (e stubProofEncoder) EncodeSigs(hdr *types.Header, sigs [][]byte) ([]byte, error) {
+	if e.fail {
+		return nil, errors.New("unsupported sig")
+	}
+	data := []byte{byte(len(sigs))}
+	for _, sig := range sigs {
+		data = append(data, sig[:64]...)
+	}
+	return data, nil
+}
+
+func (e stubProofEncoder) EncodeAuditPath(path string) (string, error) {
+	return "00" + path, nil
+}
+
+func TestProofEncoder(t *testing.T) {
+	hdr := &types.Header{Height: 100}
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	var evm [][]byte
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		sig, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr.SigData = append(hdr.SigData, append([]byte{byte(signature.SHA256withECDSA), sig[64] + 27}, sig[:64]...))
+		evm = append(evm, sig)
+	}
+
+	s := new(Submitter)
+	s.SetProofEncoder(base.APTOS, stubProofEncoder{})
+	tx := &msg.Tx{DstChainId: base.ETH, PolyHeader: hdr}
+	if err := s.CollectSigs(tx); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx.PolySigs, bytes.Join(evm, nil)) {
+		t.Fatal("Expect EVM encoded sigs by default")
+	}
+	tx = &msg.Tx{DstChainId: base.APTOS, PolyHeader: hdr}
+	if err := s.CollectSigs(tx); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.PolySigs) != 1+3*64 || tx.PolySigs[0] != 3 || !bytes.Equal(tx.PolySigs[1:65], evm[0][:64]) {
+		t.Fatalf("Expect sigs encoded by the dst chain encoder, got %x", tx.PolySigs)
+	}
+
+	if path, _ := s.proofEncoder(base.ETH).EncodeAuditPath("0a0b"); path != "0a0b" {
+		t.Fatalf("Expect audit path kept by default, got %s", path)
+	}
+	if path, _ := s.proofEncoder(base.APTOS).EncodeAuditPath("0a0b"); path != "000a0b" {
+		t.Fatalf("Expect audit path encoded by the dst chain encoder, got %s", path)
+	}
+
+	s.SetProofEncoder(base.APTOS, stubProofEncoder{fail: true})
+	if err := s.CollectSigs(&msg.Tx{DstChainId: base.APTOS, PolyHeader: hdr}); err == nil {
+		t.Fatal("Expect sig encoding error")
+	}
+}
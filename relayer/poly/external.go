@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+
+	pcom "github.com/polynetwork/poly/common"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// SubmitExternalProof imports a src tx proof assembled outside the relayer, value is the serialized
+// MakeTxParam of the src event, proven by proof at the src proofHeight. It runs the same checks as the
+// src tx submit, the returned poly hash is empty when the tx was already imported.
+func (s *Submitter) SubmitExternalProof(srcChainId uint64, value, proof []byte, proofHeight uint32) (polyHash string, err error) {
+	tx, err := externalProofTx(srcChainId, value, proof, proofHeight)
+	if err != nil {
+		return
+	}
+	key := "external:" + tx.IdempotencyKey()
+	if s.seenKey(tx, key) {
+		return "", fmt.Errorf("%w, external proof of src tx %s already submitted", msg.ERR_TX_BYPASS, tx.TxId)
+	}
+	ctx, span := s.startTxSpan(tx, "submitExternalProof")
+	defer func() { endSpan(span, err) }()
+	res, err := s.importTx(ctx, tx)
+	if err != nil {
+		return
+	}
+	s.markKey(tx, key)
+	return res.PolyHash, nil
+}
+
+// Build the src tx of an external proof
+func externalProofTx(srcChainId uint64, value, proof []byte, proofHeight uint32) (tx *msg.Tx, err error) {
+	if srcChainId == 0 || len(proof) == 0 || proofHeight == 0 {
+		return nil, fmt.Errorf("%w, external proof missing src chain(%d), proof(%x) or proof height(%d)",
+			msg.ERR_INVALID_TX, srcChainId, proof, proofHeight)
+	}
+	param := new(ccom.MakeTxParam)
+	err = param.Deserialization(pcom.NewZeroCopySource(value))
+	if err != nil {
+		return nil, fmt.Errorf("%w, decode external proof value %x error %v", msg.ERR_INVALID_TX, value, err)
+	}
+	tx = &msg.Tx{
+		TxType:         msg.SRC,
+		TxId:           msg.EncodeTxId(param.TxHash),
+		Param:          param,
+		SrcChainId:     srcChainId,
+		SrcEvent:       value,
+		SrcProof:       proof,
+		SrcProofHeight: uint64(proofHeight),
+		DstChainId:     param.ToChainID,
+	}
+	return
+}
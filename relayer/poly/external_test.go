@@ -0,0 +1,71 @@
+package poly
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestSubmitExternalProof(t *testing.T) {
+	useTestConfig(t)
+	var sends int
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{},
+		signer: sdk.NewAccount(),
+		seen:   bus.NewMemorySeenSet(time.Minute),
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 100, nil
+			case "getheaderbyheight":
+				return fmt.Sprintf("%x", (&types.Header{}).ToArray()), nil
+			case "getstorage":
+				return "", nil
+			case "sendrawtransaction":
+				sends++
+				return fmt.Sprintf("%064x", 1), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	param := &ccom.MakeTxParam{
+		TxHash:              []byte{1},
+		CrossChainID:        []byte{2},
+		FromContractAddress: []byte{3},
+		ToChainID:           base.BSC,
+		ToContractAddress:   []byte{4},
+		Method:              "unlock",
+		Args:                []byte{5},
+	}
+	sink := pcom.NewZeroCopySink(nil)
+	param.Serialization(sink)
+	value := sink.Bytes()
+
+	hash, err := s.SubmitExternalProof(base.ETH, value, []byte{6}, 100)
+	if err != nil || hash != fmt.Sprintf("%064x", 1) || sends != 1 {
+		t.Fatalf("Expect external proof imported, got hash %s sends %d err %v", hash, sends, err)
+	}
+	_, err = s.SubmitExternalProof(base.ETH, value, []byte{6}, 100)
+	if !errors.Is(err, msg.ERR_TX_BYPASS) || sends != 1 {
+		t.Fatalf("Expect duplicated external proof bypassed, got sends %d err %v", sends, err)
+	}
+	_, err = s.SubmitExternalProof(base.ETH, value[:len(value)-2], []byte{6}, 100)
+	if !errors.Is(err, msg.ERR_INVALID_TX) || sends != 1 {
+		t.Fatalf("Expect malformed external proof rejected, got sends %d err %v", sends, err)
+	}
+	_, err = s.SubmitExternalProof(base.ETH, value, nil, 100)
+	if !errors.Is(err, msg.ERR_INVALID_TX) || sends != 1 {
+		t.Fatalf("Expect external proof without proof rejected, got sends %d err %v", sends, err)
+	}
+}
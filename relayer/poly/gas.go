@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Estimator of the gas cost of an unsigned poly tx
+type GasEstimator func(tx *types.Transaction) (uint64, error)
+
+// Gas settings of a poly tx, zero values keep the sdk defaults
+type polyGas struct {
+	price uint64
+	limit uint64
+}
+
+// Set the source of the suggested poly gas price scaled by GasPriceMultiplier, the configured GasPrice is scaled if nil
+func (s *Submitter) SetGasPriceOracle(oracle func() (uint64, error)) {
+	s.gasOracle = oracle
+}
+
+// Gas settings for the poly tx, the price is the suggested price scaled by the multiplier in auto mode
+func (s *Submitter) txGas(price, limit uint64) (gas polyGas, err error) {
+	gas = polyGas{price, limit}
+	if s.config == nil || s.config.GasPriceMultiplier <= 0 {
+		return
+	}
+	if s.gasOracle != nil {
+		price, err = s.gasOracle()
+		if err != nil {
+			return gas, fmt.Errorf("Failed to get suggested poly gas price %v", err)
+		}
+	}
+	gas.price = uint64(float64(price) * s.config.GasPriceMultiplier)
+	return
+}
+
+// Set the import gas estimator, the tx is pre-executed on the poly node if nil
+func (s *Submitter) SetGasEstimator(estimator GasEstimator) {
+	s.gasEstimator = estimator
+}
+
+// EstimateImportGas estimates the gas cost of importing the composed src tx to poly without signing or sending it.
+// Poly native contract calls are not metered, the default pre-execution only checks that the import succeeds
+// and estimates zero gas, set an estimator with SetGasEstimator otherwise.
+func (s *Submitter) EstimateImportGas(tx *msg.Tx) (uint64, error) {
+	if tx.SrcChainId == 0 || len(tx.SrcEvent) == 0 {
+		return 0, fmt.Errorf("%s submitter src tx %s not composed or src chain id not specified", s.name, tx.SrcHash)
+	}
+	node := s.nodes.Node(s.sdk)
+	t, err := newImportTx(node, s.ccm(), tx, importAccount(tx.SrcChainId, s.account()))
+	if err != nil {
+		return 0, err
+	}
+	return s.estimateGas(node, t)
+}
+
+func (s *Submitter) estimateGas(node *poly.Client, tx *types.Transaction) (uint64, error) {
+	if s.gasEstimator != nil {
+		return s.gasEstimator(tx)
+	}
+	return preExecGas(node, tx)
+}
+
+// Pre-execute the tx on the node, which reports no gas usage of native contract calls
+func preExecGas(node *poly.Client, tx *types.Transaction) (uint64, error) {
+	res, err := node.PreExecTransaction(tx)
+	if err != nil {
+		return 0, fmt.Errorf("Pre-execute poly tx error %v", err)
+	}
+	if res.State == 0 {
+		return 0, fmt.Errorf("%w, poly tx pre-execution failed", msg.ERR_TX_EXEC_FAILURE)
+	}
+	return 0, nil
+}
+
+// Gas limit of the tx scaled from the estimate by GasLimitMultiplier in auto mode, a zero estimate keeps the limit
+func (s *Submitter) autoLimit(gas polyGas, node *poly.Client, tx *types.Transaction) (polyGas, error) {
+	if s.config == nil || s.config.GasLimitMultiplier <= 0 {
+		return gas, nil
+	}
+	estimate, err := s.estimateGas(node, tx)
+	if err != nil {
+		return gas, fmt.Errorf("Failed to estimate poly gas %v", err)
+	}
+	if estimate > 0 {
+		gas.limit = uint64(float64(estimate) * s.config.GasLimitMultiplier)
+	}
+	return gas, nil
+}
+
+// Gas settings for ImportOuterTransfer txs
+func (s *Submitter) importGas() (polyGas, error) {
+	if s.config == nil {
+		return polyGas{}, nil
+	}
+	return s.txGas(s.config.GasPrice, s.config.GasLimit)
+}
+
+// Gas settings for SyncBlockHeader txs, the header sync config overrides the poly submitter config
+func (s *Submitter) headerGas() (polyGas, error) {
+	var price, limit uint64
+	if s.config != nil {
+		price, limit = s.config.GasPrice, s.config.GasLimit
+	}
+	if s.sync != nil {
+		if s.sync.GasPrice > 0 {
+			price = s.sync.GasPrice
+		}
+		if s.sync.GasLimit > 0 {
+			limit = s.sync.GasLimit
+		}
+	}
+	return s.txGas(price, limit)
+}
+
+// Sign and send the native tx with the gas settings applied
+func sendWithGas(node *poly.Client, tx *types.Transaction, gas polyGas, signer *sdk.Account) (hash pcom.Uint256, err error) {
+	if gas.price != 0 || gas.limit != 0 {
+		tx.GasPrice, tx.GasLimit = gas.price, gas.limit
+		// Decode the tx again to refresh the cached hash and raw bytes
+		sink := pcom.NewZeroCopySink(nil)
+		if err = tx.Serialization(sink); err != nil {
+			return
+		}
+		tx, err = types.TransactionFromRawBytes(sink.Bytes())
+		if err != nil {
+			return
+		}
+	}
+	if err = node.SignToTransaction(tx, signer); err != nil {
+		return
+	}
+	return node.SendTransaction(tx)
+}
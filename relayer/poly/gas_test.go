@@ -0,0 +1,147 @@
+package poly
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly-go-sdk/utils"
+	"github.com/polynetwork/poly/core/types"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestTxGas(t *testing.T) {
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{GasPrice: 500, GasLimit: 20000},
+		sync:   &config.HeaderSyncConfig{GasLimit: 40000},
+	}
+	if gas, err := s.importGas(); err != nil || gas != (polyGas{500, 20000}) {
+		t.Fatalf("Unexpected fixed gas %+v, err %v", gas, err)
+	}
+	if gas, err := s.headerGas(); err != nil || gas != (polyGas{500, 40000}) {
+		t.Fatalf("Unexpected header gas %+v, err %v", gas, err)
+	}
+
+	// Auto mode scales the configured price without an oracle
+	s.config.GasPriceMultiplier = 1.5
+	if gas, _ := s.importGas(); gas.price != 750 {
+		t.Fatalf("Expect scaled gas price 750, got %d", gas.price)
+	}
+	s.SetGasPriceOracle(func() (uint64, error) { return 1000, nil })
+	if gas, _ := s.importGas(); gas != (polyGas{1500, 20000}) {
+		t.Fatalf("Unexpected auto gas %+v", gas)
+	}
+	s.SetGasPriceOracle(func() (uint64, error) { return 0, fmt.Errorf("node down") })
+	if _, err := s.headerGas(); err == nil {
+		t.Fatal("Expect oracle failure")
+	}
+}
+
+func TestSendWithGas(t *testing.T) {
+	signer := sdk.NewAccount()
+	var sent *types.Transaction
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		if method != "sendrawtransaction" {
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}
+		raw, _ := hex.DecodeString(params[0].(string))
+		tx, err := types.TransactionFromRawBytes(raw)
+		if err != nil {
+			return nil, err
+		}
+		sent = tx
+		hash := tx.Hash()
+		return hash.ToHexString(), nil
+	})
+	tx, err := node.Native.Hs.NewSyncBlockHeaderTransaction(2, signer.Address, [][]byte{{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := sendWithGas(node, tx, polyGas{2500, 30000}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.GasPrice != 2500 || sent.GasLimit != 30000 {
+		t.Fatalf("Unexpected sent gas price %d limit %d", sent.GasPrice, sent.GasLimit)
+	}
+	if hash != sent.Hash() || !utils.HasAlreadySig(hash.ToArray(), signer.GetPublicKey(), sent.Sigs[0].SigData) {
+		t.Fatal("Expect the tx signed over the hash with gas applied")
+	}
+}
+
+func TestEstimateImportGas(t *testing.T) {
+	useTestConfig(t)
+	var (
+		state float64 = 1
+		sent  *types.Transaction
+	)
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{GasLimit: 20000},
+		signer: sdk.NewAccount(),
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 100, nil
+			case "getheaderbyheight":
+				return hex.EncodeToString((&types.Header{}).ToArray()), nil
+			case "getstorage":
+				return "", nil
+			case "sendrawtransaction":
+				if len(params) > 1 {
+					return map[string]interface{}{"State": state}, nil
+				}
+				raw, _ := hex.DecodeString(params[0].(string))
+				tx, err := types.TransactionFromRawBytes(raw)
+				if err != nil {
+					return nil, err
+				}
+				sent = tx
+				hash := tx.Hash()
+				return hash.ToHexString(), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	tx := &msg.Tx{
+		SrcChainId: base.ETH,
+		SrcEvent:   []byte{1},
+		SrcProof:   []byte{2},
+		Param:      &ccom.MakeTxParam{CrossChainID: []byte{3}, Method: "unlock"},
+	}
+
+	// Pre-execution on the node estimates no gas
+	if gas, err := s.EstimateImportGas(tx); err != nil || gas != 0 {
+		t.Fatalf("Expect zero gas pre-executed, got %d err %v", gas, err)
+	}
+	state = 0
+	if _, err := s.EstimateImportGas(tx); !errors.Is(err, msg.ERR_TX_EXEC_FAILURE) {
+		t.Fatalf("Expect pre-execution failure, got %v", err)
+	}
+	if _, err := s.EstimateImportGas(&msg.Tx{SrcChainId: base.ETH}); err == nil {
+		t.Fatal("Expect uncomposed tx rejected")
+	}
+
+	s.SetGasEstimator(func(t *types.Transaction) (uint64, error) {
+		if len(t.Sigs) > 0 {
+			return 0, fmt.Errorf("signed tx estimated")
+		}
+		return 1000, nil
+	})
+	if gas, err := s.EstimateImportGas(tx); err != nil || gas != 1000 {
+		t.Fatalf("Expect estimated gas 1000, got %d err %v", gas, err)
+	}
+	if _, err := s.importTx(context.Background(), tx); err != nil || sent.GasLimit != 20000 {
+		t.Fatalf("Expect configured gas limit without auto mode, got %+v err %v", sent, err)
+	}
+	s.config.GasLimitMultiplier = 1.5
+	if _, err := s.importTx(context.Background(), tx); err != nil || sent.GasLimit != 1500 {
+		t.Fatalf("Expect scaled estimate as gas limit, got %+v err %v", sent, err)
+	}
+}
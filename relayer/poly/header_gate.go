@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"context"
+	"sync"
+)
+
+// Limits of the header sync txs submitted to poly, shared by the side chain submitters of the same settings,
+// so the header syncs of the chains run independently while poly sees bounded load
+type headerGate struct {
+	slots   chan struct{} // Concurrent header submits, no limit if nil
+	limiter *rateLimiter  // Header submit rate, no limit if nil
+}
+
+type headerGateKey struct {
+	procs int
+	rate  float64
+}
+
+var (
+	headerGatesLock sync.Mutex
+	headerGates     = map[headerGateKey]*headerGate{}
+)
+
+// Header gate shared by the submitters of the settings, nil if no limit is configured
+func sharedHeaderGate(procs int, rate float64) *headerGate {
+	if procs <= 0 && rate <= 0 {
+		return nil
+	}
+	key := headerGateKey{procs, rate}
+	headerGatesLock.Lock()
+	defer headerGatesLock.Unlock()
+	gate, ok := headerGates[key]
+	if !ok {
+		gate = &headerGate{limiter: newRateLimiter(rate, 1)}
+		if procs > 0 {
+			gate.slots = make(chan struct{}, procs)
+		}
+		headerGates[key] = gate
+	}
+	return gate
+}
+
+// Wait for a header submit slot, returns false if the context is done first
+func (g *headerGate) acquire(ctx context.Context) bool {
+	if g == nil {
+		return true
+	}
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	g.limiter.Wait()
+	return true
+}
+
+func (g *headerGate) release() {
+	if g != nil && g.slots != nil {
+		<-g.slots
+	}
+}
@@ -0,0 +1,88 @@
+package poly
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+func TestHeaderGate(t *testing.T) {
+	if sharedHeaderGate(0, 0) != nil {
+		t.Fatal("Expect no gate without limits")
+	}
+	gate := sharedHeaderGate(1, 0)
+	if sharedHeaderGate(1, 0) != gate || sharedHeaderGate(2, 0) == gate {
+		t.Fatal("Expect gate shared by the same settings only")
+	}
+
+	var inflight, overlaps, submits int64
+	submitter := func(fail bool) *Submitter {
+		s := &Submitter{
+			config:  &config.PolySubmitterConfig{},
+			signer:  sdk.NewAccount(),
+			headers: gate,
+			sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+				switch method {
+				case "getblockcount":
+					return 100, nil
+				case "getheaderbyheight":
+					return hex.EncodeToString((&types.Header{}).ToArray()), nil
+				case "sendrawtransaction":
+					if atomic.AddInt64(&inflight, 1) > 1 {
+						atomic.AddInt64(&overlaps, 1)
+					}
+					defer atomic.AddInt64(&inflight, -1)
+					time.Sleep(50 * time.Millisecond)
+					if fail {
+						return nil, errors.New("side chain header verify failed")
+					}
+					atomic.AddInt64(&submits, 1)
+					return fmt.Sprintf("%064x", 1), nil
+				case "getblockheightbytxhash":
+					return 100, nil
+				}
+				return nil, fmt.Errorf("unexpected method %s", method)
+			}),
+		}
+		s.Context, s.cancel = context.WithCancel(context.Background())
+		return s
+	}
+
+	// Chain failing on every submit keeps retrying while the other chain syncs
+	failing, healthy := submitter(true), submitter(false)
+	failed := make(chan error)
+	go func() { failed <- failing.SubmitHeadersWithLoop(base.ETH, [][]byte{{1}}, nil) }()
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		done := make(chan error)
+		go func() { done <- healthy.SubmitHeadersWithLoop(base.BSC, [][]byte{{2}}, nil) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Header sync stalled by the failing chain")
+		}
+	}
+	select {
+	case err := <-failed:
+		t.Fatalf("Expect failing chain still retrying, got %v", err)
+	default:
+	}
+	failing.cancel()
+	<-failed
+	if atomic.LoadInt64(&submits) != 3 || atomic.LoadInt64(&overlaps) != 0 {
+		t.Fatalf("Expect 3 header submits one at a time, got %d with %d overlaps", submits, overlaps)
+	}
+}
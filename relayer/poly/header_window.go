@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"bytes"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Side chain headers recently received by the header sync, so the duplicated headers from overlapping producers
+// are skipped rather than submitted again. Headers from a sync reset height on are forgotten to accept the resent ones.
+type headerWindow struct {
+	size    int
+	ids     map[uint64][]byte
+	heights []uint64 // Received heights, oldest first
+}
+
+// Window of the recent size heights, nil if disabled
+func newHeaderWindow(size int) *headerWindow {
+	if size <= 0 {
+		return nil
+	}
+	return &headerWindow{size: size, ids: make(map[uint64][]byte, size)}
+}
+
+// Header identity, the hash if present or the header data
+func headerId(header *msg.Header) []byte {
+	if len(header.Hash) > 0 {
+		return header.Hash
+	}
+	return header.Data
+}
+
+// Check if the header was received within the window, the header is recorded otherwise
+func (w *headerWindow) duplicated(header *msg.Header) bool {
+	if w == nil || header.Data == nil {
+		return false
+	}
+	id := headerId(header)
+	if prev, ok := w.ids[header.Height]; ok {
+		if bytes.Equal(prev, id) {
+			return true
+		}
+		// A different header at the height replaces the recorded one
+		w.ids[header.Height] = id
+		return false
+	}
+	w.ids[header.Height] = id
+	w.heights = append(w.heights, header.Height)
+	for len(w.heights) > w.size {
+		delete(w.ids, w.heights[0])
+		w.heights = w.heights[1:]
+	}
+	return false
+}
+
+// Forget the headers from the height on
+func (w *headerWindow) forget(height uint64) {
+	if w == nil {
+		return
+	}
+	heights := w.heights[:0]
+	for _, h := range w.heights {
+		if h < height {
+			heights = append(heights, h)
+		} else {
+			delete(w.ids, h)
+		}
+	}
+	w.heights = heights
+}
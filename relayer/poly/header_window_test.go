@@ -0,0 +1,74 @@
+package poly
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestHeaderWindow(t *testing.T) {
+	header := func(height uint64, data byte) *msg.Header {
+		return &msg.Header{Height: height, Data: []byte{data}}
+	}
+	w := newHeaderWindow(2)
+	if w.duplicated(header(10, 1)) || !w.duplicated(header(10, 1)) {
+		t.Fatal("Expect the header duplicated once received")
+	}
+	if w.duplicated(header(10, 2)) || !w.duplicated(header(10, 2)) {
+		t.Fatal("Expect a different header at the height replacing the recorded one")
+	}
+	w.duplicated(header(11, 1))
+	w.duplicated(header(12, 1))
+	if w.duplicated(header(10, 2)) {
+		t.Fatal("Expect the header out of the window forgotten")
+	}
+	w.forget(11)
+	if w.duplicated(header(11, 1)) || w.duplicated(header(12, 1)) {
+		t.Fatal("Expect the headers from the reset height forgotten")
+	}
+	if newHeaderWindow(0).duplicated(header(10, 1)) {
+		t.Fatal("Expect no dedup with the window disabled")
+	}
+}
+
+func TestSyncOverlappingHeaders(t *testing.T) {
+	cases := []struct {
+		batch    int
+		window   int
+		progress string
+	}{
+		{1, 10, "[[10 1] [11 1] [12 1] [13 1]]"},
+		{2, 10, "[[11 2] [13 2]]"},
+		// Overlapping heights break the batch without the window
+		{2, 0, "[[11 2] [11 2] [13 2]]"},
+	}
+	for _, c := range cases {
+		s := &Submitter{
+			config: &config.PolySubmitterConfig{DryRun: true},
+			sync: &config.HeaderSyncConfig{Batch: c.batch, Timeout: 10, DedupWindow: c.window,
+				ListenerConfig: &config.ListenerConfig{ChainId: base.HARMONY}},
+			state: new(memChainStore),
+		}
+		s.Context, s.cancel = context.WithCancel(context.Background())
+		progress := [][2]uint64{}
+		s.OnProgress(func(height uint64, count int) { progress = append(progress, [2]uint64{height, uint64(count)}) })
+
+		// Second producer overlapping the first one after a failover
+		heights := []uint64{10, 11, 12, 10, 11, 12, 13}
+		ch := make(chan msg.Header, len(heights))
+		for _, h := range heights {
+			ch <- msg.Header{Height: h, Data: []byte{byte(h)}}
+		}
+		close(ch)
+		s.startSync(ch, nil)
+		s.cancel()
+		if fmt.Sprint(progress) != c.progress {
+			t.Fatalf("Batch %d window %d expect progress %s, got %v", c.batch, c.window, c.progress, progress)
+		}
+	}
+}
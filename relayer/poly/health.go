@@ -0,0 +1,195 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	"github.com/polynetwork/bridge-common/log"
+)
+
+var HEALTH_CHECK_TIMEOUT = 5 * time.Second
+
+// Balance of an account. Poly does not charge gas natively and serves no balances, so no source is set by
+// default and the balance checks are skipped unless the relayer embedding the submitter provides one.
+type BalanceSource interface {
+	Balance(address string) (uint64, error)
+}
+
+// Failing subsystems reported by HealthCheck
+type HealthErrors map[string]error
+
+func (e HealthErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	info := make([]string, len(names))
+	for i, name := range names {
+		info[i] = fmt.Sprintf("%s: %v", name, e[name])
+	}
+	return strings.Join(info, "; ")
+}
+
+// Check the signer balance of the source in HealthCheck, and every interval against min once started, min 0 to
+// disable the periodical checks
+func (s *Submitter) SetBalanceSource(source BalanceSource, min uint64, interval time.Duration) {
+	s.balance = source
+	s.minBalance = min
+	s.balanceCheck = interval
+}
+
+// HealthCheck verifies poly nodes, the signer account and the tx bus, returns HealthErrors on failure
+func (s *Submitter) HealthCheck() error {
+	errs := HealthErrors{}
+	if s.sdk == nil {
+		errs["nodes"] = fmt.Errorf("poly sdk not initialized")
+	} else if err := checkNodes(s.sdk.Delta(), s.sdk.AllNodes()); err != nil {
+		errs["nodes"] = err
+	}
+	if err := s.checkSigner(); err != nil {
+		errs["signer"] = err
+	}
+	if err := s.checkBus(); err != nil {
+		errs["bus"] = err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// At least one node should respond while the chain height keeps advancing
+func checkNodes(delta int64, nodes []*poly.Client) error {
+	if delta <= 0 {
+		return fmt.Errorf("no height increment since last update")
+	}
+	list := make([]chains.SDK, len(nodes))
+	for i, node := range nodes {
+		list[i] = node
+	}
+	return anyNodeResponsive(list)
+}
+
+func anyNodeResponsive(nodes []chains.SDK) error {
+	var errs NodeErrors
+	for i, node := range nodes {
+		_, err := node.GetLatestHeight()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("node %d %s: %v", i, node.Address(), err))
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no poly node configured")
+	}
+	return errs
+}
+
+func (s *Submitter) checkSigner() error {
+	signer := s.account()
+	if signer == nil {
+		return fmt.Errorf("signer account not loaded")
+	}
+	if s.balance == nil {
+		return nil
+	}
+	address := signer.Address.ToBase58()
+	balance, err := s.balance.Balance(address)
+	if err != nil {
+		return fmt.Errorf("query balance of %s error %v", address, err)
+	}
+	if balance == 0 {
+		return fmt.Errorf("zero balance of %s", address)
+	}
+	return nil
+}
+
+// Only checked once the tx bus is attached with Start
+func (s *Submitter) checkBus() error {
+	if s.mq == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+	_, err := s.mq.Len(ctx)
+	if err != nil {
+		return fmt.Errorf("bus %s unreachable %v", s.mq.Topic(), err)
+	}
+	return nil
+}
+
+// Register the handler invoked when the signer balance drops below the min balance of the balance source
+func (s *Submitter) OnLowBalance(handler func(address string, balance uint64)) {
+	s.onLowBalance = handler
+}
+
+// Periodically check the signer balance, requires a balance source with a min balance
+func (s *Submitter) monitorBalance() {
+	if s.balance == nil || s.account() == nil || s.minBalance == 0 {
+		return
+	}
+	interval := s.balanceCheck
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	log.Info("Starting poly signer balance monitor", "chain", s.name, "min_balance", s.minBalance, "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	low := false
+	for {
+		low = s.checkBalance(low)
+		select {
+		case <-s.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check signer balance once, the low balance handler fires when the balance crosses below the threshold.
+// Returns whether the balance is currently low.
+func (s *Submitter) checkBalance(low bool) bool {
+	address := s.account().Address.ToBase58()
+	balance, err := s.balance.Balance(address)
+	if err != nil {
+		log.Error("Failed to query poly signer balance", "address", address, "err", err)
+		return low
+	}
+	record(balance, "%s.signer_balance", s.name)
+	if balance >= s.minBalance {
+		return false
+	}
+	log.Warn("Poly signer balance low", "address", address, "balance", balance, "min_balance", s.minBalance)
+	if !low {
+		s.alert(ALERT_WARN, "Poly signer balance low", map[string]interface{}{
+			"chain": s.name, "address": address, "balance": balance, "min_balance": s.minBalance,
+		})
+		if s.onLowBalance != nil {
+			s.onLowBalance(address, balance)
+		}
+	}
+	return true
+}
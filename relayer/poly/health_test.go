@@ -0,0 +1,106 @@
+package poly
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains"
+	sdk "github.com/polynetwork/poly-go-sdk"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type testNode struct {
+	height uint64
+	err    error
+}
+
+func (n *testNode) GetLatestHeight() (uint64, error) { return n.height, n.err }
+func (n *testNode) Address() string                  { return "test" }
+
+type testBalance struct {
+	balance uint64
+	err     error
+}
+
+func (b *testBalance) Balance(string) (uint64, error) { return b.balance, b.err }
+
+type testSortedBus struct {
+	err error
+}
+
+func (b *testSortedBus) Push(context.Context, *msg.Tx, uint64) error { return nil }
+func (b *testSortedBus) Range(context.Context, uint64, int64) ([]*msg.Tx, error) {
+	return nil, nil
+}
+func (b *testSortedBus) PopTimed(context.Context, time.Duration) (*msg.Tx, uint64, error) {
+	return nil, 0, nil
+}
+func (b *testSortedBus) Pop(context.Context) (*msg.Tx, uint64, error) { return nil, 0, nil }
+func (b *testSortedBus) Len(context.Context) (uint64, error)          { return 0, b.err }
+func (b *testSortedBus) Topic() string                                { return "test" }
+
+func TestHealthCheck(t *testing.T) {
+	down := &testNode{err: errors.New("timeout")}
+	if err := anyNodeResponsive([]chains.SDK{down, &testNode{height: 1}}); err != nil {
+		t.Fatalf("Expect healthy with one responsive node, got %v", err)
+	}
+	if err := anyNodeResponsive([]chains.SDK{down, down}); err == nil {
+		t.Fatal("Expect failure with all nodes down")
+	}
+	if err := checkNodes(0, nil); err == nil {
+		t.Fatal("Expect failure with stalled height")
+	}
+
+	s := &Submitter{signer: new(sdk.Account), mq: &testSortedBus{}}
+	if err := s.checkSigner(); err != nil {
+		t.Fatalf("Expect signer healthy without balance source, got %v", err)
+	}
+	s.SetBalanceSource(&testBalance{balance: 0}, 0, 0)
+	if err := s.checkSigner(); err == nil {
+		t.Fatal("Expect failure with zero balance")
+	}
+	s.SetBalanceSource(&testBalance{err: errors.New("rpc")}, 0, 0)
+	if err := s.checkSigner(); err == nil {
+		t.Fatal("Expect failure with balance query error")
+	}
+	s.SetBalanceSource(&testBalance{balance: 10}, 0, 0)
+
+	err := s.HealthCheck()
+	errs, ok := err.(HealthErrors)
+	if !ok || len(errs) != 1 || errs["nodes"] == nil {
+		t.Fatalf("Expect only nodes failure without sdk, got %v", err)
+	}
+
+	s.signer = nil
+	s.mq = &testSortedBus{err: errors.New("redis down")}
+	errs, _ = s.HealthCheck().(HealthErrors)
+	if len(errs) != 3 || errs["signer"] == nil || errs["bus"] == nil {
+		t.Fatalf("Expect each failing subsystem reported, got %v", errs)
+	}
+}
+
+func TestCheckBalance(t *testing.T) {
+	source := &testBalance{balance: 100}
+	s := &Submitter{name: "test", signer: new(sdk.Account)}
+	s.SetBalanceSource(source, 50, 0)
+	var alerts []uint64
+	s.OnLowBalance(func(address string, balance uint64) { alerts = append(alerts, balance) })
+
+	low := false
+	for _, balance := range []uint64{100, 60, 40, 30, 80, 10} {
+		source.balance = balance
+		low = s.checkBalance(low)
+	}
+	if len(alerts) != 2 || alerts[0] != 40 || alerts[1] != 10 {
+		t.Fatalf("Expect alerts on each threshold crossing, got %v", alerts)
+	}
+
+	// Query failure keeps the last state
+	source.err = errors.New("rpc")
+	if !s.checkBalance(true) || s.checkBalance(false) {
+		t.Fatal("Balance state changed on query failure")
+	}
+}
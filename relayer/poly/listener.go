@@ -18,9 +18,14 @@
 package poly
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/polynetwork/bridge-common/base"
@@ -28,127 +33,505 @@ import (
 	"github.com/polynetwork/bridge-common/chains/poly"
 	"github.com/polynetwork/bridge-common/log"
 	"github.com/polynetwork/bridge-common/util"
+	sdkcom "github.com/polynetwork/poly-go-sdk/common"
 	"github.com/polynetwork/poly-relayer/config"
 	"github.com/polynetwork/poly-relayer/msg"
+	"github.com/polynetwork/poly/merkle"
 )
 
 type Listener struct {
-	sdk    *poly.SDK
-	config *config.ListenerConfig
+	sdk     *poly.SDK
+	config  *config.ListenerConfig
+	window  heightWindow
+	reorg   reorgTracker
+	limiter *rateLimiter // Optional rate limit of node calls
+	breaker *breaker     // Optional circuit breaker of node calls
+	dst     DstChecker   // Optional dst chain check to skip executed txs
+
+	nodes    *nodeSelector // Optional health aware node selection
+	confirms *confirmGate  // Optional txs held till their blocks are confirmed
+	heights  *heightCache  // Optional latest height shared by callers
+}
+
+// Dst chain check of poly txs executed on the dst chain
+type DstChecker interface {
+	DstExecuted(tx *msg.Tx) (bool, error)
+}
+
+// Skip the txs already executed on the dst chain when scanning poly txs with ScanDst
+func (l *Listener) SetDstChecker(checker DstChecker) {
+	l.dst = checker
 }
 
 func (l *Listener) Init(config *config.ListenerConfig, sdk *poly.SDK) (err error) {
 	l.config = config
+	if err = checkCCMAddress(config.CCMContract); err != nil {
+		return
+	}
+	l.limiter = newRateLimiter(config.RateLimit, config.RateBurst)
+	l.breaker = newBreaker("poly", config.BreakerThreshold, config.BreakerCooldown)
+	l.nodes = newNodeSelector(config.NodeHealth)
+	l.confirms = newConfirmGate(config.Confirmations)
+	l.heights = newHeightCache(config.HeightCacheTTL)
 	if sdk != nil {
 		l.sdk = sdk
 	} else {
-		l.sdk, err = poly.WithOptions(base.POLY, config.Nodes, time.Minute, 1)
+		interval, maxGap := sdkOptions(config.NodeCheckInterval, config.NodeMaxGap)
+		l.sdk, err = poly.WithOptions(base.POLY, config.Nodes, interval, maxGap)
+		detectRateLimits(l.sdk)
 	}
 	return
 }
 
 func (l *Listener) ScanDst(height uint64) (txs []*msg.Tx, err error) {
+	return l.ScanDstWithContext(context.Background(), height)
+}
+
+// Scan poly txs of the block and fetch their merkle proofs with bounded concurrency
+func (l *Listener) ScanDstWithContext(ctx context.Context, height uint64) (txs []*msg.Tx, err error) {
 	txs, err = l.Scan(height)
-	if err != nil { return }
-	sub := &Submitter{sdk:l.sdk}
-	for _, tx := range txs {
-		tx.MerkleValue, _, _, err = sub.GetProof(tx.PolyHeight, tx.PolyKey)
-		if err != nil { return }
+	if err != nil {
+		return
 	}
+	txs = l.skipExecuted(txs)
+	sub := &Submitter{sdk: l.sdk}
+	workers := 1
+	if l.config != nil && l.config.ProofWorkers > 0 {
+		workers = l.config.ProofWorkers
+	}
+	err = fetchParallel(ctx, len(txs), workers, func(i int) (err error) {
+		l.limiter.Wait()
+		txs[i].MerkleValue, _, _, err = sub.GetProof(txs[i].PolyHeight, txs[i].PolyKey)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	txs = l.allowedTxs(txs)
 	return
 }
 
+// Drop the txs of methods not allowed by the listener config
+func (l *Listener) allowedTxs(txs []*msg.Tx) []*msg.Tx {
+	allowed := txs[:0]
+	for _, tx := range txs {
+		if tx.MerkleValue != nil && tx.MerkleValue.MakeTxParam != nil && !l.config.AllowMethod(tx.MerkleValue.MakeTxParam.Method) {
+			log.Debug("Skipping poly tx of disallowed method", "poly_hash", tx.PolyHash, "method", tx.MerkleValue.MakeTxParam.Method)
+			continue
+		}
+		allowed = append(allowed, tx)
+	}
+	return allowed
+}
+
+// Drop the txs executed on the dst chain, keeping the txs failed to check
+func (l *Listener) skipExecuted(txs []*msg.Tx) []*msg.Tx {
+	if l.dst == nil {
+		return txs
+	}
+	pending := make([]*msg.Tx, 0, len(txs))
+	for _, tx := range txs {
+		executed, err := l.dst.DstExecuted(tx)
+		if err != nil {
+			log.Warn("Failed to check poly tx execution on dst chain", "poly_hash", tx.PolyHash, "dst_chain", tx.DstChainId, "err", err)
+		} else if executed {
+			log.Debug("Skipping poly tx executed on dst chain", "poly_hash", tx.PolyHash, "dst_chain", tx.DstChainId)
+			continue
+		}
+		pending = append(pending, tx)
+	}
+	return pending
+}
+
+// Run fetch for indexes [0, count) with at most workers running at once, returns the first error.
+// Pending fetches are skipped once an error occurs or the context is canceled.
+func fetchParallel(ctx context.Context, count, workers int, fetch func(int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+	)
+	fail := func(e error) {
+		once.Do(func() {
+			err = e
+			cancel()
+		})
+	}
+	sem := make(chan struct{}, workers)
+	for i := 0; i < count; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if ctx.Err() != nil {
+				return
+			}
+			if e := fetch(i); e != nil {
+				fail(e)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
 func (l *Listener) Scan(height uint64) (txs []*msg.Tx, err error) {
-	events, err := l.sdk.Node().GetSmartContractEventByBlock(uint32(height))
+	// Invalid heights are rejected before counting as node failures in the breaker
+	block, err := scanHeight(height)
+	if err != nil {
+		return
+	}
+	err = l.breaker.Call(func() (err error) {
+		txs, err = l.scan(block)
+		return
+	})
+	if err != nil || l.confirms == nil {
+		return
+	}
+	var latest uint64
+	err = l.breaker.Call(func() (err error) {
+		latest, err = l.LatestHeight()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l.confirms.Hold(height, txs, latest), nil
+}
+
+func (l *Listener) scan(block uint32) (txs []*msg.Tx, err error) {
+	height := uint64(block)
+	if l.config != nil && l.config.CheckReorg {
+		err = l.checkReorg(l.node(), height)
+		if err != nil {
+			return nil, err
+		}
+	}
+	node := l.node()
+	start := time.Now()
+	events, err := node.GetSmartContractEventByBlock(block)
+	l.nodes.Report(node, time.Since(start), err)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, event := range events {
-		for _, notify := range event.Notify {
-			if notify.ContractAddress == poly.CCM_ADDRESS {
-				states := notify.States.([]interface{})
-				if len(states) < 6 {
-					continue
-				}
-				method, _ := states[0].(string)
-				if method != "makeProof" {
-					continue
-				}
+		for _, tx := range makeProofTxs(event, l.ccm()) {
+			tx.PolyHeight = block
+			txs = append(txs, tx)
+		}
+	}
+
+	return
+}
+
+// Buffered txs of ScanStream ahead of the consumer
+const SCAN_STREAM_BUFFER = 16
+
+// Scan poly txs of the block tx by tx, emitting the txs as found instead of loading all the block events at once,
+// which suits blocks with huge event counts. Txs of blocks without enough confirmations are held as by Scan.
+// The tx channel is closed when the scan ends and should be drained, then the error channel yields the scan error if any.
+func (l *Listener) ScanStream(height uint64) (<-chan *msg.Tx, <-chan error) {
+	txs := make(chan *msg.Tx, SCAN_STREAM_BUFFER)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(txs)
+		err := l.scanStream(height, txs)
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return txs, errs
+}
+
+func (l *Listener) scanStream(height uint64, txs chan<- *msg.Tx) (err error) {
+	height32, err := scanHeight(height)
+	if err != nil {
+		return
+	}
+	if l.config != nil && l.config.CheckReorg {
+		err = l.checkReorg(l.node(), height)
+		if err != nil {
+			return
+		}
+	}
+	// Txs of a block not confirmed yet are held by the confirm gate as the batch scan does
+	var latest uint64
+	if l.confirms != nil {
+		err = l.breaker.Call(func() (err error) {
+			latest, err = l.LatestHeight()
+			return
+		})
+		if err != nil {
+			return
+		}
+		for _, tx := range l.confirms.Hold(height, nil, latest) {
+			txs <- tx
+		}
+	}
+	held := l.confirms != nil && !l.confirms.confirmed(&msg.Tx{PolyHeight: height32}, latest)
+	var pending []*msg.Tx
+
+	var block *sdkcom.BlockTxHashes
+	err = l.breaker.Call(func() (err error) {
+		node, start := l.node(), time.Now()
+		block, err = node.GetBlockTxHashesByHeight(height32)
+		l.nodes.Report(node, time.Since(start), err)
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to fetch poly block txs at height %d, %v", height, err)
+	}
+	for _, hash := range block.Transactions {
+		var event *sdkcom.SmartContactEvent
+		err = l.breaker.Call(func() (err error) {
+			node, start := l.node(), time.Now()
+			event, err = node.GetSmartContractEvent(hash.ToHexString())
+			l.nodes.Report(node, time.Since(start), err)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to fetch poly tx %s event at height %d, %v", hash.ToHexString(), height, err)
+		}
+		if event == nil {
+			continue
+		}
+		for _, tx := range makeProofTxs(event, l.ccm()) {
+			tx.PolyHeight = height32
+			if held {
+				pending = append(pending, tx)
+			} else {
+				txs <- tx
+			}
+		}
+	}
+	if held {
+		for _, tx := range l.confirms.Hold(height, pending, latest) {
+			txs <- tx
+		}
+	}
+	return
+}
 
-				dstChain := uint64(states[2].(float64))
-				if dstChain == 0 {
-					log.Error("Invalid dst chain id in poly tx", "hash", event.TxHash)
+// Follow scans poly blocks from the start height on as they are confirmed by Defer blocks and streams the txs,
+// till the context is done. Blocks are scanned one by one without gaps, and a slow consumer holds back the scan.
+// On a scan failure the error channel reports the height to restart Follow from.
+func (l *Listener) Follow(ctx context.Context, start uint64) (<-chan *msg.Tx, <-chan error) {
+	txs := make(chan *msg.Tx, SCAN_STREAM_BUFFER)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(txs)
+		height, err := l.follow(ctx, start, txs)
+		if err != nil {
+			errs <- fmt.Errorf("Follow poly txs stopped at height %d, %w", height, err)
+		}
+	}()
+	return txs, errs
+}
+
+func (l *Listener) follow(ctx context.Context, height uint64, txs chan<- *msg.Tx) (uint64, error) {
+	confirms := uint64(l.Defer())
+	var latest uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return height, nil
+		default:
+		}
+		if latest < height+confirms {
+			var err error
+			latest, err = l.LatestHeight()
+			if err != nil {
+				if l.throttled(ctx, err) {
 					continue
 				}
-
-				tx := new(msg.Tx)
-				tx.DstChainId = dstChain
-				tx.PolyKey = states[5].(string)
-				tx.PolyHeight = uint32(height)
-				tx.PolyHash = event.TxHash
-				tx.TxType = msg.POLY
-				tx.TxId = states[3].(string)
-				tx.SrcChainId = uint64(states[1].(float64))
-				switch tx.SrcChainId {
-				case base.NEO, base.NEO3, base.ONT:
-					tx.TxId = util.ReverseHex(tx.TxId)
+				return height, err
+			}
+			if latest < height+confirms {
+				select {
+				case <-ctx.Done():
+				case <-time.After(l.ListenCheck()):
 				}
-				txs = append(txs, tx)
+				continue
 			}
 		}
+		log.Debug("Following poly txs in block", "height", height, "latest", latest)
+		block, err := l.Scan(height)
+		if err != nil {
+			var reorg *msg.ReorgError
+			if errors.As(err, &reorg) && reorg.Height > 0 && reorg.Height <= height {
+				log.Warn("Rewinding poly tx follow for chain reorg", "height", height, "fork", reorg.Height)
+				height = reorg.Height
+				continue
+			}
+			if l.throttled(ctx, err) {
+				continue
+			}
+			return height, err
+		}
+		for _, tx := range block {
+			select {
+			case txs <- tx:
+			case <-ctx.Done():
+				return height, nil
+			}
+		}
+		height++
+	}
+}
+
+// Height to mark as synced once the height is scanned, the blocks of the txs held till confirmed are scanned again
+// after a restart
+func (l *Listener) Checkpoint(height uint64) uint64 {
+	return l.confirms.Checkpoint(height)
+}
+
+// Wait for the Retry-After hint, or a listen check without one, when the poly node is rate limited
+func (l *Listener) throttled(ctx context.Context, err error) bool {
+	delay, limited := retryAfter(err)
+	if !limited {
+		return false
+	}
+	if delay == 0 {
+		delay = l.ListenCheck()
+	}
+	log.Warn("Poly node rate limited, pausing the follow", "retry_after", delay)
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
 	}
+	return true
+}
+
+// Poly block height to scan, heights beyond uint32 would be truncated to another block and the genesis block has no txs
+func scanHeight(height uint64) (uint32, error) {
+	if height == 0 || height > math.MaxUint32 {
+		return 0, fmt.Errorf("Invalid poly scan height %d", height)
+	}
+	return uint32(height), nil
+}
 
+// Poly cross chain manager contract of the listener
+func (l *Listener) ccm() string {
+	if l.config == nil {
+		return ccmAddress("")
+	}
+	return ccmAddress(l.config.CCMContract)
+}
+
+// Poly txs of the makeProof notifies in the tx event
+func makeProofTxs(event *sdkcom.SmartContactEvent, contract string) (txs []*msg.Tx) {
+	for _, notify := range event.Notify {
+		if notify.ContractAddress != contract {
+			continue
+		}
+		states, _ := notify.States.([]interface{})
+		if !msg.IsMakeProof(states) {
+			continue
+		}
+		tx, err := msg.ParseMakeProofStates(states)
+		if err != nil {
+			log.Error("Invalid makeProof notify in poly tx", "hash", event.TxHash, "err", err)
+			continue
+		}
+		tx.PolyHash = event.TxHash
+		txs = append(txs, tx)
+	}
 	return
 }
 
+// Poly node to call, throttled by the rate limiter
+func (l *Listener) node() *poly.Client {
+	l.limiter.Wait()
+	return l.nodes.Node(l.sdk)
+}
+
+// Health of the poly nodes used by the listener, empty unless node quarantine is configured
+func (l *Listener) NodeHealth() []NodeHealth {
+	return l.nodes.Health()
+}
+
 func (l *Listener) GetTxBlock(hash string) (height uint64, err error) {
-	h, err := l.sdk.Node().GetBlockHeightByTxHash(hash)
+	h, err := l.node().GetBlockHeightByTxHash(hash)
 	height = uint64(h)
 	return
 }
 
 func (l *Listener) ScanTx(hash string) (tx *msg.Tx, err error) {
-	return l.scanTx(l.sdk.Node(), hash)
+	return l.scanTx(l.node(), hash)
+}
+
+// Poly rpc expects lower case tx hash without '0x'
+func normalizeHash(hash string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(hash)), "0x")
+}
+
+// Concurrent event fetches of ScanTxs
+const SCAN_TXS_WORKERS = 8
+
+// Errors of ScanTxs aligned to the input hashes, nil for the hashes scanned
+type ScanTxsErrors []error
+
+func (e ScanTxsErrors) Error() string {
+	info := []string{}
+	for i, err := range e {
+		if err != nil {
+			info = append(info, fmt.Sprintf("tx %d: %v", i, err))
+		}
+	}
+	return strings.Join(info, "; ")
+}
+
+// Scan the poly txs of the hashes with bounded concurrency, txs are aligned to the hashes and left nil
+// for the hashes failed, whose errors are collected in ScanTxsErrors instead of aborting the batch.
+func (l *Listener) ScanTxs(hashes []string) ([]*msg.Tx, error) {
+	txs := make([]*msg.Tx, len(hashes))
+	errs := make(ScanTxsErrors, len(hashes))
+	var failed int32
+	fetchParallel(context.Background(), len(hashes), SCAN_TXS_WORKERS, func(i int) error {
+		txs[i], errs[i] = l.scanTx(l.node(), hashes[i])
+		if errs[i] != nil {
+			atomic.AddInt32(&failed, 1)
+		}
+		return nil
+	})
+	if failed > 0 {
+		return txs, errs
+	}
+	return txs, nil
 }
 
 func (l *Listener) scanTx(node *poly.Client, hash string) (tx *msg.Tx, err error) {
-	//hash hasn't '0x'
+	hash = normalizeHash(hash)
+	start := time.Now()
 	event, err := node.GetSmartContractEvent(hash)
+	l.nodes.Report(node, time.Since(start), err)
 	if err != nil {
 		return nil, err
 	}
-	for _, notify := range event.Notify {
-		if notify.ContractAddress == poly.CCM_ADDRESS {
-			states := notify.States.([]interface{})
-			if len(states) < 6 {
-				continue
-			}
-			method, _ := states[0].(string)
-			if method != "makeProof" {
-				continue
-			}
-
-			dstChain := uint64(states[2].(float64))
-			if dstChain == 0 {
-				log.Error("Invalid dst chain id in poly tx", "hash", event.TxHash)
-				continue
-			}
-
-			tx := new(msg.Tx)
-			tx.DstChainId = dstChain
-			tx.PolyKey = states[5].(string)
-			tx.PolyHeight = uint32(states[4].(float64))
-			tx.PolyHash = event.TxHash
-			tx.TxType = msg.POLY
-			tx.TxId = states[3].(string)
-			tx.SrcChainId = uint64(states[1].(float64))
-			switch tx.SrcChainId {
-			case base.NEO, base.NEO3, base.ONT:
-				tx.TxId = util.ReverseHex(tx.TxId)
-			}
-			return tx, nil
-		}
+	if txs := makeProofTxs(event, l.ccm()); len(txs) > 0 {
+		return txs[0], nil
 	}
 	return nil, errors.New(fmt.Sprintf("hash:%v hasn't event", hash))
 }
@@ -157,21 +540,62 @@ func (l *Listener) ChainId() uint64 {
 	return base.POLY
 }
 
+// Compose waits for the poly tx to be buried under FinalityDepth blocks, checking every ListenCheck
+// for at most FinalityWait seconds. Fails with the retryable msg.ERR_TX_NOT_FINAL on timeout, no wait
+// unless both are set.
 func (l *Listener) Compose(tx *msg.Tx) (err error) {
+	if l.config == nil || l.config.FinalityDepth == 0 || l.config.FinalityWait <= 0 {
+		return
+	}
+	deadline := time.Now().Add(time.Duration(l.config.FinalityWait) * time.Second)
+	for {
+		err = checkFinal(l.node(), tx.PolyHeight, l.config.FinalityDepth)
+		if err == nil {
+			return
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(l.ListenCheck())
+	}
+	if !errors.Is(err, msg.ERR_TX_NOT_FINAL) {
+		err = fmt.Errorf("%w, poly tx %s height %d: %v", msg.ERR_TX_NOT_FINAL, tx.PolyHash, tx.PolyHeight, err)
+	}
 	return
 }
 
+// Poly blocks to wait for before scanning, configured by listener Defer
 func (l *Listener) Defer() int {
+	if l.config != nil && l.config.Defer > 0 {
+		return l.config.Defer
+	}
 	return 1
 }
 
-func (l *Listener) Header(uint64) (header []byte, hash []byte, err error) {
-	return
+// Serialized poly block header and its hash at the height
+func (l *Listener) Header(height uint64) (header []byte, hash []byte, err error) {
+	return l.header(l.node(), height)
+}
+
+func (l *Listener) header(node *poly.Client, height uint64) (header []byte, hash []byte, err error) {
+	if height > math.MaxUint32 {
+		return nil, nil, fmt.Errorf("Poly header height %d out of range", height)
+	}
+	hdr, err := node.GetHeaderByHeight(uint32(height))
+	if err != nil {
+		latest, e := node.GetCurrentBlockHeight()
+		if e == nil && uint64(latest) < height {
+			return nil, nil, fmt.Errorf("Poly header height %d out of range, latest height %d", height, latest)
+		}
+		return nil, nil, fmt.Errorf("Fetch poly header %d error %v", height, err)
+	}
+	h := hdr.Hash()
+	return hdr.ToArray(), h.ToArray(), nil
 }
 
 func (l *Listener) ListenCheck() time.Duration {
 	duration := time.Second
-	if l.config.ListenCheck > 0 {
+	if l.config != nil && l.config.ListenCheck > 0 {
 		duration = time.Duration(l.config.ListenCheck) * time.Second
 	}
 	return duration
@@ -181,38 +605,202 @@ func (l *Listener) Nodes() chains.Nodes {
 	return l.sdk.ChainSDK
 }
 
-func (l *Listener) LastHeaderSync(uint64, uint64) (uint64, error) {
-	return 0, nil
+// Last synced header height of the listening chain recorded on poly, force height takes precedence
+func (l *Listener) LastHeaderSync(force, last uint64) (uint64, error) {
+	return l.lastHeaderSync(l.node(), force, last)
 }
 
+func (l *Listener) lastHeaderSync(node *poly.Client, force, last uint64) (uint64, error) {
+	if force != 0 {
+		return force, nil
+	}
+	// Poly headers are not synced to poly itself
+	if l.config == nil || l.config.ChainId == 0 || l.config.ChainId == base.POLY {
+		return last, nil
+	}
+	return node.GetSideChainHeight(l.config.ChainId)
+}
+
+// Latest poly height, shared between callers within HeightCacheTTL
 func (l *Listener) LatestHeight() (uint64, error) {
-	return l.sdk.Node().GetLatestHeight()
+	return l.heights.get(false, l.latestHeight)
 }
 
+// Latest poly height fetched from the node regardless of the cache, refreshing the cached height
+func (l *Listener) RefreshLatestHeight() (uint64, error) {
+	return l.heights.get(true, l.latestHeight)
+}
 
+func (l *Listener) latestHeight() (uint64, error) {
+	return l.node().GetLatestHeight()
+}
+
+
+// Node validation reads the sdk tracked heights, never the cached latest height, to see the real movement
 func (l *Listener) ValidateNodes() (err error) {
 	if l.sdk.Delta() <= 0 {
-		err = fmt.Errorf("No height increment since last update for chain %d", l.ChainId())
+		return fmt.Errorf("No height increment since last update for chain %d", l.ChainId())
+	}
+	if l.config == nil || l.config.MinHeightDelta == 0 || l.config.HeightDeltaWindow <= 0 {
+		return
+	}
+	window := time.Duration(l.config.HeightDeltaWindow) * time.Second
+	delta, ok := l.window.Observe(time.Now(), l.sdk.Height(), window)
+	if ok && delta < l.config.MinHeightDelta {
+		err = fmt.Errorf("Height increment %d below %d within %s for chain %d", delta, l.config.MinHeightDelta, window, l.ChainId())
 	}
 	return
 }
 
-func (l *Listener) Validate(tx *msg.Tx) (err error) {
-	err = l.validate(l.sdk.Node(), tx)
-	if err == nil {
+type heightSample struct {
+	time   time.Time
+	height uint64
+}
+
+// Track chain heights to measure growth over a sliding time window
+type heightWindow struct {
+	sync.Mutex
+	samples []heightSample
+}
+
+// Observe records the height and returns the growth since the latest sample at least window old,
+// ok is false until enough history is collected.
+func (w *heightWindow) Observe(now time.Time, height uint64, window time.Duration) (delta uint64, ok bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.samples = append(w.samples, heightSample{now, height})
+	base := -1
+	for i, sample := range w.samples {
+		if now.Sub(sample.time) >= window {
+			base = i
+		}
+	}
+	if base < 0 {
 		return
 	}
-	
-	for _, node := range l.sdk.AllNodes() {
-		e := l.validate(node, tx)
-		if e == nil {
-			return
+	w.samples = w.samples[base:]
+	if height > w.samples[0].height {
+		delta = height - w.samples[0].height
+	}
+	return delta, true
+}
+
+func (l *Listener) Validate(tx *msg.Tx) (err error) {
+	return validateOnNodes(l.node(), l.sdk.AllNodes(), func(node *poly.Client) error {
+		return l.validate(node, tx)
+	})
+}
+
+// Validate on the primary node first, then the remaining nodes until one succeeds. Fails with the
+// NodeErrors of every node tried, or the primary node error when the tx misses the dst proxy.
+func validateOnNodes(primary *poly.Client, nodes []*poly.Client, validate func(*poly.Client) error) error {
+	err := validate(primary)
+	if err == nil || errors.Is(err, msg.ERR_MISSING_DST_PROXY) {
+		return err
+	}
+	errs := NodeErrors{fmt.Errorf("node %s: %w", primary.Address(), err)}
+	for _, node := range nodes {
+		if node == primary {
+			continue
 		}
+		err = validate(node)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("node %s: %w", node.Address(), err))
 	}
-	return
+	return errs
+}
+
+// ValidateQuorum validates the tx against all poly nodes and requires at least threshold nodes to agree,
+// threshold defaults to the majority of nodes when not positive.
+func (l *Listener) ValidateQuorum(tx *msg.Tx, threshold int) error {
+	return validateQuorum(l.sdk.AllNodes(), threshold, func(node *poly.Client) error {
+		return l.validate(node, tx)
+	})
+}
+
+func validateQuorum(nodes []*poly.Client, threshold int, validate func(*poly.Client) error) error {
+	if threshold <= 0 {
+		threshold = len(nodes)/2 + 1
+	}
+	agreed := 0
+	var dissents, failures NodeErrors
+	for i, node := range nodes {
+		err := validate(node)
+		if err == nil {
+			agreed++
+		} else if errors.Is(err, msg.ERR_TX_VOILATION) {
+			dissents = append(dissents, fmt.Errorf("node %d %s: %v", i, node.Address(), err))
+		} else {
+			failures = append(failures, fmt.Errorf("node %d %s: %v", i, node.Address(), err))
+		}
+	}
+	if len(dissents) > 0 {
+		log.Error("Poly nodes dissented on tx validation", "agreed", agreed, "dissents", dissents)
+	}
+	if agreed >= threshold {
+		return nil
+	}
+	info := fmt.Sprintf("%d of %d nodes agreed with threshold %d", agreed, len(nodes), threshold)
+	if len(dissents) > 0 {
+		info = fmt.Sprintf("%s, dissented: %v", info, dissents)
+	}
+	if len(failures) > 0 {
+		info = fmt.Sprintf("%s, failed: %v", info, failures)
+	}
+	return fmt.Errorf("%w, %s", msg.ERR_QUORUM_NOT_REACHED, info)
+}
+
+// ValidateFinal validates the tx like Validate and requires the poly tx to be buried under
+// FinalityDepth blocks, failing with msg.ERR_TX_NOT_FINAL otherwise.
+func (l *Listener) ValidateFinal(tx *msg.Tx) (err error) {
+	var depth uint64
+	if l.config != nil {
+		depth = l.config.FinalityDepth
+	}
+	return validateOnNodes(l.node(), l.sdk.AllNodes(), func(node *poly.Client) error {
+		return l.validateFinal(node, tx, depth)
+	})
 }
 
 func (l *Listener) validate(node *poly.Client, tx *msg.Tx) (err error) {
+	return l.validateFinal(node, tx, 0)
+}
+
+// Check the poly tx has depth blocks on top of it
+func checkFinal(node *poly.Client, height uint32, depth uint64) error {
+	latest, err := node.GetLatestHeight()
+	if err != nil {
+		return err
+	}
+	if uint64(height)+depth > latest {
+		return fmt.Errorf("%w, poly tx height %d latest %d finality depth %d", msg.ERR_TX_NOT_FINAL, height, latest, depth)
+	}
+	return nil
+}
+
+// Verify the audit path proves to the cross states root of the poly header following the proof height
+func verifyStateRoot(node *poly.Client, height uint32, path string) error {
+	raw, err := hex.DecodeString(path)
+	if err != nil {
+		return fmt.Errorf("%w, decode error %v", msg.ERR_AUDIT_PATH_INVALID, err)
+	}
+	hdr, err := node.GetHeaderByHeight(height + 1)
+	if err != nil {
+		return fmt.Errorf("Fetch poly header %d error %v", height+1, err)
+	}
+	_, err = merkle.MerkleProve(raw, hdr.CrossStateRoot[:])
+	if err != nil {
+		return fmt.Errorf("%w cross states root of poly header %d does not match: %v", msg.ERR_TX_VOILATION, height+1, err)
+	}
+	return nil
+}
+
+func (l *Listener) validateFinal(node *poly.Client, tx *msg.Tx, depth uint64) (err error) {
+	if tx.DstProxy == "" {
+		return fmt.Errorf("%w, poly tx %s dst chain %d", msg.ERR_MISSING_DST_PROXY, tx.PolyHash, tx.DstChainId)
+	}
 	t, err := l.scanTx(node, tx.PolyHash)
 	if err != nil { return }
 	if t == nil {
@@ -224,12 +812,20 @@ func (l *Listener) validate(node *poly.Client, tx *msg.Tx) (err error) {
 	if tx.DstChainId != t.DstChainId {
 		return fmt.Errorf("%w DstChainID does not match: %v, was %v", msg.ERR_TX_VOILATION, tx.DstChainId, t.DstChainId)
 	}
+	if depth > 0 {
+		err = checkFinal(node, t.PolyHeight, depth)
+		if err != nil { return }
+	}
 	sub := &Submitter{sdk:l.sdk}
-	value, _, _, err := sub.getProof(node, t.PolyHeight, t.PolyKey)
+	value, path, _, err := sub.GetProofFromNode(node, t.PolyHeight, t.PolyKey)
 	if err != nil { return }
 	if value == nil {
 		return msg.ERR_TX_PROOF_MISSING
 	}
+	if l.config != nil && l.config.VerifyStateRoot {
+		err = verifyStateRoot(node, t.PolyHeight, path)
+		if err != nil { return }
+	}
 	a := util.LowerHex(hex.EncodeToString(value.MakeTxParam.ToContractAddress))
 	b := util.LowerHex(tx.DstProxy)
 	if a != b {
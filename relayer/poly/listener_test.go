@@ -0,0 +1,691 @@
+package poly
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	"github.com/polynetwork/poly/merkle"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestFetchParallel(t *testing.T) {
+	var running, peak int32
+	results := make([]int, 20)
+	err := fetchParallel(context.Background(), len(results), 3, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		// Finish later indexes first to shuffle completion order
+		time.Sleep(time.Duration(len(results)-i) * time.Millisecond)
+		results[i] = i * i
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > 3 {
+		t.Fatalf("Concurrency limit exceeded, peak %d", peak)
+	}
+	for i, v := range results {
+		if v != i*i {
+			t.Fatalf("Result order broken at %d: %d", i, v)
+		}
+	}
+
+	fail := errors.New("proof")
+	var calls int32
+	err = fetchParallel(context.Background(), 100, 2, func(i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 1 {
+			return fail
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if !errors.Is(err, fail) {
+		t.Fatalf("Expect first error returned, got %v", err)
+	}
+	if calls == 100 {
+		t.Fatalf("Pending fetches not aborted after error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = fetchParallel(ctx, 100, 2, func(i int) error {
+		if atomic.AddInt32(&calls, 1) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expect context canceled, got %v", err)
+	}
+	if calls == 100 {
+		t.Fatalf("Pending fetches not aborted after cancel")
+	}
+}
+
+func TestValidateMissingDstProxy(t *testing.T) {
+	l := new(Listener)
+	err := l.validate(nil, &msg.Tx{PolyHash: "hash", DstChainId: 2})
+	if !errors.Is(err, msg.ERR_MISSING_DST_PROXY) {
+		t.Fatalf("Expect missing dst proxy error, got %v", err)
+	}
+	if errors.Is(err, msg.ERR_TX_VOILATION) {
+		t.Fatalf("Missing dst proxy should not be reported as violation")
+	}
+}
+
+func TestValidateFinal(t *testing.T) {
+	var latest uint64
+	value := &ccom.ToMerkleValue{MakeTxParam: &ccom.MakeTxParam{ToContractAddress: []byte{1, 2}}}
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getsmartcodeevent":
+			return map[string]interface{}{"TxHash": "hash", "State": 1, "Notify": []interface{}{
+				map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS, "States": []interface{}{"makeProof", 2, 6, "ab", 100, "key"}},
+			}}, nil
+		case "getblockcount":
+			return latest + 1, nil
+		case "getcrossstatesproof":
+			return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	l := new(Listener)
+	tx := &msg.Tx{PolyHash: "hash", SrcChainId: 2, DstChainId: 6, DstProxy: "0102"}
+	cases := []struct {
+		latest, depth uint64
+		final         bool
+	}{
+		{104, 0, true},
+		{104, 5, false},
+		{105, 5, true},
+		{200, 5, true},
+	}
+	for i, c := range cases {
+		latest = c.latest
+		err := l.validateFinal(node, tx, c.depth)
+		if c.final && err != nil || !c.final && !errors.Is(err, msg.ERR_TX_NOT_FINAL) {
+			t.Fatalf("Case %d latest %d depth %d unexpected result %v", i, c.latest, c.depth, err)
+		}
+	}
+}
+
+func TestAllowedTxs(t *testing.T) {
+	tx := func(hash, method string) *msg.Tx {
+		return &msg.Tx{PolyHash: hash, MerkleValue: &ccom.ToMerkleValue{MakeTxParam: &ccom.MakeTxParam{Method: method}}}
+	}
+	scan := func() []*msg.Tx {
+		return []*msg.Tx{tx("a", "unlock"), tx("b", "swap"), {PolyHash: "c"}, tx("d", "unlock")}
+	}
+	hashes := func(txs []*msg.Tx) (list []string) {
+		for _, tx := range txs {
+			list = append(list, tx.PolyHash)
+		}
+		return
+	}
+	l := &Listener{config: &config.ListenerConfig{}}
+	if list := hashes(l.allowedTxs(scan())); strings.Join(list, "") != "abcd" {
+		t.Fatalf("Expect all txs without method allowlist, got %v", list)
+	}
+	l.config.Methods = []string{"unlock"}
+	if list := hashes(l.allowedTxs(scan())); strings.Join(list, "") != "acd" {
+		t.Fatalf("Expect disallowed method filtered, got %v", list)
+	}
+}
+
+func TestHeightWindow(t *testing.T) {
+	w := new(heightWindow)
+	start := time.Now()
+	window := 10 * time.Minute
+
+	// Slow node advancing one block per five minutes
+	if _, ok := w.Observe(start, 100, window); ok {
+		t.Fatal("Expect no verdict without enough history")
+	}
+	if _, ok := w.Observe(start.Add(5*time.Minute), 101, window); ok {
+		t.Fatal("Expect no verdict without enough history")
+	}
+	delta, ok := w.Observe(start.Add(10*time.Minute), 102, window)
+	if !ok || delta != 2 {
+		t.Fatalf("Unexpected delta %d %v", delta, ok)
+	}
+	delta, ok = w.Observe(start.Add(15*time.Minute), 103, window)
+	if !ok || delta != 2 {
+		t.Fatalf("Unexpected delta %d %v", delta, ok)
+	}
+	if len(w.samples) != 3 {
+		t.Fatalf("Stale samples not dropped, size %d", len(w.samples))
+	}
+
+	// Healthy growth
+	delta, ok = w.Observe(start.Add(25*time.Minute), 700, window)
+	if !ok || delta != 597 {
+		t.Fatalf("Unexpected delta %d %v", delta, ok)
+	}
+}
+
+func TestNormalizeHash(t *testing.T) {
+	expected := "a1b2c3"
+	for _, hash := range []string{"a1b2c3", "0xa1b2c3", "0XA1B2C3", "A1B2C3", " 0xA1b2C3 "} {
+		if v := normalizeHash(hash); v != expected {
+			t.Fatalf("Unexpected normalized hash of %q: %s", hash, v)
+		}
+	}
+}
+
+func TestValidateQuorum(t *testing.T) {
+	a, b, c := new(poly.Client), new(poly.Client), new(poly.Client)
+	nodes := []*poly.Client{a, b, c}
+	dissent := func(node *poly.Client) error {
+		if node == b {
+			return fmt.Errorf("%w ToContract does not match", msg.ERR_TX_VOILATION)
+		}
+		return nil
+	}
+	if err := validateQuorum(nodes, 0, dissent); err != nil {
+		t.Fatalf("Expect majority quorum with one dissent, got %v", err)
+	}
+	err := validateQuorum(nodes, 3, dissent)
+	if !errors.Is(err, msg.ERR_QUORUM_NOT_REACHED) || !strings.Contains(err.Error(), "node 1") {
+		t.Fatalf("Expect quorum failure reporting dissented node, got %v", err)
+	}
+
+	err = validateQuorum(nodes, 0, func(node *poly.Client) error {
+		if node == a {
+			return nil
+		}
+		return errors.New("unavailable")
+	})
+	if !errors.Is(err, msg.ERR_QUORUM_NOT_REACHED) || strings.Contains(err.Error(), "dissented") {
+		t.Fatalf("Expect quorum failure without dissents, got %v", err)
+	}
+}
+
+func TestValidateOnNodes(t *testing.T) {
+	idle := func(method string, params []interface{}) (interface{}, error) { return nil, nil }
+	a, b, c := testPolyNode(t, idle), testPolyNode(t, idle), testPolyNode(t, idle)
+	nodes := []*poly.Client{a, b, c}
+	reasons := map[*poly.Client]error{
+		a: errors.New("connection refused"),
+		b: fmt.Errorf("%w DstChainID does not match", msg.ERR_TX_VOILATION),
+		c: errors.New("tx not found"),
+	}
+
+	// Every node failure is reported with its endpoint
+	var tried []*poly.Client
+	err := validateOnNodes(b, nodes, func(node *poly.Client) error {
+		tried = append(tried, node)
+		return reasons[node]
+	})
+	errs, ok := err.(NodeErrors)
+	if !ok || len(errs) != 3 || len(tried) != 3 || tried[0] != b {
+		t.Fatalf("Expect errors of all nodes with primary first, got %v", err)
+	}
+	for _, node := range nodes {
+		if !strings.Contains(err.Error(), node.Address()+": "+reasons[node].Error()) {
+			t.Fatalf("Expect error of node %s in %v", node.Address(), err)
+		}
+	}
+	if !errors.Is(err, msg.ERR_TX_VOILATION) {
+		t.Fatalf("Expect violation matched in node errors, got %v", err)
+	}
+
+	// Stops on the first success
+	tried = nil
+	err = validateOnNodes(a, nodes, func(node *poly.Client) error {
+		tried = append(tried, node)
+		if node == b {
+			return nil
+		}
+		return reasons[node]
+	})
+	if err != nil || len(tried) != 2 {
+		t.Fatalf("Expect validated on the second node, got %v after %d nodes", err, len(tried))
+	}
+}
+
+func TestLastHeaderSync(t *testing.T) {
+	var queried []interface{}
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		queried = params
+		value := make([]byte, 8)
+		binary.LittleEndian.PutUint64(value, 12345)
+		return hex.EncodeToString(value), nil
+	})
+	l := &Listener{config: &config.ListenerConfig{ChainId: base.ETH}}
+	height, err := l.lastHeaderSync(node, 0, 100)
+	if err != nil || height != 12345 {
+		t.Fatalf("Expect side chain height from poly, got %d %v", height, err)
+	}
+	if len(queried) != 2 {
+		t.Fatalf("Unexpected storage query %v", queried)
+	}
+
+	if height, _ = l.lastHeaderSync(node, 200, 100); height != 200 {
+		t.Fatalf("Expect forced height, got %d", height)
+	}
+
+	queried = nil
+	l.config.ChainId = base.POLY
+	if height, _ = l.lastHeaderSync(node, 0, 100); height != 100 || queried != nil {
+		t.Fatalf("Expect last height kept for poly chain, got %d", height)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	fixture := &types.Header{Height: 10, Timestamp: 1600000000, NextBookkeeper: pcom.Address{1}}
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 11, nil
+		case "getheaderbyheight":
+			if params[0] == float64(10) {
+				return hex.EncodeToString(fixture.ToArray()), nil
+			}
+		}
+		return nil, errors.New("unknown block")
+	})
+	l := new(Listener)
+	header, hash, err := l.header(node, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fixture.Hash()
+	if !bytes.Equal(header, fixture.ToArray()) || !bytes.Equal(hash, expected.ToArray()) {
+		t.Fatal("Header differs from fixture")
+	}
+
+	_, _, err = l.header(node, 11)
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatalf("Expect out of range error, got %v", err)
+	}
+	if _, _, err = l.header(node, 1<<32); err == nil {
+		t.Fatal("Expect out of range error for height overflow")
+	}
+}
+
+func TestDefer(t *testing.T) {
+	cases := []struct {
+		config *config.ListenerConfig
+		defers int
+	}{
+		{nil, 1},
+		{&config.ListenerConfig{}, 1},
+		{&config.ListenerConfig{Defer: 3}, 3},
+	}
+	for _, c := range cases {
+		l := &Listener{config: c.config}
+		if l.Defer() != c.defers {
+			t.Fatalf("Expect defer %d, got %d", c.defers, l.Defer())
+		}
+	}
+}
+
+type stubDstChecker map[string]error
+
+func (c stubDstChecker) DstExecuted(tx *msg.Tx) (bool, error) {
+	err, ok := c[tx.PolyHash]
+	return ok && err == nil, err
+}
+
+func TestSkipExecuted(t *testing.T) {
+	txs := []*msg.Tx{{PolyHash: "a"}, {PolyHash: "b"}, {PolyHash: "c"}, {PolyHash: "d"}}
+	l := new(Listener)
+	if len(l.skipExecuted(txs)) != 4 {
+		t.Fatal("Expect all txs kept without dst checker")
+	}
+	l.SetDstChecker(stubDstChecker{"a": nil, "c": nil, "d": errors.New("dst node down")})
+	pending := l.skipExecuted(txs)
+	if len(pending) != 2 || pending[0].PolyHash != "b" || pending[1].PolyHash != "d" {
+		t.Fatalf("Unexpected pending txs %v", pending)
+	}
+}
+
+func TestScanStream(t *testing.T) {
+	size := 2000
+	hashes := make([]string, size)
+	index := map[string]int{}
+	for i := range hashes {
+		hashes[i] = fmt.Sprintf("%064x", i+1)
+		index[hashes[i]] = i
+	}
+	fail := int64(-1)
+	l := &Listener{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		case "getblocktxsbyheight":
+			return map[string]interface{}{"Hash": fmt.Sprintf("%064x", 0), "Height": 100, "Transactions": hashes}, nil
+		case "getsmartcodeevent":
+			i := index[params[0].(string)]
+			if int64(i) == atomic.LoadInt64(&fail) {
+				return nil, fmt.Errorf("node down")
+			}
+			// Every other tx in the block carries a makeProof notify
+			notify := []interface{}{}
+			if i%2 == 0 {
+				notify = append(notify, map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS,
+					"States": []interface{}{"makeProof", 2, 6, fmt.Sprintf("%04x", i), 100, "key"}})
+			}
+			return map[string]interface{}{"TxHash": params[0], "State": 1, "Notify": notify}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+
+	txs, errs := l.ScanStream(100)
+	count := 0
+	for tx := range txs {
+		if tx.PolyHash != hashes[count*2] || tx.PolyHeight != 100 || tx.TxId != fmt.Sprintf("%04x", count*2) {
+			t.Fatalf("Unexpected tx %d %+v", count, tx)
+		}
+		count++
+	}
+	if err := <-errs; err != nil || count != size/2 {
+		t.Fatalf("Expect %d txs, got %d err %v", size/2, count, err)
+	}
+
+	// Scan ends with the error after the txs emitted before the failure
+	atomic.StoreInt64(&fail, 10)
+	txs, errs = l.ScanStream(100)
+	count = 0
+	for range txs {
+		count++
+	}
+	if err := <-errs; err == nil || count != 5 {
+		t.Fatalf("Expect failure after 5 txs, got %d err %v", count, err)
+	}
+}
+
+func TestScanHeightRange(t *testing.T) {
+	calls := int64(0)
+	l := &Listener{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		}
+		atomic.AddInt64(&calls, 1)
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+	for _, height := range []uint64{0, 1 << 32} {
+		if _, err := l.Scan(height); err == nil || !strings.Contains(err.Error(), "Invalid poly scan height") {
+			t.Fatalf("Expect invalid height error for %d, got %v", height, err)
+		}
+		txs, errs := l.ScanStream(height)
+		for range txs {
+			t.Fatalf("Expect no txs for height %d", height)
+		}
+		if err := <-errs; err == nil {
+			t.Fatalf("Expect stream error for height %d", height)
+		}
+	}
+	if atomic.LoadInt64(&calls) != 0 {
+		t.Fatal("Expect no node queries for invalid heights")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	latest, fail := int64(8), int64(-1)
+	l := &Listener{config: &config.ListenerConfig{}, sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return atomic.LoadInt64(&latest) + 1, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		case "getsmartcodeevent":
+			height := int64(params[0].(float64))
+			if height > atomic.LoadInt64(&latest) {
+				return nil, fmt.Errorf("block %d not confirmed", height)
+			}
+			if height == atomic.LoadInt64(&fail) {
+				return nil, fmt.Errorf("node down")
+			}
+			notify := []interface{}{map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS,
+				"States": []interface{}{"makeProof", 2, 6, fmt.Sprintf("%04x", height), height, "key"}}}
+			return []interface{}{map[string]interface{}{"TxHash": fmt.Sprintf("%064x", height), "State": 1, "Notify": notify}}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+	expect := func(txs <-chan *msg.Tx, heights ...int64) {
+		for _, height := range heights {
+			select {
+			case tx := <-txs:
+				if tx == nil || tx.PolyHeight != uint32(height) || tx.TxId != fmt.Sprintf("%04x", height) {
+					t.Fatalf("Expect tx of block %d, got %+v", height, tx)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timeout waiting for tx of block %d", height)
+			}
+		}
+	}
+
+	// Blocks are scanned once confirmed by the defer block on top
+	ctx, cancel := context.WithCancel(context.Background())
+	txs, errs := l.Follow(ctx, 5)
+	expect(txs, 5, 6, 7)
+	select {
+	case tx := <-txs:
+		t.Fatalf("Expect block 8 held till confirmed, got %+v", tx)
+	case <-time.After(100 * time.Millisecond):
+	}
+	atomic.StoreInt64(&latest, 11)
+	expect(txs, 8, 9, 10)
+	cancel()
+	for range txs {
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Expect clean stop on cancel, got %v", err)
+	}
+
+	// Failure reports the height to restart from
+	atomic.StoreInt64(&fail, 9)
+	txs, errs = l.Follow(context.Background(), 7)
+	expect(txs, 7, 8)
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "stopped at height 9") {
+		t.Fatalf("Expect follow stopped at height 9, got %v", err)
+	}
+	atomic.StoreInt64(&fail, -1)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	txs, _ = l.Follow(ctx, 9)
+	expect(txs, 9, 10)
+}
+
+func TestScanTxs(t *testing.T) {
+	proof := func(id string) interface{} {
+		return []interface{}{map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS,
+			"States": []interface{}{"makeProof", 2, 6, id, 100, "key"}}}
+	}
+	events := map[string]interface{}{
+		fmt.Sprintf("%064x", 1): proof("01"),
+		fmt.Sprintf("%064x", 2): []interface{}{},
+		fmt.Sprintf("%064x", 3): proof("03"),
+	}
+	l := &Listener{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		case "getsmartcodeevent":
+			hash := params[0].(string)
+			notify, ok := events[hash]
+			if !ok {
+				return nil, fmt.Errorf("unknown tx %s", hash)
+			}
+			return map[string]interface{}{"TxHash": hash, "State": 1, "Notify": notify}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+
+	hashes := []string{
+		fmt.Sprintf("0x%064x", 1),
+		fmt.Sprintf("%064x", 2),
+		fmt.Sprintf("%064X", 3),
+		fmt.Sprintf("%064x", 4),
+	}
+	txs, err := l.ScanTxs(hashes)
+	var errs ScanTxsErrors
+	if !errors.As(err, &errs) || len(txs) != len(hashes) || len(errs) != len(hashes) {
+		t.Fatalf("Expect aligned results with errors, got %d txs %v", len(txs), err)
+	}
+	for i, id := range []string{"01", "", "03", ""} {
+		if id == "" {
+			if txs[i] != nil || errs[i] == nil {
+				t.Fatalf("Expect error for hash %d, got %+v", i, txs[i])
+			}
+		} else if txs[i] == nil || txs[i].TxId != id || errs[i] != nil {
+			t.Fatalf("Expect tx %s for hash %d, got %+v %v", id, i, txs[i], errs[i])
+		}
+	}
+	if !strings.Contains(errs[1].Error(), "hasn't event") {
+		t.Fatalf("Expect eventless tx error, got %v", errs[1])
+	}
+
+	txs, err = l.ScanTxs(hashes[:1])
+	if err != nil || len(txs) != 1 || txs[0].TxId != "01" {
+		t.Fatalf("Expect batch scanned without errors, got %v", err)
+	}
+}
+
+func TestComposeFinality(t *testing.T) {
+	var latest uint64 = 100
+	l := &Listener{
+		config: &config.ListenerConfig{FinalityDepth: 2, FinalityWait: 5},
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				latest++
+				return latest, nil
+			case "getheaderbyheight":
+				return hex.EncodeToString((&types.Header{}).ToArray()), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	latest = 99
+	tx := &msg.Tx{PolyHash: "hash", PolyHeight: 100}
+	if err := l.Compose(tx); err != nil || latest != 103 {
+		t.Fatalf("Expect poly tx final after waiting, got latest %d err %v", latest, err)
+	}
+
+	l.config.FinalityWait = 1
+	tx.PolyHeight = 1000
+	start := time.Now()
+	err := l.Compose(tx)
+	if !errors.Is(err, msg.ERR_TX_NOT_FINAL) || time.Since(start) < time.Second {
+		t.Fatalf("Expect not final after waiting %v, got %v", time.Since(start), err)
+	}
+}
+
+func TestValidateStateRoot(t *testing.T) {
+	value := &ccom.ToMerkleValue{MakeTxParam: &ccom.MakeTxParam{ToContractAddress: []byte{1, 2}}}
+	sink := pcom.NewZeroCopySink(nil)
+	value.Serialization(sink)
+	root := merkle.HashLeaf(sink.Bytes())
+	var headers []float64
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getsmartcodeevent":
+			return map[string]interface{}{"TxHash": "hash", "State": 1, "Notify": []interface{}{
+				map[string]interface{}{"ContractAddress": poly.CCM_ADDRESS, "States": []interface{}{"makeProof", 2, 6, "ab", 100, "key"}},
+			}}, nil
+		case "getcrossstatesproof":
+			return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+		case "getheaderbyheight":
+			headers = append(headers, params[0].(float64))
+			return hex.EncodeToString((&types.Header{CrossStateRoot: root}).ToArray()), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	l := &Listener{config: &config.ListenerConfig{}}
+	tx := &msg.Tx{PolyHash: "hash", SrcChainId: 2, DstChainId: 6, DstProxy: "0102"}
+	if err := l.validate(node, tx); err != nil || len(headers) != 0 {
+		t.Fatalf("Expect no header fetched without state root verification, got %v %v", headers, err)
+	}
+	l.config.VerifyStateRoot = true
+	if err := l.validate(node, tx); err != nil || fmt.Sprint(headers) != "[101]" {
+		t.Fatalf("Expect proof verified against header 101, got %v %v", headers, err)
+	}
+	root = pcom.Uint256{1}
+	if err := l.validate(node, tx); !errors.Is(err, msg.ERR_TX_VOILATION) {
+		t.Fatalf("Expect mismatched state root violation, got %v", err)
+	}
+}
+
+func TestLatestHeightCache(t *testing.T) {
+	var calls int64
+	sdk := testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 100 + atomic.AddInt64(&calls, 1), nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	l := new(Listener)
+	if err := l.Init(&config.ListenerConfig{HeightCacheTTL: 500}, sdk); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	l.heights.now = func() time.Time { return now }
+	count := atomic.LoadInt64(&calls)
+	latest := func(force bool) uint64 {
+		get := l.LatestHeight
+		if force {
+			get = l.RefreshLatestHeight
+		}
+		height, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return height
+	}
+
+	// Callers within the ttl share one node call
+	first := latest(false)
+	if latest(false) != first || atomic.LoadInt64(&calls) != count+1 {
+		t.Fatalf("Expect cached height %d, node called %d times", first, atomic.LoadInt64(&calls)-count)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if latest(false) <= first || atomic.LoadInt64(&calls) != count+2 {
+		t.Fatal("Expect height fetched again after ttl")
+	}
+	if h := latest(true); h <= first+1 || latest(false) != h || atomic.LoadInt64(&calls) != count+3 {
+		t.Fatal("Expect forced refresh updating the cache")
+	}
+
+	// Disabled cache calls the node every time
+	l.heights = nil
+	latest(false)
+	latest(false)
+	if atomic.LoadInt64(&calls) != count+5 {
+		t.Fatal("Expect node called without cache")
+	}
+}
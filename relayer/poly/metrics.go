@@ -0,0 +1,145 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"sync"
+
+	"github.com/polynetwork/bridge-common/metrics"
+)
+
+var (
+	metricsOnce sync.Once
+
+	// Elapsed seconds from the src chain block time to the poly import
+	RelayLatency = NewHistogram("relay_latency", 5, 15, 30, 60, 120, 300, 600, 1800, 3600)
+
+	// Outcomes of looking up the merkle value from the poly tx notifies
+	PolyParamsOutcomes = NewCounter("poly_params")
+
+	// Seconds since the tx being processed was first enqueued by submitter
+	BacklogAge = NewGauge("backlog_age")
+
+	// 1 while the submitter is paused, 0 otherwise
+	SubmitterPaused = NewGauge("submitter_paused")
+)
+
+// Set up the metrics collector and its http route, it shares the once with the on demand
+// collector of record, so call it before any worker starts to have the route served.
+func InitMetrics() {
+	metricsOnce.Do(func() { metrics.Init("relayer") })
+}
+
+// Record a metric value, the metrics collector is created on demand so workers
+// can report even when the metrics http endpoint is not served by this process.
+func record(value interface{}, key string, args ...interface{}) {
+	metricsOnce.Do(func() { metrics.NewMetrics("relayer") })
+	metrics.Record(value, key, args...)
+}
+
+// Histogram counts observations into buckets with fixed upper bounds
+type Histogram struct {
+	sync.Mutex
+	name   string
+	bounds []float64
+	counts []uint64 // Cumulative count per bound, the last slot is +Inf
+	count  uint64
+	sum    float64
+}
+
+func NewHistogram(name string, bounds ...float64) *Histogram {
+	return &Histogram{name: name, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.Lock()
+	defer h.Unlock()
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.counts[len(h.bounds)]++
+	h.count++
+	h.sum += value
+
+	for i, bound := range h.bounds {
+		record(h.counts[i], "%s.bucket.le_%v", h.name, bound)
+	}
+	record(h.count, "%s.count", h.name)
+	record(h.sum, "%s.sum", h.name)
+}
+
+// Snapshot returns the cumulative bucket counts, total count and sum of observations
+func (h *Histogram) Snapshot() (counts []uint64, count uint64, sum float64) {
+	h.Lock()
+	defer h.Unlock()
+	counts = make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	return counts, h.count, h.sum
+}
+
+// Counter counts occurrences per label
+type Counter struct {
+	sync.Mutex
+	name   string
+	counts map[string]uint64
+}
+
+func NewCounter(name string) *Counter {
+	return &Counter{name: name, counts: map[string]uint64{}}
+}
+
+func (c *Counter) Inc(label string) {
+	c.Lock()
+	defer c.Unlock()
+	c.counts[label]++
+	record(c.counts[label], "%s.%s", c.name, label)
+}
+
+// Count returns the count of the label
+func (c *Counter) Count(label string) uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.counts[label]
+}
+
+// Gauge keeps the latest value per label
+type Gauge struct {
+	sync.Mutex
+	name   string
+	values map[string]float64
+}
+
+func NewGauge(name string) *Gauge {
+	return &Gauge{name: name, values: map[string]float64{}}
+}
+
+func (g *Gauge) Set(label string, value float64) {
+	g.Lock()
+	defer g.Unlock()
+	g.values[label] = value
+	record(value, "%s.%s", g.name, label)
+}
+
+// Value returns the latest value of the label
+func (g *Gauge) Value(label string) float64 {
+	g.Lock()
+	defer g.Unlock()
+	return g.values[label]
+}
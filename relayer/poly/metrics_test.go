@@ -0,0 +1,49 @@
+package poly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRecordLatency(t *testing.T) {
+	s := &Submitter{}
+	prev, before, prevSum := RelayLatency.Snapshot()
+
+	s.recordLatency(&msg.Tx{SrcHash: "missing"})
+	_, count, _ := RelayLatency.Snapshot()
+	if count != before {
+		t.Fatalf("Latency recorded without src time")
+	}
+
+	s.recordLatency(&msg.Tx{SrcHash: "present", SrcTime: time.Now().Add(-20 * time.Second).Unix()})
+	counts, count, sum := RelayLatency.Snapshot()
+	if count != before+1 {
+		t.Fatalf("Latency not recorded, count %v", count)
+	}
+	if sum-prevSum < 20 {
+		t.Fatalf("Unexpected latency sum %v", sum)
+	}
+	// 20s falls into the 30s bucket but not the 15s one
+	if counts[1] != prev[1] || counts[2] != prev[2]+1 {
+		t.Fatalf("Unexpected bucket counts %v", counts)
+	}
+}
+
+func TestBacklogAge(t *testing.T) {
+	s := &Submitter{name: "backlog", config: &config.PolySubmitterConfig{BacklogAgeAlert: 10}}
+	s.recordBacklogAge(&msg.Tx{SrcHash: "unmarked"})
+	if age := BacklogAge.Value(s.name); age != 0 {
+		t.Fatalf("Expect no age without enqueue time, got %v", age)
+	}
+	s.recordBacklogAge(&msg.Tx{SrcHash: "stuck", EnqueuedAt: time.Now().Add(-30 * time.Second).Unix()})
+	if age := BacklogAge.Value(s.name); age < 30 || age > 40 {
+		t.Fatalf("Expect backlog age of 30s, got %v", age)
+	}
+	s.recordBacklogAge(&msg.Tx{SrcHash: "fresh", EnqueuedAt: time.Now().Unix()})
+	if age := BacklogAge.Value(s.name); age > 5 {
+		t.Fatalf("Expect backlog age reset by a fresh tx, got %v", age)
+	}
+}
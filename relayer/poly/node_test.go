@@ -0,0 +1,88 @@
+package poly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	psdk "github.com/polynetwork/poly-go-sdk"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+// Poly node backed by a local json rpc server, handler returns the result of the rpc method
+func testPolyNode(t *testing.T, handler func(method string, params []interface{}) (interface{}, error)) *poly.Client {
+	s := psdk.NewPolySdk()
+	s.NewRpcClient().SetAddress(testPolyServer(t, handler))
+	return &poly.Client{Rpc: s}
+}
+
+// Poly sdk with a single node backed by a local json rpc server
+func testPolySDK(t *testing.T, handler func(method string, params []interface{}) (interface{}, error)) *poly.SDK {
+	sdk, err := poly.NewSDK(base.POLY, []string{testPolyServer(t, handler)}, time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sdk
+}
+
+func testPolyServer(t *testing.T, handler func(method string, params []interface{}) (interface{}, error)) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			Id     string
+			Method string
+			Params []interface{}
+		}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		res := map[string]interface{}{"id": req.Id}
+		result, err := handler(req.Method, req.Params)
+		if err != nil {
+			res["error"] = 1
+			res["desc"] = err.Error()
+		} else {
+			res["result"] = result
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// Audit path carrying the merkle value as the proof leaf
+func testAuditPath(value *ccom.ToMerkleValue) string {
+	sink := pcom.NewZeroCopySink(nil)
+	value.Serialization(sink)
+	path := pcom.NewZeroCopySink(nil)
+	path.WriteVarBytes(sink.Bytes())
+	return hex.EncodeToString(path.Bytes())
+}
+
+// Poly node serving cross states proofs of the merkle value
+func testProofNode(t *testing.T, value *ccom.ToMerkleValue) *poly.Client {
+	return testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+	})
+}
+
+// Serialized poly header at the height signed by a bookkeeper
+func testSignedHeader(t *testing.T, height uint32) string {
+	hdr := &types.Header{Height: height, NextBookkeeper: pcom.ADDRESS_EMPTY}
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	key, _ := crypto.GenerateKey()
+	sig, err := crypto.Sign(digest[:], key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr.SigData = [][]byte{append([]byte{byte(signature.SHA256withECDSA), sig[64] + 27}, sig[:64]...)}
+	return hex.EncodeToString(hdr.ToArray())
+}
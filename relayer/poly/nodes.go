@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Health of a poly node seen by the node selector
+type NodeHealth struct {
+	Address     string
+	Calls       int           // Calls in the current window
+	Failures    int           // Unreachable node failures in the current window
+	Latency     time.Duration // Average call latency in the current window
+	Quarantined bool
+	Until       time.Time // End of the quarantine, the node is probed afterwards
+}
+
+type nodeStats struct {
+	calls       int
+	failures    int
+	latency     time.Duration
+	quarantined bool
+	until       time.Time
+	probing     bool
+}
+
+// Health aware poly node selection, quarantining the nodes failing or slow over a window of calls
+// and letting a single probe call through after the quarantine. A nil selector uses all the nodes.
+type nodeSelector struct {
+	sync.Mutex
+	errorRate  float64
+	maxLatency time.Duration
+	window     int
+	quarantine time.Duration
+	stats      map[*poly.Client]*nodeStats
+	now        func() time.Time
+	alert      func(level, msg string, fields map[string]interface{}) // Alert of quarantined nodes, optional
+}
+
+func newNodeSelector(c *config.NodeHealthConfig) *nodeSelector {
+	if c == nil || (c.ErrorRate <= 0 && c.MaxLatency <= 0) {
+		return nil
+	}
+	s := &nodeSelector{
+		errorRate:  c.ErrorRate,
+		maxLatency: time.Duration(c.MaxLatency) * time.Millisecond,
+		window:     20,
+		quarantine: time.Minute,
+		stats:      map[*poly.Client]*nodeStats{},
+		now:        time.Now,
+	}
+	if c.Window > 0 {
+		s.window = c.Window
+	}
+	if c.Quarantine > 0 {
+		s.quarantine = time.Duration(c.Quarantine) * time.Second
+	}
+	return s
+}
+
+func (s *nodeSelector) get(node *poly.Client) *nodeStats {
+	stats, ok := s.stats[node]
+	if !ok {
+		stats = new(nodeStats)
+		s.stats[node] = stats
+	}
+	return stats
+}
+
+// Order the nodes to call: a quarantined node due for a probe leads, then the healthy nodes with the primary first.
+// Quarantined nodes are kept out, unless none of the nodes is healthy. A probe not reported within the quarantine
+// period is handed out again.
+func (s *nodeSelector) Select(primary *poly.Client, nodes []*poly.Client) []*poly.Client {
+	candidates := []*poly.Client{primary}
+	for _, node := range nodes {
+		if node != primary {
+			candidates = append(candidates, node)
+		}
+	}
+	if s == nil {
+		return candidates
+	}
+	s.Lock()
+	defer s.Unlock()
+	var probe, healthy []*poly.Client
+	now := s.now()
+	for _, node := range candidates {
+		stats := s.get(node)
+		if !stats.quarantined {
+			healthy = append(healthy, node)
+		} else if probe == nil && !now.Before(stats.until) {
+			// Callers not reporting the probe leave it pending, it expires with another quarantine period
+			stats.probing = true
+			stats.until = now.Add(s.quarantine)
+			probe = append(probe, node)
+		}
+	}
+	selected := append(probe, healthy...)
+	if len(selected) == 0 {
+		return candidates
+	}
+	return selected
+}
+
+// Node to call, selected from the sdk nodes
+func (s *nodeSelector) Node(sdk *poly.SDK) *poly.Client {
+	if s == nil {
+		return sdk.Node()
+	}
+	return s.Select(sdk.Node(), sdk.AllNodes())[0]
+}
+
+// Report the call result of the node
+func (s *nodeSelector) Report(node *poly.Client, latency time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	// Alert after releasing the lock
+	var fields map[string]interface{}
+	defer func() {
+		if fields != nil && s.alert != nil {
+			s.alert(ALERT_WARN, "Quarantining unhealthy poly node", fields)
+		}
+	}()
+	s.Lock()
+	defer s.Unlock()
+	stats := s.get(node)
+	failed := nodeFailure(err)
+	if stats.probing {
+		stats.probing = false
+		if failed {
+			stats.until = s.now().Add(s.quarantine)
+			log.Warn("Poly node probe failed, keeping it in quarantine", "node", node.Address(), "until", stats.until, "err", err)
+		} else {
+			*stats = nodeStats{}
+			log.Info("Poly node probe succeeded, reintegrating it", "node", node.Address())
+		}
+		return
+	}
+	if stats.quarantined {
+		return
+	}
+	stats.calls++
+	stats.latency += latency
+	if failed {
+		stats.failures++
+	}
+	if stats.calls < s.window {
+		return
+	}
+	rate := float64(stats.failures) / float64(stats.calls)
+	average := stats.latency / time.Duration(stats.calls)
+	if (s.errorRate > 0 && rate >= s.errorRate) || (s.maxLatency > 0 && average > s.maxLatency) {
+		stats.quarantined = true
+		stats.until = s.now().Add(s.quarantine)
+		log.Warn("Quarantining unhealthy poly node", "node", node.Address(), "error_rate", rate, "latency", average, "until", stats.until)
+		fields = map[string]interface{}{"node": node.Address(), "error_rate": rate, "latency": average.String(), "until": stats.until}
+	} else {
+		stats.calls, stats.failures, stats.latency = 0, 0, 0
+	}
+}
+
+// Health of the nodes seen so far
+func (s *nodeSelector) Health() []NodeHealth {
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	health := make([]NodeHealth, 0, len(s.stats))
+	for node, stats := range s.stats {
+		h := NodeHealth{Address: node.Address(), Calls: stats.calls, Failures: stats.failures,
+			Quarantined: stats.quarantined, Until: stats.until}
+		if stats.calls > 0 {
+			h.Latency = stats.latency / time.Duration(stats.calls)
+		}
+		health = append(health, h)
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Address < health[j].Address })
+	return health
+}
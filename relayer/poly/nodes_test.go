@@ -0,0 +1,84 @@
+package poly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+func TestNodeSelector(t *testing.T) {
+	a, b := new(poly.Client), new(poly.Client)
+	nodes := []*poly.Client{a, b}
+	var s *nodeSelector
+	if selected := s.Select(b, nodes); len(selected) != 2 || selected[0] != b {
+		t.Fatal("Expect all nodes with the primary first without selector")
+	}
+	if newNodeSelector(&config.NodeHealthConfig{Window: 4}) != nil {
+		t.Fatal("Expect no selector without thresholds")
+	}
+
+	s = newNodeSelector(&config.NodeHealthConfig{ErrorRate: 0.5, MaxLatency: 100, Window: 4, Quarantine: 60})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	down := errors.New("http post request failed")
+	selected := func(expect ...*poly.Client) {
+		t.Helper()
+		list := s.Select(a, nodes)
+		if len(list) != len(expect) {
+			t.Fatalf("Expect %d nodes selected, got %d", len(expect), len(list))
+		}
+		for i := range list {
+			if list[i] != expect[i] {
+				t.Fatalf("Unexpected node selected at %d", i)
+			}
+		}
+	}
+
+	// Node failing half of the window calls is quarantined
+	for _, err := range []error{nil, down, errors.New("tx already done"), down} {
+		s.Report(a, time.Millisecond, err)
+	}
+	selected(b)
+	if health := s.Health(); len(health) != 2 || !health[0].Quarantined && !health[1].Quarantined {
+		t.Fatalf("Expect quarantined node in health, got %+v", health)
+	}
+
+	// A single probe goes through after the quarantine, a failed probe extends it
+	now = now.Add(61 * time.Second)
+	selected(a, b)
+	selected(b)
+	s.Report(a, time.Millisecond, down)
+	selected(b)
+	now = now.Add(61 * time.Second)
+	selected(a, b)
+	s.Report(a, time.Millisecond, nil)
+	selected(a, b)
+
+	// A probe never reported expires, the node is probed again after another quarantine period
+	for i := 0; i < 4; i++ {
+		s.Report(a, time.Millisecond, down)
+	}
+	now = now.Add(61 * time.Second)
+	selected(a, b)
+	selected(b)
+	now = now.Add(30 * time.Second)
+	selected(b)
+	now = now.Add(31 * time.Second)
+	selected(a, b)
+	s.Report(a, time.Millisecond, nil)
+	selected(a, b)
+
+	// Slow node is quarantined by the average latency, and all nodes are used when none is healthy
+	for i := 0; i < 4; i++ {
+		s.Report(b, 200*time.Millisecond, nil)
+	}
+	selected(a)
+	for i := 0; i < 4; i++ {
+		s.Report(a, time.Millisecond, down)
+	}
+	selected(a, b)
+}
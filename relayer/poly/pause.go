@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/polynetwork/bridge-common/log"
+)
+
+// Submitter workers block before taking the next tx from the bus while paused, keeping the bus position
+// and the signer nonce untouched, e.g. during a poly upgrade.
+type pauseGate struct {
+	sync.Mutex
+	resumed chan struct{} // Closed on resume, nil while running
+}
+
+// Block while paused, false if the context is done first
+func (g *pauseGate) Wait(ctx context.Context) bool {
+	g.Lock()
+	resumed := g.resumed
+	g.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-resumed:
+		return true
+	}
+}
+
+// Pause the tx submission, txs in flight still finish
+func (s *Submitter) Pause() {
+	s.pause.Lock()
+	defer s.pause.Unlock()
+	if s.pause.resumed != nil {
+		return
+	}
+	s.pause.resumed = make(chan struct{})
+	SubmitterPaused.Set(s.name, 1)
+	log.Info("Poly submitter paused", "chain", s.name, "inflight", atomic.LoadInt64(&s.inflight))
+}
+
+// Resume the tx submission of the paused workers
+func (s *Submitter) Resume() {
+	s.pause.Lock()
+	defer s.pause.Unlock()
+	if s.pause.resumed == nil {
+		return
+	}
+	close(s.pause.resumed)
+	s.pause.resumed = nil
+	SubmitterPaused.Set(s.name, 0)
+	log.Info("Poly submitter resumed", "chain", s.name)
+}
+
+// Whether the tx submission is paused
+func (s *Submitter) Paused() bool {
+	s.pause.Lock()
+	defer s.pause.Unlock()
+	return s.pause.resumed != nil
+}
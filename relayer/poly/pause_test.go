@@ -0,0 +1,128 @@
+package poly
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	sdk "github.com/polynetwork/poly-go-sdk"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type countComposer struct {
+	testComposer
+	composed int32
+}
+
+func (c *countComposer) Compose(tx *msg.Tx) error {
+	atomic.AddInt32(&c.composed, 1)
+	return c.testComposer.Compose(tx)
+}
+
+func TestPauseResume(t *testing.T) {
+	useTestConfig(t)
+	mq := new(memTxBus)
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "a", SrcChainId: base.ONT})
+	composer := new(countComposer)
+	s := &Submitter{
+		name:     "pause",
+		config:   &config.PolySubmitterConfig{DryRun: true, IdleInterval: 10},
+		signer:   new(sdk.Account),
+		seen:     bus.NewMemorySeenSet(time.Minute),
+		composer: composer,
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+
+	// Paused workers leave the txs on the bus
+	s.Pause()
+	if !s.Paused() || SubmitterPaused.Value(s.name) != 1 {
+		t.Fatal("Expect submitter paused")
+	}
+	done := make(chan struct{})
+	go func() {
+		s.run(mq)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if n, _ := mq.Len(context.Background()); n != 1 || atomic.LoadInt32(&composer.composed) != 0 {
+		t.Fatalf("Expect no tx taken while paused, bus size %d composed %d", n, composer.composed)
+	}
+
+	s.Resume()
+	if s.Paused() || SubmitterPaused.Value(s.name) != 0 {
+		t.Fatal("Expect submitter resumed")
+	}
+	waitFor(t, func() bool { return processed(s, &msg.Tx{SrcHash: "a", SrcChainId: base.ONT}) })
+
+	// Paused workers still exit on cancellation
+	s.Pause()
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "b", SrcChainId: base.ONT})
+	time.Sleep(50 * time.Millisecond)
+	s.cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Paused worker did not exit on cancellation")
+	}
+	if n, _ := mq.Len(context.Background()); n != 1 || atomic.LoadInt32(&composer.composed) != 1 {
+		t.Fatalf("Expect tx b left on the bus, bus size %d composed %d", n, composer.composed)
+	}
+}
+
+// Sorted tx bus pausing the submitter while a pop is in flight
+type pausingSortedTxBus struct {
+	memSortedTxBus
+	s *Submitter
+}
+
+func (b *pausingSortedTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, uint64, error) {
+	tx, block, err := b.memSortedTxBus.PopTimed(ctx, duration)
+	if tx != nil {
+		b.s.Pause()
+	}
+	return tx, block, err
+}
+
+func TestConsumePausedInPop(t *testing.T) {
+	useTestConfig(t)
+	composer := new(countComposer)
+	s := &Submitter{
+		name:     "pause-pop",
+		config:   &config.PolySubmitterConfig{DryRun: true, IdleInterval: 10},
+		signer:   new(sdk.Account),
+		seen:     bus.NewMemorySeenSet(time.Minute),
+		composer: composer,
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	mq := &pausingSortedTxBus{s: s}
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "a", TxId: "a", SrcChainId: base.ONT}, 7)
+
+	// Txs popped as the submitter pauses are held, then pushed back with their score on cancellation
+	done := make(chan struct{})
+	go func() {
+		s.consume(mq)
+		close(done)
+	}()
+	waitFor(t, func() bool { n, _ := mq.Len(s.Context); return n == 0 && s.Paused() })
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&composer.composed) != 0 {
+		t.Fatal("Expect no tx submitted while paused")
+	}
+	s.cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Paused consumer did not exit on cancellation")
+	}
+	if n, _ := mq.Len(context.Background()); n != 1 || mq.blocks[0] != 7 || atomic.LoadInt32(&composer.composed) != 0 {
+		t.Fatalf("Expect tx a pushed back to the bus, bus size %d composed %d", n, composer.composed)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Tx queued in the tx bus with its bus score, the src height it becomes ready at
+type PendingTx struct {
+	Tx    *msg.Tx
+	Score uint64
+}
+
+// DrainPending pops all txs queued in the attached tx bus without processing them, for backlog migration
+// or inspection. Workers should be stopped first. Only the queue length seen at start is drained, txs pushed
+// during the drain stay in the bus. On error, the txs drained so far are returned and should be restored.
+func (s *Submitter) DrainPending() ([]*PendingTx, error) {
+	if s.mq == nil {
+		return nil, fmt.Errorf("No tx bus attached to %s submitter", s.name)
+	}
+	return drainPending(s.ctx(), s.mq)
+}
+
+// RestorePending pushes the txs back to the attached tx bus with their drained scores, so delayed retries
+// stay delayed and the queue order is kept
+func (s *Submitter) RestorePending(txs []*PendingTx) error {
+	if s.mq == nil {
+		return fmt.Errorf("No tx bus attached to %s submitter", s.name)
+	}
+	return restorePending(s.ctx(), s.mq, txs)
+}
+
+func drainPending(ctx context.Context, mq bus.SortedTxBus) (txs []*PendingTx, err error) {
+	size, err := mq.Len(ctx)
+	if err != nil {
+		return
+	}
+	for i := uint64(0); i < size; i++ {
+		// Stop early if the queue was consumed by others to avoid blocking on pop
+		n, err := mq.Len(ctx)
+		if err != nil {
+			return txs, err
+		}
+		if n == 0 {
+			break
+		}
+		tx, score, err := mq.Pop(ctx)
+		if err != nil {
+			return txs, fmt.Errorf("Drain tx bus %s error %v", mq.Topic(), err)
+		}
+		if tx != nil {
+			txs = append(txs, &PendingTx{tx, score})
+		}
+	}
+	log.Info("Drained pending txs", "topic", mq.Topic(), "size", len(txs))
+	return
+}
+
+func restorePending(ctx context.Context, mq bus.SortedTxBus, txs []*PendingTx) error {
+	for i, pending := range txs {
+		err := mq.Push(ctx, pending.Tx, pending.Score)
+		if err != nil {
+			return fmt.Errorf("Restore tx bus %s error %v, %d of %d txs restored", mq.Topic(), err, i, len(txs))
+		}
+	}
+	log.Info("Restored pending txs", "topic", mq.Topic(), "size", len(txs))
+	return nil
+}
@@ -0,0 +1,507 @@
+package poly
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	sdk "github.com/polynetwork/poly-go-sdk"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type memSortedBus struct {
+	sync.Mutex
+	items []memSortedItem
+}
+
+type memSortedItem struct {
+	data  string
+	score uint64
+}
+
+func (b *memSortedBus) Push(_ context.Context, tx *msg.Tx, score uint64) error {
+	b.Lock()
+	defer b.Unlock()
+	b.items = append(b.items, memSortedItem{tx.Encode(), score})
+	sort.SliceStable(b.items, func(i, j int) bool { return b.items[i].score < b.items[j].score })
+	return nil
+}
+
+func (b *memSortedBus) Range(context.Context, uint64, int64) ([]*msg.Tx, error) { return nil, nil }
+
+func (b *memSortedBus) PopTimed(ctx context.Context, _ time.Duration) (*msg.Tx, uint64, error) {
+	return b.Pop(ctx)
+}
+
+func (b *memSortedBus) Pop(context.Context) (tx *msg.Tx, score uint64, err error) {
+	b.Lock()
+	defer b.Unlock()
+	if len(b.items) == 0 {
+		return
+	}
+	item := b.items[0]
+	b.items = b.items[1:]
+	tx = new(msg.Tx)
+	err = tx.Decode(item.data)
+	return tx, item.score, err
+}
+
+func (b *memSortedBus) Len(context.Context) (uint64, error) {
+	b.Lock()
+	defer b.Unlock()
+	return uint64(len(b.items)), nil
+}
+
+func (b *memSortedBus) Topic() string { return "mem" }
+
+func TestDrainPending(t *testing.T) {
+	mq := new(memSortedBus)
+	s := &Submitter{}
+	if _, err := s.DrainPending(); err == nil {
+		t.Fatal("Expect failure without tx bus")
+	}
+	s.mq = mq
+	ctx := context.Background()
+	// Scores differ from the proof heights for immediate and delayed retry txs
+	for i, score := range []uint64{30, 0, 20} {
+		mq.Push(ctx, &msg.Tx{SrcHash: string(rune('a' + i)), SrcChainId: 2, SrcProofHeight: 10}, score)
+	}
+
+	txs, err := s.DrainPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 3 || txs[0].Tx.SrcHash != "b" || txs[0].Score != 0 || txs[2].Tx.SrcHash != "a" || txs[2].Score != 30 {
+		t.Fatalf("Unexpected drained txs %v", txs)
+	}
+	if n, _ := mq.Len(ctx); n != 0 {
+		t.Fatalf("Bus not drained, size %d", n)
+	}
+
+	if err = s.RestorePending(txs); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []struct {
+		hash  string
+		score uint64
+	}{{"b", 0}, {"c", 20}, {"a", 30}} {
+		tx, score, _ := mq.Pop(ctx)
+		if tx.SrcHash != expected.hash || score != expected.score || tx.SrcChainId != 2 {
+			t.Fatalf("Unexpected restored tx %s score %d", tx.SrcHash, score)
+		}
+	}
+}
+
+type memTxBus struct {
+	sync.Mutex
+	txs []*msg.Tx
+}
+
+func (b *memTxBus) Pop(context.Context) (*msg.Tx, error) {
+	b.Lock()
+	defer b.Unlock()
+	if len(b.txs) == 0 {
+		return nil, nil
+	}
+	tx := b.txs[0]
+	b.txs = b.txs[1:]
+	return tx, nil
+}
+
+func (b *memTxBus) PopTimed(ctx context.Context, _ time.Duration) (*msg.Tx, error) { return b.Pop(ctx) }
+
+func (b *memTxBus) Push(_ context.Context, tx *msg.Tx) error {
+	b.Lock()
+	defer b.Unlock()
+	b.txs = append(b.txs, tx)
+	return nil
+}
+
+func (b *memTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error { return b.Push(ctx, tx) }
+func (b *memTxBus) Patch(ctx context.Context, tx *msg.Tx) error       { return b.Push(ctx, tx) }
+func (b *memTxBus) PushBack(ctx context.Context, tx *msg.Tx) error    { return b.Push(ctx, tx) }
+
+func (b *memTxBus) Len(context.Context) (uint64, error) {
+	b.Lock()
+	defer b.Unlock()
+	return uint64(len(b.txs)), nil
+}
+
+func (b *memTxBus) LenOf(ctx context.Context, _ uint64, _ msg.TxType) (uint64, error) {
+	return b.Len(ctx)
+}
+func (b *memTxBus) Topic() string { return "mem" }
+
+func (b *memTxBus) hashes() (list []string) {
+	b.Lock()
+	defer b.Unlock()
+	for _, tx := range b.txs {
+		list = append(list, tx.SrcHash)
+	}
+	return
+}
+
+func TestRetryBus(t *testing.T) {
+	useTestConfig(t)
+	mq, retry := new(memTxBus), new(memTxBus)
+	for _, hash := range []string{"ok1", "bad", "ok2"} {
+		mq.Push(context.Background(), &msg.Tx{SrcHash: hash, TxId: hash, SrcChainId: base.ONT})
+	}
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{DryRun: true, IdleInterval: 10, RetryInterval: 10},
+		signer: new(sdk.Account),
+		seen:   bus.NewMemorySeenSet(time.Minute),
+	}
+	s.SetRetryBus(retry)
+	composer := &testComposer{fail: "bad"}
+	s.composer = composer
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+
+	// Failures land on the retry bus while fresh txs keep flowing
+	go s.run(mq)
+	waitFor(t, func() bool { n, _ := mq.Len(s.Context); return n == 0 && len(retry.hashes()) == 1 })
+	for _, hash := range []string{"ok1", "ok2"} {
+		if !processed(s, &msg.Tx{SrcHash: hash, TxId: hash, SrcChainId: base.ONT}) {
+			t.Fatalf("Expect fresh tx %s submitted", hash)
+		}
+	}
+	if hashes := retry.hashes(); hashes[0] != "bad" {
+		t.Fatalf("Unexpected retry bus txs %v", hashes)
+	}
+	s.cancel()
+	s.wg.Wait()
+
+	// Retry worker drains the retry bus once the tx recovers
+	composer.fail = ""
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	go s.retryLoop(retry)
+	bad := &msg.Tx{SrcHash: "bad", TxId: "bad", SrcChainId: base.ONT}
+	waitFor(t, func() bool { return len(retry.hashes()) == 0 && processed(s, bad) })
+	if n, _ := mq.Len(s.Context); n != 0 {
+		t.Fatalf("Retried tx pushed to main bus")
+	}
+}
+
+// Sorted tx bus in memory, popping the txs in push order
+type memSortedTxBus struct {
+	sync.Mutex
+	txs    []*msg.Tx
+	blocks []uint64
+}
+
+func (b *memSortedTxBus) Push(_ context.Context, tx *msg.Tx, block uint64) error {
+	b.Lock()
+	defer b.Unlock()
+	b.txs = append(b.txs, tx)
+	b.blocks = append(b.blocks, block)
+	return nil
+}
+
+func (b *memSortedTxBus) Range(context.Context, uint64, int64) ([]*msg.Tx, error) { return nil, nil }
+
+func (b *memSortedTxBus) PopTimed(ctx context.Context, _ time.Duration) (*msg.Tx, uint64, error) {
+	return b.Pop(ctx)
+}
+
+func (b *memSortedTxBus) Pop(context.Context) (*msg.Tx, uint64, error) {
+	b.Lock()
+	defer b.Unlock()
+	if len(b.txs) == 0 {
+		return nil, 0, nil
+	}
+	tx, block := b.txs[0], b.blocks[0]
+	b.txs, b.blocks = b.txs[1:], b.blocks[1:]
+	return tx, block, nil
+}
+
+func (b *memSortedTxBus) Len(context.Context) (uint64, error) {
+	b.Lock()
+	defer b.Unlock()
+	return uint64(len(b.txs)), nil
+}
+
+func (b *memSortedTxBus) Topic() string { return "mem-sorted" }
+
+func TestConsumeRetryBus(t *testing.T) {
+	useTestConfig(t)
+	mq, retry := new(memSortedTxBus), new(memTxBus)
+	for _, hash := range []string{"ok1", "bad", "ok2"} {
+		enqueued := time.Now().Add(-time.Minute).Unix()
+		mq.Push(context.Background(), &msg.Tx{SrcHash: hash, TxId: hash, SrcChainId: base.ONT, EnqueuedAt: enqueued}, 0)
+	}
+	s := &Submitter{
+		name:   "consume",
+		config: &config.PolySubmitterConfig{DryRun: true, RetryInterval: 10},
+		signer: new(sdk.Account),
+		seen:   bus.NewMemorySeenSet(time.Minute),
+	}
+	s.SetRetryBus(retry)
+	s.composer = &testComposer{fail: "bad"}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+
+	// Failures of the sorted bus consumers land on the retry bus instead of the sorted bus
+	go s.consume(mq)
+	waitFor(t, func() bool { n, _ := mq.Len(s.Context); return n == 0 && len(retry.hashes()) == 1 })
+	s.cancel()
+	s.wg.Wait()
+	if n, _ := mq.Len(s.Context); n != 0 || retry.hashes()[0] != "bad" {
+		t.Fatalf("Expect failed tx on the retry bus only, sorted bus size %d", n)
+	}
+	if age := BacklogAge.Value(s.name); age < 60 {
+		t.Fatalf("Expect backlog age recorded by the sorted bus consumers, got %v", age)
+	}
+}
+
+// Composer failing every tx with the error
+type errComposer struct {
+	testComposer
+	err error
+}
+
+func (c *errComposer) Compose(*msg.Tx) error { return c.err }
+
+func TestConsumeDroppedTx(t *testing.T) {
+	useTestConfig(t)
+	mq, retry, alerter := new(memSortedTxBus), new(memTxBus), new(testAlerter)
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "a", TxId: "a", SrcChainId: base.ONT}, 0)
+	s := &Submitter{
+		name:    "consume",
+		config:  &config.PolySubmitterConfig{DryRun: true, RetryInterval: 10},
+		signer:  new(sdk.Account),
+		seen:    bus.NewMemorySeenSet(time.Minute),
+		alerter: alerter,
+	}
+	s.SetRetryBus(retry)
+	s.composer = &errComposer{err: errors.New("side chain 2 not registered")}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+
+	// Txs of unregistered side chains are dropped with an alert, as by the other consumers
+	go s.consume(mq)
+	waitFor(t, func() bool { n, _ := mq.Len(s.Context); return n == 0 })
+	time.Sleep(50 * time.Millisecond)
+	s.cancel()
+	s.wg.Wait()
+	if n := len(retry.hashes()); n != 0 || len(alerter.alerts) != 1 {
+		t.Fatalf("Expect tx dropped with an alert, got %d retried, alerts %v", n, alerter.alerts)
+	}
+}
+
+// Tx bus failing the pops
+type downTxBus struct {
+	memTxBus
+	pops int32
+}
+
+func (b *downTxBus) PopTimed(context.Context, time.Duration) (*msg.Tx, error) {
+	atomic.AddInt32(&b.pops, 1)
+	return nil, errors.New("redis connection refused")
+}
+
+func TestRetryLoopPopError(t *testing.T) {
+	retry := new(downTxBus)
+	var waits int32
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{RetryInterval: 10},
+		after: func(time.Duration) <-chan time.Time {
+			atomic.AddInt32(&waits, 1)
+			return nil
+		},
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	go s.retryLoop(retry)
+	waitFor(t, func() bool { return atomic.LoadInt32(&waits) == 1 })
+	time.Sleep(20 * time.Millisecond)
+	s.cancel()
+	s.wg.Wait()
+	if pops := atomic.LoadInt32(&retry.pops); pops != 1 {
+		t.Fatalf("Expect retry worker waiting after a pop error, got %d pops", pops)
+	}
+}
+
+// Tx bus failing the first pushes
+type flakyTxBus struct {
+	memTxBus
+	fails int
+}
+
+func (b *flakyTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	b.Lock()
+	if b.fails > 0 {
+		b.fails--
+		b.Unlock()
+		return errors.New("redis connection refused")
+	}
+	b.Unlock()
+	return b.memTxBus.Push(ctx, tx)
+}
+
+func TestPushBackFailure(t *testing.T) {
+	useTestConfig(t)
+	mq, retry := new(memTxBus), &flakyTxBus{fails: 3}
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "bad", SrcChainId: base.ONT})
+	alerter := new(testAlerter)
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{DryRun: true, IdleInterval: 10},
+		signer:   new(sdk.Account),
+		composer: &testComposer{fail: "bad"},
+		alerter:  alerter,
+		after: func(time.Duration) <-chan time.Time {
+			ch := make(chan time.Time, 1)
+			ch <- time.Now()
+			return ch
+		},
+	}
+	s.SetRetryBus(retry)
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+
+	// Failed pushes to the retry bus are retried instead of dropping the tx
+	go s.run(mq)
+	waitFor(t, func() bool { return len(retry.hashes()) == 1 })
+	s.cancel()
+	s.wg.Wait()
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("Unexpected alerts %v", alerter.alerts)
+	}
+
+	// Dead letter alerted once the submitter exits and the last push fails
+	retry.fails = 100
+	lost := &msg.Tx{SrcHash: "lost"}
+	err := s.pushBack(lost, "retry bus", func(ctx context.Context) error { return retry.Push(ctx, lost) })
+	if err == nil || len(alerter.alerts) != 1 || len(retry.hashes()) != 1 {
+		t.Fatalf("Expect lost tx alerted, err %v alerts %v", err, alerter.alerts)
+	}
+
+	// Pushed on exit if the bus recovers
+	retry.fails = 1
+	flush := &msg.Tx{SrcHash: "flush"}
+	err = s.pushBack(flush, "retry bus", func(ctx context.Context) error { return retry.Push(ctx, flush) })
+	if err != nil || len(retry.hashes()) != 2 {
+		t.Fatalf("Expect tx pushed on exit, err %v", err)
+	}
+}
+
+// Tx bus blocking pops like redis, which ignores the context cancel
+type blockingTxBus struct {
+	memTxBus
+}
+
+func (b *blockingTxBus) Pop(context.Context) (*msg.Tx, error) {
+	select {}
+}
+
+func (b *blockingTxBus) PopTimed(_ context.Context, duration time.Duration) (*msg.Tx, error) {
+	time.Sleep(duration)
+	return nil, nil
+}
+
+// Sorted tx bus blocking pops like redis
+type blockingSortedTxBus struct {
+	memSortedTxBus
+}
+
+func (b *blockingSortedTxBus) Pop(context.Context) (*msg.Tx, uint64, error) {
+	select {}
+}
+
+func (b *blockingSortedTxBus) PopTimed(_ context.Context, duration time.Duration) (*msg.Tx, uint64, error) {
+	time.Sleep(duration)
+	return nil, 0, nil
+}
+
+func TestPopCancel(t *testing.T) {
+	s := &Submitter{config: &config.PolySubmitterConfig{ChainId: base.ONT, IdleInterval: 10}, composer: new(testComposer)}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	done := make(chan struct{})
+	go func() {
+		s.run(new(blockingTxBus))
+		close(done)
+	}()
+	go s.retryLoop(new(blockingTxBus))
+	consumed := make(chan struct{})
+	go func() {
+		s.consume(new(blockingSortedTxBus))
+		close(consumed)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.cancel()
+	for _, ch := range []chan struct{}{done, consumed} {
+		select {
+		case <-ch:
+		case <-time.After(2 * BUS_POP_TIMEOUT):
+			t.Fatal("Expect blocked pop to return on cancel")
+		}
+	}
+	s.wg.Wait()
+}
+
+func waitFor(t *testing.T, check func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsumeIdle(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		waits []time.Duration
+	)
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{IdleInterval: 10, MaxIdleInterval: 40},
+		composer: new(testComposer),
+		after: func(d time.Duration) <-chan time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			waits = append(waits, d)
+			if len(waits) > 4 {
+				return nil
+			}
+			ch := make(chan time.Time, 1)
+			ch <- time.Now()
+			return ch
+		},
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+
+	// Idle polls of the empty sorted bus back off and end on cancellation
+	done := make(chan struct{})
+	go func() {
+		s.consume(new(memSortedTxBus))
+		close(done)
+	}()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(waits) == 5
+	})
+	s.cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Idle consumer did not exit on cancellation")
+	}
+	for i, d := range []time.Duration{10, 20, 40, 40, 40} {
+		if waits[i] != d*time.Millisecond {
+			t.Fatalf("Expect idle wait %d of %v, got %v", i, d*time.Millisecond, waits[i])
+		}
+	}
+}
@@ -20,20 +20,30 @@ package poly
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ontio/ontology-crypto/signature"
+	ocom "github.com/ontio/ontology/common"
+	otypes "github.com/ontio/ontology/core/types"
 
 	"github.com/polynetwork/bridge-common/base"
 	"github.com/polynetwork/bridge-common/chains/poly"
 	"github.com/polynetwork/bridge-common/log"
 	"github.com/polynetwork/bridge-common/wallet"
 	sdk "github.com/polynetwork/poly-go-sdk"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/polynetwork/poly-relayer/bus"
 	"github.com/polynetwork/poly-relayer/config"
@@ -42,7 +52,9 @@ import (
 
 type Submitter struct {
 	context.Context
+	cancel   context.CancelFunc
 	wg       *sync.WaitGroup
+	inflight int64 // Txs being submitted by workers
 	config   *config.PolySubmitterConfig
 	sdk      *poly.SDK
 	signer   *sdk.Account
@@ -51,10 +63,48 @@ type Submitter struct {
 	composer msg.SrcComposer
 	state    bus.ChainStore // Header sync marking
 
+	headerSigner *sdk.Account // Optional signer for header sync, falls back to signer
+	seen         bus.SeenSet  // Recently processed txs
+	tokens       bus.SeenSet  // Claimed request tokens without the shared seen set
+	tokenLock    sync.Mutex
+	composeCache *composeCache
+	keepers      func(uint64) ([]byte, error) // Dst chain poly keepers resolver
+	epochHeight  func(uint64) (uint32, error) // Dst chain poly epoch start height resolver
+	epochs       *epochCache                  // Resolved epoch start heights by dst chain
+	balance      BalanceSource                // Optional signer balance source
+	minBalance   uint64                       // Signer balance threshold of the balance source
+	balanceCheck time.Duration                // Signer balance check interval
+	gasOracle    func() (uint64, error)       // Suggested poly gas price for auto gas
+	gasEstimator GasEstimator                 // Import gas estimator for auto gas
+	mq           bus.SortedTxBus              // Tx bus attached with Start
+	onLowBalance func(string, uint64)         // Signer low balance handler
+	onStream     func(int)                    // Header stream progress handler
+	onProgress   func(uint64, int)            // Header sync progress handler
+	onComplete   func(uint64)                 // Header sync stop height reached handler
+	encoders     map[uint64]ProofEncoder      // Dst chain proof encoders, EVM encoding if not set
+	replayer     func(uint64) (TxReplayer, error)
+	proofs       *proofCache // Optional cross states proof cache
+	retry        bus.TxBus   // Optional bus for failed txs
+	tracer       trace.Tracer
+	breaker      *breaker                             // Optional circuit breaker of poly node calls
+	requeues     *rateLimiter                         // Optional rate limit of failed txs pushed back
+	headers      *headerGate                          // Optional limits of header submits shared across side chains
+	nodes        *nodeSelector                        // Optional health aware poly node selection
+	recent       *headerWindow                        // Optional window of received headers of the sync
+	alerter      Alerter                              // Receiver of significant failures, no-op if nil
+	pause        pauseGate                            // Workers stop taking txs while paused
+	after        func(time.Duration) <-chan time.Time // Idle poll timer, time.After if nil
+	cacheOnce    sync.Once
+	signerLock   sync.RWMutex // Held by txs in flight with the signer, rotating waits for them
+
 	// Check last header commit
 	lastCommit   uint64
 	lastCheck    uint64
 	blocksToWait uint64
+
+	// Header failing to decompress and its failures in a row
+	decompressHeight   uint64
+	decompressFailures int
 }
 
 func (s *Submitter) Init(config *config.PolySubmitterConfig) (err error) {
@@ -67,23 +117,73 @@ func (s *Submitter) Init(config *config.PolySubmitterConfig) (err error) {
 	} else {
 		log.Warn("Skipping poly wallet init")
 	}
+	if config.HeaderWallet != nil && config.HeaderWallet.Path != "" {
+		s.headerSigner, err = wallet.NewPolySigner(config.HeaderWallet)
+		if err != nil {
+			return
+		}
+		log.Info("Using dedicated poly account for header sync", "address", s.headerSigner.Address.ToBase58())
+	}
+	for chainId := range config.AnchorOffsets {
+		if err = config.AnchorOffset(chainId).Validate(); err != nil {
+			return fmt.Errorf("Invalid anchor offset of chain %d, %v", chainId, err)
+		}
+	}
+	if err = checkCCMAddress(config.CCMContract); err != nil {
+		return
+	}
+	if err = config.CheckConfirmStrategy(CONFIRM_ROUNDS); err != nil {
+		return
+	}
 	s.name = base.GetChainName(config.ChainId)
+	s.breaker = newBreaker(s.name, config.BreakerThreshold, config.BreakerCooldown)
+	s.requeues = newRateLimiter(config.RequeueRate, config.RequeueBurst)
+	s.headers = sharedHeaderGate(config.HeaderSyncProcs, config.HeaderSyncRate)
+	s.nodes = newNodeSelector(config.NodeHealth)
+	if s.nodes != nil {
+		s.nodes.alert = s.alert
+	}
+	if config.AlertWebhook != "" && s.alerter == nil {
+		s.alerter = NewWebhookAlerter(config.AlertWebhook)
+	}
 	s.blocksToWait = base.BlocksToWait(config.ChainId)
 	log.Info("Chain blocks to wait", "blocks", s.blocksToWait, "chain", s.name)
-	s.sdk, err = poly.WithOptions(base.POLY, config.Nodes, time.Minute, 1)
+	if config.ProofCacheTTL > 0 {
+		s.proofs = newProofCache(time.Duration(config.ProofCacheTTL) * time.Second)
+	}
+	interval, maxGap := sdkOptions(config.NodeCheckInterval, config.NodeMaxGap)
+	s.sdk, err = poly.WithOptions(base.POLY, config.Nodes, interval, maxGap)
+	detectRateLimits(s.sdk)
 	return
 }
 
+// Poly sdk node check interval and max height gap, defaults to one minute and one block
+func sdkOptions(interval int, maxGap uint64) (time.Duration, uint64) {
+	d := time.Minute
+	if interval > 0 {
+		d = time.Duration(interval) * time.Second
+	}
+	if maxGap == 0 {
+		maxGap = 1
+	}
+	return d, maxGap
+}
+
 func (s *Submitter) SDK() *poly.SDK {
 	return s.sdk
 }
 
+// Health of the poly nodes used by the submitter, empty unless node quarantine is configured
+func (s *Submitter) NodeHealth() []NodeHealth {
+	return s.nodes.Health()
+}
+
 func (s *Submitter) Submit(msg msg.Message) error {
 	return nil
 }
 
 func (s *Submitter) Hook(ctx context.Context, wg *sync.WaitGroup, ch <-chan msg.Message) error {
-	s.Context = ctx
+	s.Context, s.cancel = context.WithCancel(ctx)
 	s.wg = wg
 	return nil
 }
@@ -93,7 +193,8 @@ func (s *Submitter) SubmitHeadersWithLoop(chainId uint64, headers [][]byte, head
 	h := uint64(0)
 	if len(headers) > 0 {
 		err = s.submitHeadersWithLoop(chainId, headers, header)
-		if err == nil && header != nil {
+		// Dry run submits nothing, so poly has no commit to check
+		if err == nil && header != nil && !s.dryRun() {
 			// Check last commit every 4 successful submit
 			if s.lastCommit > 0 && s.lastCheck > 3 {
 				s.lastCheck = 0
@@ -117,7 +218,9 @@ func (s *Submitter) SubmitHeadersWithLoop(chainId uint64, headers [][]byte, head
 	if header != nil {
 		h = header.Height
 		if err == nil {
-			s.state.HeightMark(h)        // Mark header sync height
+			if !s.dryRun() {
+				s.state.HeightMark(h) // Mark header sync height
+			}
 			s.lastCommit = header.Height // Mark last commit
 		}
 	}
@@ -126,7 +229,7 @@ func (s *Submitter) SubmitHeadersWithLoop(chainId uint64, headers [][]byte, head
 }
 
 func (s *Submitter) submitHeadersWithLoop(chainId uint64, headers [][]byte, header *msg.Header) error {
-	attempt := 0
+	attempt, forks := 0, 0
 	var ok bool
 	for {
 		var err error
@@ -135,8 +238,14 @@ func (s *Submitter) submitHeadersWithLoop(chainId uint64, headers [][]byte, head
 			if ok {
 				return nil
 			}
-			if err != nil {
-				log.Error("Failed to check header existence", "chain", chainId, "height", header.Height)
+			if errors.Is(err, msg.ERR_NODES_INCONSISTENT) {
+				// Disagreeing nodes tell nothing of the header, submit it as missing
+				log.Warn("Submitting header not agreed by poly nodes", "chain", chainId, "height", header.Height, "err", err)
+				err = nil
+			} else if err != nil {
+				// Failed checks count against the attempts, so an erroring node can not hold the sync forever
+				attempt += 1
+				log.Error("Failed to check header existence", "chain", chainId, "height", header.Height, "err", err)
 			}
 		}
 
@@ -146,14 +255,26 @@ func (s *Submitter) submitHeadersWithLoop(chainId uint64, headers [][]byte, head
 			if err == nil {
 				return nil
 			}
-			info := err.Error()
-			if strings.Contains(info, "parent header not exist") ||
-				strings.Contains(info, "missing required field") ||
-				strings.Contains(info, "parent block failed") ||
-				strings.Contains(info, "span not correct") ||
-				strings.Contains(info, "VerifySpan err") {
+			if headerInconsistent(err) {
+				if s.sync != nil && forks < s.sync.ForkRetryLimit {
+					// Side chain headers may be rejected while the poly node catches up
+					forks++
+					delay := forkBackoff(forks)
+					log.Warn("Retrying header submit on possible fork", "chain", chainId, "retries", forks, "delay", delay, "err", err)
+					select {
+					case <-s.Done():
+						return nil
+					case <-s.timer()(delay):
+					}
+					continue
+				}
 				//NOTE: reset header height back here
 				log.Error("Possible hard fork, will rollback some blocks", "chain", chainId, "err", err)
+				fields := map[string]interface{}{"chain": chainId, "err": err.Error()}
+				if header != nil {
+					fields["height"] = header.Height
+				}
+				s.alert(ALERT_ERROR, "Header sync rolling back on possible fork", fields)
 				return msg.ERR_HEADER_INCONSISTENT
 			}
 			log.Error("Failed to submit header to poly", "chain", chainId, "err", err)
@@ -167,87 +288,423 @@ func (s *Submitter) submitHeadersWithLoop(chainId uint64, headers [][]byte, head
 				log.Error("Header submit too many failed attempts", "chain", chainId, "attempts", attempt)
 				return msg.ERR_HEADER_SUBMIT_FAILURE
 			}
-			time.Sleep(time.Second)
+			s.wait(time.Second)
 		}
 	}
 }
 
+// Replace the poly signer with the account of the wallet, the swap waits for the txs in flight with the
+// current signer so no tx is sent by the old account after the rotation
+func (s *Submitter) SetSigner(config *wallet.Config) error {
+	signer, err := wallet.NewPolySigner(config)
+	if err != nil {
+		return err
+	}
+	s.setSigner(signer)
+	return nil
+}
+
+func (s *Submitter) setSigner(signer *sdk.Account) {
+	s.signerLock.Lock()
+	defer s.signerLock.Unlock()
+	var old string
+	if s.signer != nil {
+		old = s.signer.Address.ToBase58()
+	}
+	s.signer = signer
+	log.Info("Rotated poly signer", "chain", s.name, "old", old, "new", signer.Address.ToBase58())
+}
+
+// Current poly signer
+func (s *Submitter) account() *sdk.Account {
+	s.signerLock.RLock()
+	defer s.signerLock.RUnlock()
+	return s.signer
+}
+
+// Account used to sign header sync txs
+func (s *Submitter) headerAccount() *sdk.Account {
+	if s.headerSigner != nil {
+		return s.headerSigner
+	}
+	return s.signer
+}
+
+// Submit headers with the primary node, falling back to other nodes on failure
 func (s *Submitter) SubmitHeaders(chainId uint64, headers [][]byte) (hash string, err error) {
-	tx, err := s.sdk.Node().Native.Hs.SyncBlockHeader(
-		chainId, s.signer.Address, headers, s.signer,
-	)
+	if s.dryRun() {
+		hash = dryRunHash(fmt.Sprintf("headers:%d:%x", chainId, headers))
+		headersLog(chainId, headers).Info("Dry run skipped submitting headers to poly", "hash", hash)
+		return
+	}
+	// Slots are held per attempt, so a chain retrying failed submits does not stall the others
+	if !s.headers.acquire(s.ctx()) {
+		return "", s.ctx().Err()
+	}
+	defer s.headers.release()
+	err = s.breaker.Call(func() (err error) {
+		nodes := s.nodes.Select(s.sdk.Node(), s.sdk.AllNodes())
+		hash, err = submitOnNodes(nodes[0], nodes, func(node *poly.Client) (string, error) {
+			start := time.Now()
+			hash, err := s.submitHeaders(node, chainId, headers)
+			s.nodes.Report(node, time.Since(start), err)
+			return hash, err
+		})
+		return
+	})
+	err = classifyHeaderError(err)
+	return
+}
+
+// Substrings of poly header sync errors telling the headers do not link to the synced side chain
+var HEADER_FORK_ERRORS = []string{"parent header not exist", "parent block failed", "span not correct", "VerifySpan err"}
+
+// Substrings of poly header sync errors telling the headers can not be decoded
+var HEADER_MALFORMED_ERRORS = []string{"missing required field"}
+
+// Wrap the header submit error with the fork or malformed header sentinel it matches
+func classifyHeaderError(err error) error {
+	if err == nil || errors.Is(err, msg.ERR_HEADER_FORK) || errors.Is(err, msg.ERR_HEADER_MALFORMED) {
+		return err
+	}
+	if matchError(err, HEADER_FORK_ERRORS) != "" {
+		return fmt.Errorf("%w, %v", msg.ERR_HEADER_FORK, err)
+	}
+	if matchError(err, HEADER_MALFORMED_ERRORS) != "" {
+		return fmt.Errorf("%w, %v", msg.ERR_HEADER_MALFORMED, err)
+	}
+	return err
+}
+
+// Whether the header sync should roll back for the submit error, unclassified errors are matched by message
+func headerInconsistent(err error) bool {
+	err = classifyHeaderError(err)
+	return errors.Is(err, msg.ERR_HEADER_FORK) || errors.Is(err, msg.ERR_HEADER_MALFORMED)
+}
+
+func (s *Submitter) submitHeaders(node *poly.Client, chainId uint64, headers [][]byte) (hash string, err error) {
+	s.signerLock.RLock()
+	defer s.signerLock.RUnlock()
+	signer := s.headerAccount()
+	gas, err := s.headerGas()
+	if err != nil {
+		return "", err
+	}
+	t, err := node.Native.Hs.NewSyncBlockHeaderTransaction(chainId, signer.Address, headers)
+	if err != nil {
+		return "", err
+	}
+	tx, err := sendWithGas(node, t, gas, signer)
 	if err != nil {
 		return "", err
 	}
 	hash = tx.ToHexString()
-	_, err = s.sdk.Node().Confirm(hash, 0, 300)
+	err = s.confirm(node, hash, CONFIRM_ROUNDS)
 	if err == nil {
-		log.Info("Submitted header to poly", "chain", chainId, "hash", hash)
+		headersLog(chainId, headers).Info("Submitted header to poly", "hash", hash, "node", node.Address())
 	}
 	return
 }
 
+// Errors collected from each node attempt
+type NodeErrors []error
+
+func (e NodeErrors) Error() string {
+	info := make([]string, len(e))
+	for i, err := range e {
+		info[i] = err.Error()
+	}
+	return strings.Join(info, "; ")
+}
+
+// Is matches the target against the error of each node
+func (e NodeErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Try submit on the primary node first, then the remaining nodes until one succeeds. Unconfirmed txs
+// were already sent, so they fail the submit instead of sending the same headers on the next node.
+func submitOnNodes(primary *poly.Client, nodes []*poly.Client, submit func(*poly.Client) (string, error)) (hash string, err error) {
+	candidates := []*poly.Client{primary}
+	for _, node := range nodes {
+		if node != primary {
+			candidates = append(candidates, node)
+		}
+	}
+	var errs NodeErrors
+	for i, node := range candidates {
+		hash, err = submit(node)
+		if err == nil {
+			return
+		}
+		errs = append(errs, fmt.Errorf("node %d %s: %w", i, node.Address(), err))
+		if errors.Is(err, msg.ERR_TX_UNCONFIRMED) {
+			break
+		}
+		if i+1 < len(candidates) {
+			log.Warn("Submit headers to poly node failed, trying next node", "node", node.Address(), "err", err)
+		}
+	}
+	return "", errs
+}
+
+// Outcome of a src tx submit, the poly hash is empty if the tx was imported before or skipped
+type SubmitResult struct {
+	PolyHash string
+	Signer   string        // Base58 address of the signer sending the poly tx
+	Nonce    uint32        // Nonce of the last sent poly tx
+	Elapsed  time.Duration // Time spent composing and importing the tx
+}
+
+// SubmitTx composes and imports a copy of the src tx to poly, leaving the tx untouched so it can be shared.
+// The composer should be set by Start or ProcessTx.
+func (s *Submitter) SubmitTx(tx *msg.Tx) (SubmitResult, error) {
+	t := *tx
+	return s.submitTx(&t)
+}
+
+// Submit the src tx keeping the composed fields and the poly hash on the tx for retries
 func (s *Submitter) submit(tx *msg.Tx) error {
-	err := s.composer.Compose(tx)
+	res, err := s.submitTx(tx)
+	if res.PolyHash != "" {
+		tx.PolyHash = res.PolyHash
+	}
+	return err
+}
+
+func (s *Submitter) submitTx(tx *msg.Tx) (res SubmitResult, err error) {
+	start := time.Now()
+	ctx, span := s.startTxSpan(tx, "submit")
+	defer func() {
+		res.Elapsed = time.Since(start)
+		endSpan(span, err)
+	}()
+
+	err = s.composer.Compose(tx)
 	if err != nil {
 		if strings.Contains(err.Error(), "missing trie node") {
-			return msg.ERR_PROOF_UNAVAILABLE
+			err = msg.ERR_PROOF_UNAVAILABLE
 		}
-		return err
+		return
 	}
+	return s.importTx(ctx, tx)
+}
+
+// Import the composed src tx to poly, checking the method and the done tx record first
+func (s *Submitter) importTx(ctx context.Context, tx *msg.Tx) (res SubmitResult, err error) {
 	if tx.Param == nil || tx.SrcChainId == 0 {
-		return fmt.Errorf("%s submitter src tx %s param is missing or src chain id not specified", s.name, tx.SrcHash)
+		err = fmt.Errorf("%s submitter src tx %s param is missing or src chain id not specified", s.name, tx.SrcHash)
+		return
 	}
 
 	if !config.CONFIG.AllowMethod(tx.Param.Method) {
-		log.Error("Invalid src tx method", "src_hash", tx.SrcHash, "chain", s.name, "method", tx.Param.Method)
-		return nil
+		s.txLog(tx).Error("Invalid src tx method", "method", tx.Param.Method)
+		return
 	}
 
 	if tx.SrcStateRoot == nil {
 		tx.SrcStateRoot = []byte{}
 	}
 
-	var account []byte
+	// Hold the signer till the tx is sent, so a rotation never interleaves with the tx
+	s.signerLock.RLock()
+	defer s.signerLock.RUnlock()
+	signer := s.signer
+	res.Signer = signer.Address.ToBase58()
+
+	account := importAccount(tx.SrcChainId, signer)
 	switch tx.SrcChainId {
 	case base.NEO, base.ONT:
-		account = s.signer.Address[:]
 		if len(tx.SrcStateRoot) == 0 || len(tx.SrcProof) == 0 {
-			return fmt.Errorf("%s submitter src tx src state root(%x) or src proof(%x) missing for chain %d with tx %s", s.name, tx.SrcStateRoot, tx.SrcProof, tx.SrcChainId, tx.SrcHash)
+			err = fmt.Errorf("%s submitter src tx src state root(%x) or src proof(%x) missing for chain %d with tx %s", s.name, tx.SrcStateRoot, tx.SrcProof, tx.SrcChainId, tx.SrcHash)
+			return
 		}
 	default:
-		// For other chains, reversed?
-		account = common.Hex2Bytes(s.signer.Address.ToHexString())
-
 		// Check done tx existence
-		data, _ := s.sdk.Node().GetDoneTx(tx.SrcChainId, tx.Param.CrossChainID)
-		if len(data) != 0 {
-			log.Info("Tx already imported", "src_hash", tx.SrcHash)
-			return nil
+		done, _ := doneTx(s.sdk.Node(), s.ccm(), tx.SrcChainId, tx.Param.CrossChainID)
+		if done {
+			s.txLog(tx).Info("Tx already imported")
+			return
 		}
 	}
 
-	t, err := s.sdk.Node().Native.Ccm.ImportOuterTransfer(
-		tx.SrcChainId,
-		tx.SrcEvent,
-		uint32(tx.SrcProofHeight),
-		tx.SrcProof,
-		account,
-		tx.SrcStateRoot,
-		s.signer,
-	)
+	if s.dryRun() {
+		res.PolyHash = dryRunHash(fmt.Sprintf("tx:%d:%s", tx.SrcChainId, tx.SrcHash))
+		s.txLog(tx).Info("Dry run skipped importing tx to poly", "poly_hash", res.PolyHash,
+			"proof_height", tx.SrcProofHeight, "event", hex.EncodeToString(tx.SrcEvent), "proof", hex.EncodeToString(tx.SrcProof),
+			"state_root", hex.EncodeToString(tx.SrcStateRoot), "account", hex.EncodeToString(account))
+		return
+	}
+
+	if err = s.breaker.Allow(); err != nil {
+		return
+	}
+	gas, err := s.importGas()
+	if err != nil {
+		return
+	}
+	_, call := s.startSpan(ctx, "ImportOuterTransfer")
+	hash, err := resendOnErrors(s.resendErrors(), func() (string, error) {
+		node := s.nodes.Node(s.sdk)
+		start := time.Now()
+		t, err := newImportTx(node, s.ccm(), tx, account)
+		if err != nil {
+			s.nodes.Report(node, time.Since(start), err)
+			return "", err
+		}
+		g, err := s.autoLimit(gas, node, t)
+		if err != nil {
+			s.nodes.Report(node, time.Since(start), err)
+			return "", err
+		}
+		start = time.Now()
+		res.Nonce = t.Nonce
+		h, err := sendWithGas(node, t, g, signer)
+		s.nodes.Report(node, time.Since(start), err)
+		if err != nil {
+			return "", err
+		}
+		return h.ToHexString(), nil
+	})
+	endSpan(call, err)
+	s.breaker.Done(!nodeFailure(err))
 	if err != nil {
 		if strings.Contains(err.Error(), "tx already done") {
-			log.Info("Tx already imported", "src_hash", tx.SrcHash, "chain", tx.SrcChainId)
-			return nil
+			s.txLog(tx).Info("Tx already imported")
+			return res, nil
 		} else if strings.Contains(err.Error(), "verifyMerkleProof error") {
-			log.Error("Tx verifyMerkleProof err", "src_hash", tx.SrcHash, "chain", tx.SrcChainId, "err", err)
-			return msg.ERR_Tx_VERIFYMERKLEPROOF
+			s.txLog(tx).Error("Tx verifyMerkleProof err", "err", err)
+			return res, msg.ERR_Tx_VERIFYMERKLEPROOF
 		}
-		return fmt.Errorf("Failed to import tx to poly, %v tx src hash %s", err, tx.SrcHash)
+		return res, fmt.Errorf("Failed to import tx to poly, %v tx src hash %s", err, tx.SrcHash)
+	}
+	res.PolyHash = hash
+	if s.config != nil && s.config.ConfirmTimeout > 0 {
+		err = s.confirm(s.sdk.Node(), hash, s.config.ConfirmTimeout)
+		if err != nil {
+			return res, fmt.Errorf("%w, tx src hash %s", err, tx.SrcHash)
+		}
+	}
+	s.recordLatency(tx)
+	return
+}
+
+// Poly cross chain manager contract of the submitter
+func (s *Submitter) ccm() string {
+	if s.config == nil {
+		return ccmAddress("")
+	}
+	return ccmAddress(s.config.CCMContract)
+}
+
+// Relayer account of the ImportOuterTransfer tx for the src chain
+func importAccount(chainId uint64, signer *sdk.Account) []byte {
+	switch chainId {
+	case base.NEO, base.ONT:
+		return signer.Address[:]
+	}
+	// For other chains, reversed?
+	return common.Hex2Bytes(signer.Address.ToHexString())
+}
+
+// Check if the src tx was imported to poly by the cross chain id, txId takes the form of msg.Tx.TxId,
+// normalized by the src chain, see msg.NormalizeTxId. Poly keeps no index from the src tx to the poly tx,
+// so the poly hash is left empty.
+func (s *Submitter) IsImported(srcChainId uint64, txId string) (imported bool, polyHash string, err error) {
+	return isImported(s.sdk.Node(), s.ccm(), srcChainId, txId)
+}
+
+func isImported(node *poly.Client, contract string, srcChainId uint64, txId string) (imported bool, polyHash string, err error) {
+	id := msg.NormalizeTxId(srcChainId, normalizeHash(txId))
+	ccId, err := hex.DecodeString(id)
+	if err != nil || len(ccId) == 0 {
+		return false, "", fmt.Errorf("Invalid src tx id %s, %v", txId, err)
+	}
+	imported, err = doneTx(node, contract, srcChainId, ccId)
+	return
+}
+
+// Max resends of a poly tx on recognized tx pool errors
+const MAX_RESEND = 3
+
+// Substrings of poly tx pool errors recovered by resending the tx with a fresh nonce
+var DEFAULT_RESEND_ERRORS = []string{"nonce too low", "already in pool", "duplicated transaction"}
+
+func (s *Submitter) resendErrors() []string {
+	if s.config != nil && len(s.config.ResendErrors) > 0 {
+		return s.config.ResendErrors
+	}
+	return DEFAULT_RESEND_ERRORS
+}
+
+// Send the tx, resending on errors matching the patterns. Poly sdk signs each send with a random nonce.
+func resendOnErrors(patterns []string, send func() (string, error)) (hash string, err error) {
+	for i := 0; ; i++ {
+		hash, err = send()
+		pattern := matchError(err, patterns)
+		if pattern == "" || i >= MAX_RESEND {
+			return
+		}
+		log.Warn("Resending poly tx with a fresh nonce", "match", pattern, "resend", i+1, "err", err)
+	}
+}
+
+// First pattern contained in the error message
+func matchError(err error, patterns []string) string {
+	if err == nil {
+		return ""
+	}
+	info := strings.ToLower(err.Error())
+	for _, pattern := range patterns {
+		if pattern != "" && strings.Contains(info, strings.ToLower(pattern)) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// Prefix of the synthetic poly hashes of dry run submits
+const DRY_RUN_HASH_PREFIX = "dryrun-"
+
+// Whether txs and headers are validated and logged only, without being signed or sent to poly
+func (s *Submitter) dryRun() bool {
+	return s.config != nil && s.config.DryRun
+}
+
+// Synthetic poly hash for dry run submits, prefixed to tell it from real tx hashes
+func dryRunHash(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return DRY_RUN_HASH_PREFIX + hex.EncodeToString(hash[:])
+}
+
+// Record the elapsed time from src block time to poly import when the src block time is known
+func (s *Submitter) recordLatency(tx *msg.Tx) {
+	if tx.SrcTime <= 0 {
+		return
+	}
+	elapse := time.Since(time.Unix(tx.SrcTime, 0))
+	if elapse < 0 {
+		log.Warn("Src tx block time ahead of local clock", "src_hash", tx.SrcHash, "src_time", tx.SrcTime)
+		return
+	}
+	RelayLatency.Observe(elapse.Seconds())
+}
+
+// Report the backlog age of the tx being processed, warning once it crosses the configured alert
+func (s *Submitter) recordBacklogAge(tx *msg.Tx) {
+	if tx.EnqueuedAt <= 0 {
+		return
+	}
+	age := tx.BacklogAge()
+	BacklogAge.Set(s.name, age.Seconds())
+	if s.config != nil && s.config.BacklogAgeAlert > 0 && age > time.Duration(s.config.BacklogAgeAlert)*time.Second {
+		s.txLog(tx).Warn("Poly submitter tx backlog aged", "age", age, "alert", s.config.BacklogAgeAlert)
 	}
-	tx.PolyHash = t.ToHexString()
-	return nil
 }
 
 func (s *Submitter) ProcessTx(m *msg.Tx, composer msg.SrcComposer) (err error) {
@@ -262,32 +719,214 @@ func (s *Submitter) Process(msg msg.Message) error {
 	return nil
 }
 
+// Stop waits for the workers to exit, if they do not exit within the configured stop timeout,
+// the submitter context will be cancelled and an error returned.
 func (s *Submitter) Stop() error {
-	s.wg.Wait()
-	return nil
+	if s.wg == nil {
+		return nil
+	}
+	if s.config == nil || s.config.StopTimeout <= 0 {
+		s.wg.Wait()
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Duration(s.config.StopTimeout) * time.Second):
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	inflight := atomic.LoadInt64(&s.inflight)
+	log.Error("Poly submitter workers did not exit in time", "chain", s.name, "timeout", s.config.StopTimeout, "inflight", inflight)
+	return fmt.Errorf("%w, chain %s with %d txs in flight", msg.ERR_STOP_TIMEOUT, s.name, inflight)
+}
+
+func (s *Submitter) submitTracked(tx *msg.Tx) error {
+	atomic.AddInt64(&s.inflight, 1)
+	defer atomic.AddInt64(&s.inflight, -1)
+	return s.submit(tx)
 }
 
 func (s *Submitter) CollectSigs(tx *msg.Tx) (err error) {
-	var (
-		sigs []byte
-	)
-	sigHeader := tx.PolyHeader
+	hdr := sigHeader(tx)
+	sigs, err := headerSigs(hdr)
+	if err != nil {
+		return
+	}
+	return s.applySigs(tx, sigs)
+}
+
+// Collect the sigs of txs sharing poly headers, converting the sigs of each header once. Returns the
+// error of the first tx failed, the txs before it have the sigs collected.
+func (s *Submitter) CollectSigsBatch(txs []*msg.Tx) (err error) {
+	converted := map[pcom.Uint256]*polySigs{}
+	for _, tx := range txs {
+		hdr := sigHeader(tx)
+		hash := hdr.Hash()
+		sigs, ok := converted[hash]
+		if !ok {
+			sigs, err = headerSigs(hdr)
+			if err != nil {
+				return fmt.Errorf("Collect sigs for poly tx %s error %w", tx.PolyHash, err)
+			}
+			converted[hash] = sigs
+		}
+		err = s.applySigs(tx, sigs)
+		if err != nil {
+			return fmt.Errorf("Collect sigs for poly tx %s error %w", tx.PolyHash, err)
+		}
+	}
+	return
+}
+
+// Eth compatible sigs of a poly header with the recovered signers, shared by the txs of the header
+type polySigs struct {
+	header  *types.Header
+	sigs    [][]byte
+	signers []common.Address
+}
+
+// Header signing the tx, the anchor header when the tx is proven by an anchor
+func sigHeader(tx *msg.Tx) *types.Header {
 	if tx.AnchorHeader != nil && tx.AnchorProof != "" {
-		sigHeader = tx.AnchorHeader
+		return tx.AnchorHeader
 	}
-	for _, sig := range sigHeader.SigData {
+	return tx.PolyHeader
+}
+
+// Convert the poly header sigs to eth compatible ones, dropping duplicate signers and checking the quorum
+func headerSigs(hdr *types.Header) (*polySigs, error) {
+	// Headers served unsigned by the node would only fail on the dst chain
+	if len(hdr.SigData) == 0 {
+		return nil, fmt.Errorf("%w, poly header %d", msg.ERR_EMPTY_SIGDATA, hdr.Height)
+	}
+	sigs := make([][]byte, len(hdr.SigData))
+	for i, sig := range hdr.SigData {
 		temp := make([]byte, len(sig))
 		copy(temp, sig)
-		s, err := signature.ConvertToEthCompatible(temp)
+		var err error
+		sigs[i], err = signature.ConvertToEthCompatible(temp)
 		if err != nil {
-			return fmt.Errorf("MakeTx signature.ConvertToEthCompatible %v", err)
+			return nil, fmt.Errorf("MakeTx signature.ConvertToEthCompatible %v", err)
 		}
-		sigs = append(sigs, s...)
 	}
-	tx.PolySigs = sigs
+	signers, err := recoverSigners(hdr, sigs)
+	if err != nil {
+		return nil, err
+	}
+	sigs, signers = dedupSigs(hdr, sigs, signers)
+	if n := len(hdr.Bookkeepers); n > 0 && len(sigs) < n-(n-1)/3 {
+		return nil, fmt.Errorf("%w, %d sigs for %d bookkeepers on poly header %d", msg.ERR_INSUFFICIENT_SIGS, len(sigs), n, hdr.Height)
+	}
+	return &polySigs{header: hdr, sigs: sigs, signers: signers}, nil
+}
+
+// Order and encode a copy of the header sigs for the dst chain of the tx
+func (s *Submitter) applySigs(tx *msg.Tx, shared *polySigs) (err error) {
+	sigs := make([][]byte, len(shared.sigs))
+	for i, sig := range shared.sigs {
+		sigs[i] = append([]byte(nil), sig...)
+	}
+	signers := append([]common.Address(nil), shared.signers...)
+	if s.config != nil && s.config.SortSigs(tx.DstChainId) {
+		sortSigs(sigs, signers)
+	}
+	if s.config != nil {
+		if enc := s.config.SigEncoding(tx.DstChainId); enc != nil {
+			for _, sig := range sigs {
+				err = encodeSigV(sig, enc)
+				if err != nil {
+					return fmt.Errorf("Encode poly header sig for chain %d error %v", tx.DstChainId, err)
+				}
+			}
+		}
+	}
+	tx.PolySigs, err = s.proofEncoder(tx.DstChainId).EncodeSigs(shared.header, sigs)
+	if err != nil {
+		return fmt.Errorf("Encode poly header sigs for chain %d error %v", tx.DstChainId, err)
+	}
 	return
 }
 
+// Replace the recovery id of the eth compatible sig with the v value of the encoding
+func encodeSigV(sig []byte, enc *config.SigEncoding) error {
+	if len(sig) != 65 || sig[64] > 1 {
+		return fmt.Errorf("invalid eth compatible sig")
+	}
+	var v uint64
+	switch enc.Scheme {
+	case config.SIG_V_LEGACY:
+		v = uint64(sig[64]) + 27
+	case config.SIG_V_EIP155:
+		v = uint64(sig[64]) + enc.ChainId*2 + 35
+	default:
+		return fmt.Errorf("unknown sig encoding scheme %q", enc.Scheme)
+	}
+	if v > 0xff {
+		return fmt.Errorf("sig v value %d overflows a byte with chain id %d", v, enc.ChainId)
+	}
+	sig[64] = byte(v)
+	return nil
+}
+
+// Recover the signer addresses of the eth compatible sigs on the poly header
+func recoverSigners(hdr *types.Header, sigs [][]byte) ([]common.Address, error) {
+	hash := hdr.Hash()
+	digest := sha256.Sum256(hash[:])
+	signers := make([]common.Address, len(sigs))
+	for i, sig := range sigs {
+		pub, err := crypto.SigToPub(digest[:], sig)
+		if err != nil {
+			return nil, fmt.Errorf("Recover poly header %d sig signer error %v", hdr.Height, err)
+		}
+		signers[i] = crypto.PubkeyToAddress(*pub)
+	}
+	return signers, nil
+}
+
+// Drop the duplicate sigs of the same signer, keeping the first one in order
+func dedupSigs(hdr *types.Header, sigs [][]byte, signers []common.Address) ([][]byte, []common.Address) {
+	seen := make(map[common.Address]bool, len(signers))
+	var (
+		uniqueSigs    [][]byte
+		uniqueSigners []common.Address
+	)
+	for i, signer := range signers {
+		if seen[signer] {
+			log.Warn("Dropping duplicate poly header sig", "height", hdr.Height, "signer", signer.Hex(), "index", i)
+			continue
+		}
+		seen[signer] = true
+		uniqueSigs = append(uniqueSigs, sigs[i])
+		uniqueSigners = append(uniqueSigners, signer)
+	}
+	return uniqueSigs, uniqueSigners
+}
+
+// Sort eth compatible sigs by the recovered signer address
+func sortSigs(sigs [][]byte, signers []common.Address) {
+	type signed struct {
+		signer common.Address
+		sig    []byte
+	}
+	list := make([]signed, len(sigs))
+	for i, sig := range sigs {
+		list[i] = signed{signers[i], sig}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].signer[:], list[j].signer[:]) < 0
+	})
+	for i, item := range list {
+		sigs[i] = item.sig
+	}
+}
+
 func (s *Submitter) ReadyBlock() (height uint64) {
 	var err error
 	switch s.config.ChainId {
@@ -306,19 +945,28 @@ func (s *Submitter) ReadyBlock() (height uint64) {
 }
 
 func (s *Submitter) consume(mq bus.SortedTxBus) error {
+	return s.consumeWith(s.Context, mq)
+}
+
+// Consume the tx bus till the context is done, which is the submitter context or a child of it
+func (s *Submitter) consumeWith(ctx context.Context, mq bus.SortedTxBus) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
 	ticker := time.NewTicker(300 * time.Millisecond)
 	defer ticker.Stop()
 
 	height := s.ReadyBlock()
+	idle := s.newIdleBackoff()
 	for {
 		select {
-		case <-s.Done():
+		case <-ctx.Done():
 			log.Info("Submitter is exiting now", "chain", s.name)
 			return nil
 		default:
 		}
+		if !s.pause.Wait(ctx) {
+			continue
+		}
 
 		select {
 		case <-ticker.C:
@@ -330,46 +978,62 @@ func (s *Submitter) consume(mq bus.SortedTxBus) error {
 		default:
 		}
 
-		tx, block, err := mq.Pop(s.Context)
+		tx, block, err := mq.PopTimed(ctx, BUS_POP_TIMEOUT)
 		if err != nil {
 			log.Error("Bus pop error", "err", err)
+			idle.Wait(ctx)
 			continue
 		}
 		if tx == nil {
-			time.Sleep(200 * time.Millisecond)
+			idle.Wait(ctx)
+			continue
+		}
+		idle.Reset()
+		// Paused while blocked in the pop, hold the tx till resumed
+		if !s.pause.Wait(ctx) {
+			s.pushBack(tx, "tx bus", func(ctx context.Context) error { return mq.Push(ctx, tx, block) })
+			continue
+		}
+		key, seen := s.duplicated(tx)
+		if seen {
 			continue
 		}
+		s.recordBacklogAge(tx)
 
 		if block <= height {
 			log.Info("Processing src tx", "src_hash", tx.SrcHash, "src_chain", tx.SrcChainId, "dst_chain", tx.DstChainId)
-			err = s.submit(tx)
+			err = s.submitTracked(tx)
 			if err == nil {
 				log.Info("Submitted src tx to poly", "src_hash", tx.SrcHash, "poly_hash", tx.PolyHash)
+				s.markProcessed(tx, key)
 				continue
 			}
 
-			if errors.Is(err, msg.ERR_Tx_VERIFYMERKLEPROOF) {
-				log.Warn("src tx submit to poly verifyMerkleProof failed, clear src proof", "chain", s.name, "src hash", tx.SrcHash, "err", err)
-				tx.SrcProofHex = ""
-				tx.SrcProof = []byte{}
+			if !s.submitFailed(tx, err) {
+				continue
 			}
-
-			if strings.Contains(err.Error(), "side chain") && strings.Contains(err.Error(), "not registered") {
-				log.Warn("Submit src tx to poly error", "chain", s.name, "err", err, "proof_height", tx.SrcProofHeight)
+			if s.retry != nil {
+				// Keep failed txs apart from fresh ones
+				s.pushBack(tx, "retry bus", func(ctx context.Context) error { return s.retry.Push(ctx, tx) })
 				continue
 			}
-
 			block = height + 10
-			tx.Attempts++
-			log.Error("Submit src tx to poly error", "chain", s.name, "err", err, "proof_height", tx.SrcProofHeight, "next_try", block)
-			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx, block) })
+			s.txLog(tx).Info("Requeued src tx to tx bus", "next_try", block)
+			s.requeueWait(tx, err)
+			s.pushBack(tx, "tx bus", func(ctx context.Context) error { return mq.Push(ctx, tx, block) })
 		} else {
-			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx, block) })
-			time.Sleep(200 * time.Millisecond)
+			s.pushBack(tx, "tx bus", func(ctx context.Context) error { return mq.Push(ctx, tx, block) })
+			select {
+			case <-ctx.Done():
+			case <-s.timer()(200 * time.Millisecond):
+			}
 		}
 	}
 }
 
+// Max wait of a single tx bus pop, as blocking redis pops are not interrupted by the context cancel
+const BUS_POP_TIMEOUT = time.Second
+
 func (s *Submitter) run(mq bus.TxBus) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
@@ -378,6 +1042,7 @@ func (s *Submitter) run(mq bus.TxBus) error {
 
 	height := s.ReadyBlock()
 	refresh := true
+	idle := s.newIdleBackoff()
 
 	for {
 		select {
@@ -386,6 +1051,9 @@ func (s *Submitter) run(mq bus.TxBus) error {
 			return nil
 		default:
 		}
+		if !s.pause.Wait(s.Context) {
+			continue
+		}
 
 		if refresh {
 			select {
@@ -396,35 +1064,44 @@ func (s *Submitter) run(mq bus.TxBus) error {
 			}
 		}
 
-		tx, err := mq.Pop(s.Context)
+		tx, err := mq.PopTimed(s.Context, BUS_POP_TIMEOUT)
 		if err != nil {
 			log.Error("Bus pop error", "err", err)
 			continue
 		}
 		if tx == nil {
-			time.Sleep(time.Second)
+			idle.Wait(s.Context)
+			continue
+		}
+		idle.Reset()
+		// Paused while blocked in the pop, hold the tx till resumed
+		if !s.pause.Wait(s.Context) {
+			s.pushBack(tx, "tx bus", func(ctx context.Context) error { return mq.Push(ctx, tx) })
+			continue
+		}
+		key, seen := s.duplicated(tx)
+		if seen {
 			continue
 		}
+		s.recordBacklogAge(tx)
 
-		log.Debug("Poly submitter checking on src tx", "src_hash", tx.SrcHash, "src_chain", tx.SrcChainId)
+		s.txLog(tx).Debug("Poly submitter checking on src tx")
 		retry := true
 
 		if height == 0 || tx.SrcHeight <= height {
-			log.Info("Processing src tx", "src_hash", tx.SrcHash, "src_chain", tx.SrcChainId, "dst_chain", tx.DstChainId)
-			err = s.submit(tx)
+			s.txLog(tx).Info("Processing src tx", "dst_chain", tx.DstChainId)
+			err = s.submitTracked(tx)
 			if err != nil {
-				log.Error("Submit src tx to poly error", "chain", s.name, "err", err, "proof_height", tx.SrcProofHeight)
-				tx.Attempts++
-				if errors.Is(err, msg.ERR_Tx_VERIFYMERKLEPROOF) {
-					log.Warn("src tx submit to poly verifyMerkleProof failed, clear src proof", "chain", s.name, "src hash", tx.SrcHash, "err", err)
-					tx.SrcProofHex = ""
-					tx.SrcProof = []byte{}
-				}
-				if strings.Contains(err.Error(), "side chain") && strings.Contains(err.Error(), "not registered") {
+				retry = s.submitFailed(tx, err)
+				if retry && s.retry != nil {
+					// Keep failed txs apart from fresh ones
+					s.pushBack(tx, "retry bus", func(ctx context.Context) error { return s.retry.Push(ctx, tx) })
+					s.waitRetryAfter(err)
 					retry = false
 				}
 			} else {
-				log.Info("Submitted src tx to poly", "src_hash", tx.SrcHash, "poly_hash", tx.PolyHash)
+				s.txLog(tx).Info("Submitted src tx to poly")
+				s.markProcessed(tx, key)
 				retry = false
 			}
 			if height == 0 {
@@ -435,31 +1112,403 @@ func (s *Submitter) run(mq bus.TxBus) error {
 		}
 
 		if retry {
-			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx) })
+			s.requeueWait(tx, err)
+			s.pushBack(tx, "tx bus", func(ctx context.Context) error { return mq.Push(ctx, tx) })
+		}
+	}
+}
+
+// Throttle pushing back the failed tx by the requeue rate limit and the tx attempts, so a poly outage
+// does not spin the workers on the bus. A throttling poly node's Retry-After hint is honored even without
+// the requeue rate limit. The tx is still pushed back on exit.
+func (s *Submitter) requeueWait(tx *msg.Tx, err error) {
+	var delay time.Duration
+	if s.requeues != nil {
+		delay = s.requeues.Reserve()
+		if backoff := s.requeueBackoff(tx.Attempts); backoff > delay {
+			delay = backoff
+		}
+	}
+	if hint, _ := retryAfter(err); hint > delay {
+		delay = hint
+	}
+	s.wait(delay)
+}
+
+// Wait for the Retry-After hint of the error from a throttling poly node
+func (s *Submitter) waitRetryAfter(err error) {
+	if hint, limited := retryAfter(err); limited {
+		log.Warn("Poly node rate limited, waiting before the next attempt", "retry_after", hint)
+		s.wait(hint)
+	}
+}
+
+// Wait for the delay or till the submitter exits
+func (s *Submitter) wait(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-s.Done():
+	case <-s.timer()(delay):
+	}
+}
+
+// Backoff bounds of pushing a tx back to the bus, and the timeout of the last push on exit
+const (
+	PUSH_RETRY_BASE    = time.Second
+	PUSH_RETRY_MAX     = 30 * time.Second
+	PUSH_FLUSH_TIMEOUT = 5 * time.Second
+)
+
+// Push the tx back to the bus, retrying with backoff till the submitter exits. A last push is tried on exit,
+// if it fails too the tx is logged with its body as a dead letter and alerted, so it can be restored by hand.
+func (s *Submitter) pushBack(tx *msg.Tx, target string, push func(context.Context) error) error {
+	ctx := s.ctx()
+	delay := PUSH_RETRY_BASE
+	for {
+		err := push(ctx)
+		if err == nil {
+			return nil
+		}
+		s.txLog(tx).Error("Failed to push tx", "target", target, "err", err, "next_try", delay)
+		select {
+		case <-ctx.Done():
+			flush, cancel := context.WithTimeout(context.Background(), PUSH_FLUSH_TIMEOUT)
+			err = push(flush)
+			cancel()
+			if err == nil {
+				return nil
+			}
+			s.txLog(tx).Error("Dead letter tx failed to push", "target", target, "err", err, "body", tx.Encode())
+			s.alert(ALERT_ERROR, "Lost src tx failed to push back to bus", map[string]interface{}{
+				"chain": s.name, "target": target, "src_chain": tx.SrcChainId, "src_hash": tx.SrcHash, "err": err.Error(),
+			})
+			return err
+		case <-s.timer()(delay):
+		}
+		delay *= 2
+		if delay > PUSH_RETRY_MAX {
+			delay = PUSH_RETRY_MAX
+		}
+	}
+}
+
+// Timer of the submitter waits, time.After unless faked
+func (s *Submitter) timer() func(time.Duration) <-chan time.Time {
+	if s.after != nil {
+		return s.after
+	}
+	return time.After
+}
+
+// Max delay between header submit retries on fork-like errors
+const HEADER_FORK_MAX_BACKOFF = 30 * time.Second
+
+// Delay doubling from one second with the fork retries
+func forkBackoff(retries int) time.Duration {
+	delay := time.Second
+	for i := 1; i < retries && delay < HEADER_FORK_MAX_BACKOFF; i++ {
+		delay *= 2
+	}
+	if delay > HEADER_FORK_MAX_BACKOFF {
+		delay = HEADER_FORK_MAX_BACKOFF
+	}
+	return delay
+}
+
+// Retry interval doubling with the tx attempts up to the max retry interval
+func (s *Submitter) requeueBackoff(attempts int) time.Duration {
+	b := s.newRetryBackoff()
+	delay := b.base
+	for i := 1; i < attempts && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	return delay
+}
+
+// Use a shared seen set to drop duplicated txs, replacing the default in memory one
+func (s *Submitter) SetSeenSet(seen bus.SeenSet) {
+	s.seen = seen
+}
+
+// Check if the tx was processed recently, as listener rescans can push the same tx again. The key is taken
+// before the submit fills in the poly hash, pass it to markProcessed so rescanned txs match it.
+func (s *Submitter) duplicated(tx *msg.Tx) (key string, seen bool) {
+	key = tx.IdempotencyKey()
+	return key, s.seenKey(tx, key)
+}
+
+func (s *Submitter) markProcessed(tx *msg.Tx, key string) {
+	s.markKey(tx, key)
+}
+
+func (s *Submitter) seenKey(tx *msg.Tx, key string) bool {
+	if s.seen == nil {
+		return false
+	}
+	seen, err := s.seen.Seen(s.ctx(), key)
+	if err != nil {
+		log.Warn("Failed to check duplicated src tx", "src_hash", tx.SrcHash, "err", err)
+		return false
+	}
+	if seen {
+		log.Info("Skipping duplicated src tx", "src_hash", tx.SrcHash, "src_chain", tx.SrcChainId, "key", key)
+	}
+	return seen
+}
+
+func (s *Submitter) markKey(tx *msg.Tx, key string) {
+	if s.seen == nil {
+		return
+	}
+	err := s.seen.Mark(s.ctx(), key)
+	if err != nil {
+		log.Warn("Failed to mark processed src tx", "src_hash", tx.SrcHash, "err", err)
+	}
+}
+
+// Submitter context, available before Start for direct calls
+func (s *Submitter) ctx() context.Context {
+	if s.Context != nil {
+		return s.Context
+	}
+	return context.Background()
+}
+
+// Leveled logger carrying context fields
+type txLogger interface {
+	Debug(msg string, ctx ...interface{})
+	Info(msg string, ctx ...interface{})
+	Warn(msg string, ctx ...interface{})
+	Error(msg string, ctx ...interface{})
+}
+
+// Logger with the chain and tx fields, so a tx can be traced across retries
+func (s *Submitter) txLog(tx *msg.Tx) txLogger {
+	return log.New("chain", s.name, "src_chain", tx.SrcChainId, "src_hash", tx.SrcHash, "poly_hash", tx.PolyHash, "attempts", tx.Attempts)
+}
+
+func headersLog(chainId uint64, headers [][]byte) txLogger {
+	return log.New("chain", chainId, "size", len(headers))
+}
+
+// Handle the submit failure of the tx, returns whether the tx should be retried
+func (s *Submitter) submitFailed(tx *msg.Tx, err error) bool {
+	tx.Attempts++
+	s.txLog(tx).Error("Submit src tx to poly error", "err", err, "proof_height", tx.SrcProofHeight)
+	if errors.Is(err, msg.ERR_Tx_VERIFYMERKLEPROOF) {
+		s.txLog(tx).Warn("src tx submit to poly verifyMerkleProof failed, clear src proof", "err", err)
+		tx.SrcProofHex = ""
+		tx.SrcProof = []byte{}
+	}
+	if strings.Contains(err.Error(), "side chain") && strings.Contains(err.Error(), "not registered") {
+		s.alert(ALERT_ERROR, "Dropping src tx failed to submit to poly", map[string]interface{}{
+			"chain": s.name, "src_chain": tx.SrcChainId, "src_hash": tx.SrcHash, "attempts": tx.Attempts, "err": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func (s *Submitter) SetRetryBus(retry bus.TxBus) {
+	s.retry = retry
+}
+
+// Drain the retry bus apart from the main queue, backing off while txs keep failing
+func (s *Submitter) retryLoop(retry bus.TxBus) error {
+	s.wg.Add(1)
+	defer s.wg.Done()
+	backoff := s.newRetryBackoff()
+	for {
+		select {
+		case <-s.Done():
+			log.Info("Retry worker is exiting now", "chain", s.name)
+			return nil
+		default:
+		}
+		if !s.pause.Wait(s.Context) {
+			continue
+		}
+
+		tx, err := retry.PopTimed(s.Context, BUS_POP_TIMEOUT)
+		if err != nil {
+			log.Error("Retry bus pop error", "err", err)
+			s.wait(backoff.base)
+			continue
+		}
+		if tx == nil {
+			select {
+			case <-s.Done():
+			case <-s.timer()(backoff.base):
+			}
+			continue
+		}
+		if !s.pause.Wait(s.Context) {
+			s.pushBack(tx, "retry bus", func(ctx context.Context) error { return retry.Push(ctx, tx) })
+			continue
+		}
+		key, seen := s.duplicated(tx)
+		if seen {
+			continue
+		}
+		s.txLog(tx).Info("Retrying src tx")
+		err = s.submitTracked(tx)
+		if err == nil {
+			s.txLog(tx).Info("Submitted src tx to poly")
+			s.markProcessed(tx, key)
+			backoff.Reset()
+			continue
+		}
+		if s.submitFailed(tx, err) {
+			s.pushBack(tx, "retry bus", func(ctx context.Context) error { return retry.Push(ctx, tx) })
+		}
+		delay := backoff.Next()
+		if hint, _ := retryAfter(err); hint > delay {
+			delay = hint
 		}
+		s.wait(delay)
 	}
 }
 
+func (s *Submitter) newRetryBackoff() *idleBackoff {
+	b := &idleBackoff{base: time.Second}
+	if s.config != nil && s.config.RetryInterval > 0 {
+		b.base = time.Duration(s.config.RetryInterval) * time.Millisecond
+	}
+	b.max = time.Minute
+	if s.config != nil && s.config.MaxRetryInterval > 0 {
+		b.max = time.Duration(s.config.MaxRetryInterval) * time.Millisecond
+	}
+	if b.max < b.base {
+		b.max = b.base
+	}
+	return b
+}
+
+// Idle poll interval backing off while the tx bus stays empty
+type idleBackoff struct {
+	base    time.Duration
+	max     time.Duration
+	current time.Duration
+	after   func(time.Duration) <-chan time.Time
+}
+
+func (s *Submitter) newIdleBackoff() *idleBackoff {
+	b := &idleBackoff{base: time.Second, after: s.after}
+	if s.config != nil && s.config.IdleInterval > 0 {
+		b.base = time.Duration(s.config.IdleInterval) * time.Millisecond
+	}
+	b.max = b.base
+	if s.config != nil && s.config.MaxIdleInterval > 0 {
+		b.max = time.Duration(s.config.MaxIdleInterval) * time.Millisecond
+	}
+	if b.max < b.base {
+		b.max = b.base
+	}
+	return b
+}
+
+// Next returns the interval to wait and doubles the following one up to max
+func (b *idleBackoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.base
+	}
+	next := b.current
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+	return next
+}
+
+// Wait for the next interval, returns false if ctx is done first
+func (b *idleBackoff) Wait(ctx context.Context) bool {
+	after := b.after
+	if after == nil {
+		after = time.After
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-after(b.Next()):
+		return true
+	}
+}
+
+func (b *idleBackoff) Reset() {
+	b.current = 0
+}
+
 func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, mq bus.SortedTxBus, composer msg.SrcComposer) error {
 	s.composer = composer
-	s.Context = ctx
+	s.Context, s.cancel = context.WithCancel(ctx)
 	s.wg = wg
+	s.mq = mq
 
 	if s.config.Procs == 0 {
 		s.config.Procs = 1
 	}
+	if s.seen == nil && s.config.DedupTTL > 0 {
+		s.seen = bus.NewMemorySeenSet(time.Duration(s.config.DedupTTL) * time.Second)
+	}
+	if s.config.MaxProcs > s.config.Procs {
+		go s.autoscale(mq)
+	} else {
+		for i := 0; i < s.config.Procs; i++ {
+			log.Info("Starting poly submitter worker", "index", i, "procs", s.config.Procs, "chain", s.name, "topic", mq.Topic())
+			go s.consume(mq)
+		}
+	}
+	s.startRetryWorkers()
+	go s.monitorBalance()
+	return nil
+}
+
+// Start workers consuming the plain tx bus, with retry workers draining the retry bus when set
+func (s *Submitter) StartTxBus(ctx context.Context, wg *sync.WaitGroup, mq bus.TxBus, composer msg.SrcComposer) error {
+	s.composer = composer
+	s.Context, s.cancel = context.WithCancel(ctx)
+	s.wg = wg
+
+	if s.config.Procs == 0 {
+		s.config.Procs = 1
+	}
+	if s.seen == nil && s.config.DedupTTL > 0 {
+		s.seen = bus.NewMemorySeenSet(time.Duration(s.config.DedupTTL) * time.Second)
+	}
 	for i := 0; i < s.config.Procs; i++ {
 		log.Info("Starting poly submitter worker", "index", i, "procs", s.config.Procs, "chain", s.name, "topic", mq.Topic())
-		go s.consume(mq)
+		go s.run(mq)
 	}
+	s.startRetryWorkers()
+	go s.monitorBalance()
 	return nil
 }
 
+// Start the workers draining the retry bus when set
+func (s *Submitter) startRetryWorkers() {
+	if s.retry == nil {
+		return
+	}
+	procs := s.config.RetryProcs
+	if procs <= 0 {
+		procs = 1
+	}
+	for i := 0; i < procs; i++ {
+		log.Info("Starting poly submitter retry worker", "index", i, "procs", procs, "chain", s.name, "topic", s.retry.Topic())
+		go s.retryLoop(s.retry)
+	}
+}
+
 func (s *Submitter) StartSync(
 	ctx context.Context, wg *sync.WaitGroup, config *config.HeaderSyncConfig,
 	reset chan<- uint64, state bus.ChainStore,
 ) (ch chan msg.Header, err error) {
-	s.Context = ctx
+	s.Context, s.cancel = context.WithCancel(ctx)
 	s.wg = wg
 	s.sync = config
 	s.state = state
@@ -509,7 +1558,7 @@ func (s *Submitter) CheckHeaderExistence(header *msg.Header) (ok bool, err error
 			return true, nil
 		}
 	}
-	hash, err = s.sdk.Node().GetSideChainHeader(s.sync.ChainId, header.Height)
+	hash, err = s.GetSideChainHeader(s.sync.ChainId, header.Height)
 	if err != nil {
 		return
 	}
@@ -517,6 +1566,39 @@ func (s *Submitter) CheckHeaderExistence(header *msg.Header) (ok bool, err error
 	return
 }
 
+// Get side chain header hash synced on poly, cross checked across all the nodes if VerifyNodes is enabled
+func (s *Submitter) GetSideChainHeader(chainId, height uint64) (hash []byte, err error) {
+	if s.sync == nil || !s.sync.VerifyNodes {
+		return s.sdk.Node().GetSideChainHeader(chainId, height)
+	}
+	nodes := s.sdk.AllNodes()
+	hashes := make([][]byte, len(nodes))
+	for i, node := range nodes {
+		hashes[i], err = node.GetSideChainHeader(chainId, height)
+		if err != nil {
+			return nil, fmt.Errorf("Get side chain header from node %s error %v", node.Address(), err)
+		}
+	}
+	hash, err = agreedHash(hashes)
+	if err != nil {
+		log.Error("Poly nodes disagree on side chain header", "chain", chainId, "height", height, "err", err)
+	}
+	return
+}
+
+// Returns the hash only when all the nodes give the same value
+func agreedHash(hashes [][]byte) ([]byte, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	for i, hash := range hashes[1:] {
+		if !bytes.Equal(hash, hashes[0]) {
+			return nil, fmt.Errorf("%w, node 0 gives %x, node %d gives %x", msg.ERR_NODES_INCONSISTENT, hashes[0], i+1, hash)
+		}
+	}
+	return hashes[0], nil
+}
+
 func (s *Submitter) syncHeaderLoop(ch <-chan msg.Header, reset chan<- uint64) {
 	for {
 		select {
@@ -526,6 +1608,9 @@ func (s *Submitter) syncHeaderLoop(ch <-chan msg.Header, reset chan<- uint64) {
 			if !ok {
 				return
 			}
+			if s.beyondStop(&header) || !s.decompressHeader(&header, reset) || s.duplicatedHeader(&header) {
+				continue
+			}
 			// NOTE err reponse here will revert header sync with delta - 2
 			headers := [][]byte{header.Data}
 			if header.Data == nil {
@@ -533,19 +1618,25 @@ func (s *Submitter) syncHeaderLoop(ch <-chan msg.Header, reset chan<- uint64) {
 			}
 			err := s.SubmitHeadersWithLoop(s.sync.ChainId, headers, &header)
 			if err != nil {
-				reset <- header.Height - 2
+				s.notifyReset(reset, header.Height-2)
+			} else {
+				s.reportProgress(header.Height, len(headers))
+				if s.stopReached() {
+					return
+				}
 			}
 		}
 	}
 }
 
 func (s *Submitter) syncHeaderBatchLoop(ch <-chan msg.Header, reset chan<- uint64) {
-	headers := [][]byte{}
+	headers := []msg.Header{}
 	commit := false
 	duration := time.Duration(s.sync.Timeout) * time.Second
 	var (
 		height uint64
 		hdr    *msg.Header
+		size   int // Total bytes of the headers in batch
 	)
 
 COMMIT:
@@ -554,20 +1645,32 @@ COMMIT:
 		case <-s.Done():
 			break COMMIT
 		case header, ok := <-ch:
+			if ok && (s.beyondStop(&header) || !s.decompressHeader(&header, reset) || s.duplicatedHeader(&header)) {
+				continue
+			}
 			if ok {
 				hdr = &header
 				if len(headers) > 0 && height != header.Height-1 {
 					log.Info("Resetting header set", "chain", s.sync.ChainId, "height", height, "current_height", header.Height)
-					headers = [][]byte{}
+					headers, size = []msg.Header{}, 0
+				}
+				if len(headers) > 0 && hdr.Data != nil && s.sync.MaxBatchBytes > 0 && size+len(hdr.Data) > s.sync.MaxBatchBytes {
+					// Flush before the batch grows beyond the size limit
+					s.commitHeaders(headers, &headers[len(headers)-1], height, reset)
+					headers, size = []msg.Header{}, 0
 				}
 				height = header.Height
 				if hdr.Data == nil {
 					// Update header sync height
 					commit = true
 				} else {
-					headers = append(headers, header.Data)
-					commit = len(headers) >= s.sync.Batch
+					headers = append(headers, header)
+					size += len(header.Data)
+					// Epoch headers end the batch, so headers signed by the new keepers go after it
+					commit = len(headers) >= s.sync.Batch || isEpochHeader(s.sync.ChainId, hdr)
 				}
+				// The header at the stop height ends the batch
+				commit = commit || s.sync.StopHeight > 0 && height >= s.sync.StopHeight
 			} else {
 				commit = len(headers) > 0
 				break COMMIT
@@ -577,25 +1680,194 @@ COMMIT:
 		}
 		if commit {
 			commit = false
-			// NOTE err reponse here will revert header sync with delta -100
-			err := s.SubmitHeadersWithLoop(s.sync.ChainId, headers, hdr)
-			if err != nil {
-				reset <- height - uint64(len(headers)) - 2
+			s.commitHeaders(headers, hdr, height, reset)
+			headers, size = []msg.Header{}, 0
+			if s.stopReached() {
+				break COMMIT
 			}
-			headers = [][]byte{}
 		}
 	}
 	if len(headers) > 0 {
-		s.SubmitHeadersWithLoop(s.sync.ChainId, headers, hdr)
+		headers = trimSyncedHeaders(headers, s.CheckHeaderExistence)
+		if s.SubmitHeadersWithLoop(s.sync.ChainId, headerData(headers), hdr) == nil {
+			s.reportProgress(height, len(headers))
+		}
+	}
+}
+
+func (s *Submitter) commitHeaders(headers []msg.Header, hdr *msg.Header, height uint64, reset chan<- uint64) {
+	headers = trimSyncedHeaders(headers, s.CheckHeaderExistence)
+	// NOTE err reponse here will revert header sync with delta -100
+	err := s.SubmitHeadersWithLoop(s.sync.ChainId, headerData(headers), hdr)
+	s.onCommit(headers, height, err, s.CheckHeaderExistence, reset)
+}
+
+// Handle the header batch commit result, a failed batch still advances the sync over the prefix accepted by poly
+func (s *Submitter) onCommit(headers []msg.Header, height uint64, err error, exists func(*msg.Header) (bool, error), reset chan<- uint64) {
+	if err == nil {
+		s.reportProgress(height, len(headers))
+		return
+	}
+	accepted := 0
+	if !errors.Is(err, msg.ERR_HEADER_INCONSISTENT) {
+		accepted = acceptedHeaders(headers, exists)
+	}
+	if accepted == 0 {
+		s.notifyReset(reset, height-uint64(len(headers))-2)
+		return
+	}
+	last := headers[accepted-1].Height
+	log.Warn("Header batch partially accepted by poly", "chain", s.sync.ChainId, "accepted", accepted, "size", len(headers), "height", last, "err", err)
+	s.state.HeightMark(last)
+	s.lastCommit = last
+	s.reportProgress(last, accepted)
+	if accepted < len(headers) {
+		// Resume from the first header rejected
+		s.notifyReset(reset, headers[accepted].Height)
+	}
+}
+
+// Count of the leading headers already synced to poly
+func acceptedHeaders(headers []msg.Header, exists func(*msg.Header) (bool, error)) int {
+	return len(headers) - len(trimSyncedHeaders(headers, exists))
+}
+
+// Resets of the header sync to a header failing to decompress before the header is skipped
+const HEADER_DECOMPRESS_RETRIES = 3
+
+// Decompress the header by its codec flag, the header sync resets to the header on failure. A header
+// failing HEADER_DECOMPRESS_RETRIES times in a row is skipped with an alert, so a corrupt one can not loop forever.
+func (s *Submitter) decompressHeader(header *msg.Header, reset chan<- uint64) bool {
+	err := header.Decompress()
+	if err == nil {
+		return true
+	}
+	if s.decompressHeight != header.Height {
+		s.decompressHeight, s.decompressFailures = header.Height, 0
+	}
+	s.decompressFailures++
+	if s.decompressFailures <= HEADER_DECOMPRESS_RETRIES {
+		log.Error("Failed to decompress header", "chain", s.sync.ChainId, "height", header.Height, "failures", s.decompressFailures, "err", err)
+		s.notifyReset(reset, header.Height)
+		return false
+	}
+	log.Error("Skipping header failed to decompress", "chain", s.sync.ChainId, "height", header.Height, "failures", s.decompressFailures, "err", err)
+	s.alert(ALERT_ERROR, "Header sync skipping header failed to decompress", map[string]interface{}{
+		"chain": s.sync.ChainId, "height": header.Height, "codec": header.Codec, "err": err.Error(),
+	})
+	return false
+}
+
+// Skip the header received already from an overlapping header producer
+func (s *Submitter) duplicatedHeader(header *msg.Header) bool {
+	if !s.recent.duplicated(header) {
+		return false
+	}
+	log.Debug("Skipping duplicated header", "chain", s.sync.ChainId, "height", header.Height)
+	return true
+}
+
+// Drop the header above the stop height, which is never submitted
+func (s *Submitter) beyondStop(header *msg.Header) bool {
+	return s.sync.StopHeight > 0 && header.Height > s.sync.StopHeight
+}
+
+// Whether the headers till the stop height are submitted
+func (s *Submitter) stopReached() bool {
+	return s.sync.StopHeight > 0 && s.lastCommit >= s.sync.StopHeight
+}
+
+// Handle the header sync completion when the headers till StopHeight are submitted, the sync exits then
+func (s *Submitter) OnSyncComplete(handler func(height uint64)) {
+	s.onComplete = handler
+}
+
+// Handle header sync progress with the last synced height and the count of headers submitted
+func (s *Submitter) OnProgress(handler func(height uint64, count int)) {
+	s.onProgress = handler
+}
+
+func (s *Submitter) reportProgress(height uint64, count int) {
+	// Header submits are abandoned without error on exit
+	if s.onProgress == nil || s.ctx().Err() != nil {
+		return
+	}
+	s.onProgress(height, count)
+}
+
+// Whether the side chain header switches the consensus epoch, only ONT headers carry the next bookkeeper
+func isEpochHeader(chainId uint64, header *msg.Header) bool {
+	switch chainId {
+	case base.ONT:
+		hdr, err := otypes.HeaderFromRawBytes(header.Data)
+		return err == nil && hdr.NextBookkeeper != ocom.ADDRESS_EMPTY
+	}
+	return false
+}
+
+// Drop the leading headers which are already synced to poly, so only the missing suffix is submitted
+func trimSyncedHeaders(headers []msg.Header, exists func(*msg.Header) (bool, error)) []msg.Header {
+	for len(headers) > 0 {
+		ok, err := exists(&headers[0])
+		if err != nil {
+			log.Error("Failed to check header existence", "height", headers[0].Height, "err", err)
+			break
+		}
+		if !ok {
+			break
+		}
+		log.Debug("Skipping header already synced", "height", headers[0].Height)
+		headers = headers[1:]
+	}
+	return headers
+}
+
+func headerData(headers []msg.Header) [][]byte {
+	data := make([][]byte, len(headers))
+	for i, header := range headers {
+		data[i] = header.Data
+	}
+	return data
+}
+
+// Wait time for the reset consumer before dropping a header sync reset
+var RESET_TIMEOUT = 10 * time.Second
+
+// Notify header sync handler to reset the sync height, the reset is dropped when the consumer is absent or gone
+func (s *Submitter) notifyReset(reset chan<- uint64, height uint64) {
+	// Accept the headers sent again from the reset height
+	s.recent.forget(height)
+	if reset == nil {
+		log.Warn("Dropping header sync reset for no reset consumer", "chain", s.sync.ChainId, "height", height)
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Warn("Dropping header sync reset for reset channel closed", "chain", s.sync.ChainId, "height", height, "err", r)
+		}
+	}()
+	select {
+	case reset <- height:
+	case <-s.Done():
+		log.Warn("Dropping header sync reset for exiting", "chain", s.sync.ChainId, "height", height)
+	case <-time.After(RESET_TIMEOUT):
+		log.Warn("Dropping header sync reset for consumer not responding", "chain", s.sync.ChainId, "height", height)
 	}
 }
 
 func (s *Submitter) startSync(ch <-chan msg.Header, reset chan<- uint64) {
+	s.recent = newHeaderWindow(s.sync.DedupWindow)
 	if s.sync.Batch == 1 {
 		s.syncHeaderLoop(ch, reset)
 	} else {
 		s.syncHeaderBatchLoop(ch, reset)
 	}
+	if s.stopReached() {
+		log.Info("Header sync reached stop height", "chain", s.sync.ChainId, "height", s.lastCommit, "stop", s.sync.StopHeight)
+		if s.onComplete != nil {
+			s.onComplete(s.lastCommit)
+		}
+	}
 	log.Info("Header sync exiting loop now")
 }
 
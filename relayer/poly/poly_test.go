@@ -0,0 +1,962 @@
+package poly
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ethlog "github.com/ethereum/go-ethereum/log"
+	ocom "github.com/ontio/ontology/common"
+	otypes "github.com/ontio/ontology/core/types"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestIdleBackoff(t *testing.T) {
+	s := &Submitter{config: &config.PolySubmitterConfig{IdleInterval: 100, MaxIdleInterval: 500}}
+	b := s.newIdleBackoff()
+	expected := []time.Duration{100, 200, 400, 500, 500}
+	for i, v := range expected {
+		if d := b.Next(); d != v*time.Millisecond {
+			t.Fatalf("Unexpected idle interval at %d: %v", i, d)
+		}
+	}
+	b.Reset()
+	if d := b.Next(); d != 100*time.Millisecond {
+		t.Fatalf("Idle interval not reset on activity: %v", d)
+	}
+
+	// Defaults keep the flat one second poll
+	b = (&Submitter{config: &config.PolySubmitterConfig{}}).newIdleBackoff()
+	if b.Next() != time.Second || b.Next() != time.Second {
+		t.Fatalf("Unexpected default idle interval")
+	}
+}
+
+func TestIdleLatency(t *testing.T) {
+	useTestConfig(t)
+	mq := new(memTxBus)
+	tx := &msg.Tx{SrcHash: "a", SrcChainId: base.ONT}
+	var (
+		mu                   sync.Mutex
+		now, arrived, polled time.Duration
+	)
+	// Fake clock advancing on each idle wait, the tx arrives after a long idle period
+	arrival := 10 * time.Second
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{DryRun: true, IdleInterval: 10, MaxIdleInterval: 80},
+		signer: new(sdk.Account),
+		seen:   bus.NewMemorySeenSet(time.Minute),
+		after: func(d time.Duration) <-chan time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			now += d
+			if arrived == 0 && now >= arrival {
+				arrived, polled = arrival, now
+				mq.Push(context.Background(), tx)
+			}
+			ch := make(chan time.Time, 1)
+			ch <- time.Time{}
+			return ch
+		},
+	}
+	s.composer = &testComposer{}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	go s.run(mq)
+	waitFor(t, func() bool { return processed(s, &msg.Tx{SrcHash: "a", SrcChainId: base.ONT}) })
+	s.cancel()
+	s.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if latency := polled - arrived; latency > 80*time.Millisecond {
+		t.Fatalf("First tx after idle waited %v, beyond the max idle interval", latency)
+	}
+
+	// Idle wait ends on cancellation
+	s.after = func(time.Duration) <-chan time.Time { return nil }
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.run(new(memTxBus))
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Idle worker did not exit on cancellation")
+	}
+}
+
+func TestTrimSyncedHeaders(t *testing.T) {
+	headers := []msg.Header{}
+	for h := uint64(100); h < 110; h++ {
+		headers = append(headers, msg.Header{Height: h, Data: []byte{byte(h)}})
+	}
+	synced := uint64(103)
+	exists := func(header *msg.Header) (bool, error) { return header.Height <= synced, nil }
+
+	trimmed := trimSyncedHeaders(headers, exists)
+	if len(trimmed) != 6 || trimmed[0].Height != 104 {
+		t.Fatalf("Unexpected trimmed headers, size %v", len(trimmed))
+	}
+	data := headerData(trimmed)
+	if len(data) != 6 || data[0][0] != 104 {
+		t.Fatalf("Unexpected header data %v", data)
+	}
+
+	synced = 200
+	if len(trimSyncedHeaders(headers, exists)) != 0 {
+		t.Fatalf("Expect all headers to be skipped")
+	}
+
+	// Keep the set intact if existence check fails
+	failure := func(*msg.Header) (bool, error) { return false, fmt.Errorf("node down") }
+	if len(trimSyncedHeaders(headers, failure)) != len(headers) {
+		t.Fatalf("Expect headers untouched on check failure")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s := &Submitter{config: &config.PolySubmitterConfig{StopTimeout: 1}}
+	s.Hook(context.Background(), &sync.WaitGroup{}, nil)
+
+	// Hold a worker which only exits on context cancel
+	s.wg.Add(1)
+	atomic.AddInt64(&s.inflight, 1)
+	go func() {
+		<-s.Done()
+		s.wg.Done()
+	}()
+
+	start := time.Now()
+	err := s.Stop()
+	if !errors.Is(err, msg.ERR_STOP_TIMEOUT) {
+		t.Fatalf("Expect stop timeout error, got %v", err)
+	}
+	if elapse := time.Since(start); elapse < time.Second || elapse > 3*time.Second {
+		t.Fatalf("Unexpected stop elapse %v", elapse)
+	}
+	if s.Err() == nil {
+		t.Fatalf("Expect submitter context cancelled")
+	}
+	if err = s.Stop(); err != nil {
+		t.Fatalf("Expect clean stop after cancel, got %v", err)
+	}
+}
+
+func TestAgreedHash(t *testing.T) {
+	a, b := []byte{1, 2, 3}, []byte{1, 2, 4}
+	hash, err := agreedHash([][]byte{a, a, a})
+	if err != nil || string(hash) != string(a) {
+		t.Fatalf("Expect agreed hash, got %x err %v", hash, err)
+	}
+	_, err = agreedHash([][]byte{a, b, a})
+	if !errors.Is(err, msg.ERR_NODES_INCONSISTENT) {
+		t.Fatalf("Expect nodes inconsistent error, got %v", err)
+	}
+	// A lagging node without the header disagrees too
+	_, err = agreedHash([][]byte{a, nil})
+	if !errors.Is(err, msg.ERR_NODES_INCONSISTENT) {
+		t.Fatalf("Expect nodes inconsistent error, got %v", err)
+	}
+}
+
+func TestHeaderExistenceFailures(t *testing.T) {
+	node := func(storage func() (interface{}, error)) string {
+		return testPolyServer(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 1000, nil
+			case "getheaderbyheight":
+				return hex.EncodeToString((&types.Header{}).ToArray()), nil
+			case "getstorage":
+				return storage()
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		})
+	}
+	agreed := node(func() (interface{}, error) { return "aa", nil })
+	other := node(func() (interface{}, error) { return "bb", nil })
+	down := node(func() (interface{}, error) { return nil, fmt.Errorf("node down") })
+	submitter := func(nodes ...string) (*Submitter, *int) {
+		sdk, err := poly.NewSDK(base.POLY, nodes, time.Hour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waits := 0
+		s := &Submitter{
+			Context: context.Background(),
+			sdk:     sdk,
+			config:  &config.PolySubmitterConfig{DryRun: true},
+			sync:    &config.HeaderSyncConfig{VerifyNodes: true, ListenerConfig: &config.ListenerConfig{ChainId: base.ETH}},
+			after: func(time.Duration) <-chan time.Time {
+				waits++
+				ch := make(chan time.Time, 1)
+				ch <- time.Now()
+				return ch
+			},
+		}
+		return s, &waits
+	}
+	header := &msg.Header{Height: 10, Hash: []byte{0xcc}}
+
+	// Disagreeing nodes do not hold the header from being submitted
+	s, waits := submitter(agreed, other)
+	if err := s.submitHeadersWithLoop(base.ETH, [][]byte{{1}}, header); err != nil || *waits != 0 {
+		t.Fatalf("Expect header submitted on node disagreement, got %v after %d waits", err, *waits)
+	}
+
+	// An erroring node uses up the attempts instead of checking forever
+	s, waits = submitter(agreed, down)
+	if err := s.submitHeadersWithLoop(base.ETH, [][]byte{{1}}, header); !errors.Is(err, msg.ERR_HEADER_SUBMIT_FAILURE) || *waits != 30 {
+		t.Fatalf("Expect header submit failure on check errors, got %v after %d waits", err, *waits)
+	}
+}
+
+func TestHeaderAccount(t *testing.T) {
+	signer := &sdk.Account{Address: pcom.Address{1}}
+	s := &Submitter{signer: signer}
+	if s.headerAccount() != signer {
+		t.Fatalf("Expect header sync to fall back to the import signer")
+	}
+	header := &sdk.Account{Address: pcom.Address{2}}
+	s.headerSigner = header
+	if s.headerAccount() != header || s.signer != signer {
+		t.Fatalf("Expect header sync and tx import to use separate accounts")
+	}
+}
+
+// Whether the tx was marked processed by the submitter
+func processed(s *Submitter, tx *msg.Tx) bool {
+	_, seen := s.duplicated(tx)
+	return seen
+}
+
+func TestDuplicatedTx(t *testing.T) {
+	s := &Submitter{Context: context.Background()}
+	tx := &msg.Tx{SrcChainId: 2, TxId: "AB01", SrcHash: "0x01"}
+	key, _ := s.duplicated(tx)
+	s.markProcessed(tx, key)
+	if processed(s, tx) {
+		t.Fatalf("Dedup should be disabled without seen set")
+	}
+
+	s.SetSeenSet(bus.NewMemorySeenSet(time.Minute))
+	key, seen := s.duplicated(tx)
+	if seen {
+		t.Fatalf("Unexpected duplicated tx before processing")
+	}
+	// Submit fills in the poly hash between the check and the mark
+	tx.PolyHash = "0xpoly"
+	s.markProcessed(tx, key)
+	again := &msg.Tx{SrcChainId: 2, TxId: "ab01", SrcHash: "0x01"}
+	if !processed(s, again) {
+		t.Fatalf("Expect the same tx pushed again to be skipped")
+	}
+	if processed(s, &msg.Tx{SrcChainId: 3, TxId: "ab01"}) {
+		t.Fatalf("Unexpected duplicated tx from another chain")
+	}
+}
+
+func TestRunDuplicatedTx(t *testing.T) {
+	useTestConfig(t)
+	mq := new(memTxBus)
+	composer := new(countComposer)
+	s := &Submitter{
+		name:     "dedup",
+		config:   &config.PolySubmitterConfig{DryRun: true, IdleInterval: 10},
+		signer:   new(sdk.Account),
+		seen:     bus.NewMemorySeenSet(time.Minute),
+		composer: composer,
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	go s.run(mq)
+	defer s.cancel()
+
+	// The submit fills in the poly hash, a rescan pushes the tx again without it
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "a", TxId: "a", SrcChainId: base.ONT})
+	waitFor(t, func() bool { return processed(s, &msg.Tx{SrcHash: "a", TxId: "a", SrcChainId: base.ONT}) })
+	mq.Push(context.Background(), &msg.Tx{SrcHash: "a", TxId: "a", SrcChainId: base.ONT})
+	waitFor(t, func() bool { n, _ := mq.Len(context.Background()); return n == 0 })
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&composer.composed); n != 1 {
+		t.Fatalf("Expect the rescanned tx submitted once, composed %d times", n)
+	}
+}
+
+func TestNotifyReset(t *testing.T) {
+	s := &Submitter{Context: context.Background(), sync: &config.HeaderSyncConfig{ListenerConfig: &config.ListenerConfig{ChainId: 2}}}
+	s.notifyReset(nil, 100)
+
+	closed := make(chan uint64, 1)
+	close(closed)
+	s.notifyReset(closed, 100)
+
+	reset := make(chan uint64, 1)
+	s.notifyReset(reset, 100)
+	if v := <-reset; v != 100 {
+		t.Fatalf("Unexpected reset height %v", v)
+	}
+
+	timeout := RESET_TIMEOUT
+	RESET_TIMEOUT = 10 * time.Millisecond
+	defer func() { RESET_TIMEOUT = timeout }()
+	s.notifyReset(make(chan uint64), 100)
+}
+
+func TestDecompressHeaderRetries(t *testing.T) {
+	alerter := new(testAlerter)
+	s := &Submitter{Context: context.Background(), alerter: alerter, sync: &config.HeaderSyncConfig{ListenerConfig: &config.ListenerConfig{ChainId: 2}}}
+	reset := make(chan uint64, HEADER_DECOMPRESS_RETRIES+1)
+	corrupt := func(height uint64) *msg.Header {
+		return &msg.Header{Height: height, Data: []byte("corrupt"), Codec: msg.HEADER_CODEC_GZIP}
+	}
+
+	// The sync resets to a corrupt header a bounded times, then skips it with an alert
+	for i := 0; i <= HEADER_DECOMPRESS_RETRIES; i++ {
+		if s.decompressHeader(corrupt(100), reset) {
+			t.Fatal("Expect corrupt header rejected")
+		}
+	}
+	if len(reset) != HEADER_DECOMPRESS_RETRIES || len(alerter.alerts) != 1 {
+		t.Fatalf("Expect %d resets and an alert, got %d resets alerts %v", HEADER_DECOMPRESS_RETRIES, len(reset), alerter.alerts)
+	}
+
+	// Failures of another header are counted apart
+	if s.decompressHeader(corrupt(101), reset) || len(reset) != HEADER_DECOMPRESS_RETRIES+1 {
+		t.Fatalf("Expect sync reset to the next corrupt header, got %d resets", len(reset))
+	}
+	if !s.decompressHeader(&msg.Header{Height: 102, Data: []byte{1}}, reset) {
+		t.Fatal("Expect raw header accepted")
+	}
+}
+
+func TestSubmitOnNodes(t *testing.T) {
+	primary, secondary, third := new(poly.Client), new(poly.Client), new(poly.Client)
+	nodes := []*poly.Client{secondary, primary, third}
+
+	var tried []*poly.Client
+	hash, err := submitOnNodes(primary, nodes, func(node *poly.Client) (string, error) {
+		tried = append(tried, node)
+		if node == primary {
+			return "", errors.New("primary lagging")
+		}
+		return "hash", nil
+	})
+	if err != nil || hash != "hash" {
+		t.Fatalf("Expect secondary submit success, got %v %v", hash, err)
+	}
+	if len(tried) != 2 || tried[0] != primary || tried[1] != secondary {
+		t.Fatalf("Unexpected node attempt order %v", tried)
+	}
+
+	_, err = submitOnNodes(primary, nodes, func(node *poly.Client) (string, error) {
+		if node == third {
+			return "", fmt.Errorf("%w, parent header not exist", msg.ERR_HEADER_FORK)
+		}
+		return "", errors.New("rejected")
+	})
+	errs, ok := err.(NodeErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Expect combined error of all nodes, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "parent header not exist") {
+		t.Fatalf("Combined error lost node failure detail: %v", err)
+	}
+	if !errors.Is(err, msg.ERR_HEADER_FORK) {
+		t.Fatalf("Combined error lost node failure classification: %v", err)
+	}
+
+	// Sent but unconfirmed headers are not sent again on the other nodes
+	tried = nil
+	_, err = submitOnNodes(primary, nodes, func(node *poly.Client) (string, error) {
+		tried = append(tried, node)
+		return "", fmt.Errorf("%w, poly tx hash in 300s", msg.ERR_TX_UNCONFIRMED)
+	})
+	if !errors.Is(err, msg.ERR_TX_UNCONFIRMED) || len(tried) != 1 {
+		t.Fatalf("Expect no failover on unconfirmed tx, got %v after %d nodes", err, len(tried))
+	}
+}
+
+func TestSDKOptions(t *testing.T) {
+	interval, maxGap := sdkOptions(0, 0)
+	if interval != time.Minute || maxGap != 1 {
+		t.Fatalf("Unexpected default sdk options %v %v", interval, maxGap)
+	}
+	c := new(config.PolySubmitterConfig).Fill(&config.PolySubmitterConfig{NodeCheckInterval: 10, NodeMaxGap: 5})
+	interval, maxGap = sdkOptions(c.NodeCheckInterval, c.NodeMaxGap)
+	if interval != 10*time.Second || maxGap != 5 {
+		t.Fatalf("Unexpected sdk options %v %v", interval, maxGap)
+	}
+	c = (&config.PolySubmitterConfig{NodeCheckInterval: 120, NodeMaxGap: 3}).Fill(nil)
+	interval, maxGap = sdkOptions(c.NodeCheckInterval, c.NodeMaxGap)
+	if interval != 2*time.Minute || maxGap != 3 {
+		t.Fatalf("Sdk options not inherited from parent config %v %v", interval, maxGap)
+	}
+}
+
+type testComposer struct {
+	fail string // Src hash to fail composing
+}
+
+func (c *testComposer) Compose(tx *msg.Tx) error {
+	if tx.SrcHash == c.fail {
+		return errors.New("compose failure")
+	}
+	tx.Param = &ccom.MakeTxParam{Method: "unlock"}
+	tx.SrcStateRoot = []byte{1}
+	tx.SrcProof = []byte{2}
+	return nil
+}
+func (c *testComposer) LatestHeight() (uint64, error) { return 0, nil }
+
+// Load a global config allowing unlock method
+func useTestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := fmt.Sprintf(`{"Env": "%s", "ValidMethods": ["unlock"]}`, base.ENV)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := config.CONFIG
+	t.Cleanup(func() { config.CONFIG = origin })
+	config.CONFIG = c
+}
+
+func TestDryRun(t *testing.T) {
+	useTestConfig(t)
+
+	// Without poly sdk any signing call would panic
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{DryRun: true},
+		signer:   new(sdk.Account),
+		composer: &testComposer{},
+	}
+	tx := &msg.Tx{SrcChainId: base.ONT, SrcHash: "src"}
+	if err := s.submit(tx); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(tx.PolyHash, DRY_RUN_HASH_PREFIX) {
+		t.Fatalf("Expect dry run poly hash, got %s", tx.PolyHash)
+	}
+
+	hash, err := s.SubmitHeaders(2, [][]byte{{1}})
+	if err != nil || !strings.HasPrefix(hash, DRY_RUN_HASH_PREFIX) {
+		t.Fatalf("Expect dry run headers submit, got %s %v", hash, err)
+	}
+
+	// Header sync height is not advanced past headers poly never received
+	store := new(memChainStore)
+	s.state = store
+	s.sync = &config.HeaderSyncConfig{ListenerConfig: &config.ListenerConfig{ChainId: base.HARMONY}}
+	for height := uint64(10); height < 20; height++ {
+		if err = s.SubmitHeadersWithLoop(base.HARMONY, [][]byte{{1}}, &msg.Header{Height: height}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.height != 0 {
+		t.Fatalf("Expect no header sync mark in dry run, got height %d", store.height)
+	}
+}
+
+func TestSubmitTx(t *testing.T) {
+	useTestConfig(t)
+	var sent *types.Transaction
+	signer := sdk.NewAccount()
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{},
+		signer:   signer,
+		composer: &testComposer{},
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 100, nil
+			case "getheaderbyheight":
+				return hex.EncodeToString((&types.Header{}).ToArray()), nil
+			case "getstorage":
+				return "", nil
+			case "sendrawtransaction":
+				raw, _ := hex.DecodeString(params[0].(string))
+				tx, err := types.TransactionFromRawBytes(raw)
+				if err != nil {
+					return nil, err
+				}
+				sent = tx
+				hash := tx.Hash()
+				return hash.ToHexString(), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	tx := &msg.Tx{SrcChainId: base.ETH, SrcHash: "src"}
+	res, err := s.SubmitTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sent.Hash()
+	if res.PolyHash != hash.ToHexString() || res.Nonce != sent.Nonce || res.Signer != signer.Address.ToBase58() || res.Elapsed <= 0 {
+		t.Fatalf("Unexpected submit result %+v", res)
+	}
+	if tx.PolyHash != "" || tx.Param != nil {
+		t.Fatal("Expect submitted tx untouched")
+	}
+
+	// Compatible submit keeps the poly hash on the tx
+	if err = s.submit(tx); err != nil || tx.PolyHash == "" || tx.Param == nil {
+		t.Fatalf("Expect poly hash set on tx, got %s err %v", tx.PolyHash, err)
+	}
+}
+
+func TestTxLog(t *testing.T) {
+	useTestConfig(t)
+	var (
+		mu      sync.Mutex
+		records = map[string]map[string]interface{}{}
+	)
+	root := ethlog.Root()
+	handler := root.GetHandler()
+	root.SetHandler(ethlog.FuncHandler(func(r *ethlog.Record) error {
+		fields := map[string]interface{}{}
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			fields[r.Ctx[i].(string)] = r.Ctx[i+1]
+		}
+		mu.Lock()
+		records[r.Msg] = fields
+		mu.Unlock()
+		return nil
+	}))
+	t.Cleanup(func() { root.SetHandler(handler) })
+
+	s := &Submitter{
+		name:     "test",
+		config:   &config.PolySubmitterConfig{DryRun: true},
+		signer:   new(sdk.Account),
+		composer: &testComposer{},
+	}
+	tx := &msg.Tx{SrcChainId: base.ONT, SrcHash: "src", Attempts: 2}
+	if err := s.submit(tx); err != nil {
+		t.Fatal(err)
+	}
+	s.submitFailed(tx, errors.New("node down"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, m := range []string{"Dry run skipped importing tx to poly", "Submit src tx to poly error"} {
+		fields, ok := records[m]
+		if !ok {
+			t.Fatalf("Missing log record %q", m)
+		}
+		if fields["chain"] != "test" || fields["src_chain"] != uint64(base.ONT) || fields["src_hash"] != "src" {
+			t.Fatalf("Missing tx context fields in %q: %v", m, fields)
+		}
+	}
+	if fields := records["Submit src tx to poly error"]; fields["attempts"] != 3 || fields["poly_hash"] != tx.PolyHash {
+		t.Fatalf("Unexpected attempt fields %v", fields)
+	}
+}
+
+func TestResendOnErrors(t *testing.T) {
+	errs := []string{
+		"JsonRpcResponse error code:42002 desc:nonce too low",
+		"JsonRpcResponse error code:43001 desc:transaction already in pool",
+		"JsonRpcResponse error code:43001 desc:Duplicated Transaction detected",
+	}
+	for _, info := range errs {
+		sends := 0
+		hash, err := resendOnErrors(DEFAULT_RESEND_ERRORS, func() (string, error) {
+			sends++
+			if sends == 1 {
+				return "", errors.New(info)
+			}
+			return "hash", nil
+		})
+		if err != nil || hash != "hash" || sends != 2 {
+			t.Fatalf("Expect tx resent on %q, got %v after %d sends", info, err, sends)
+		}
+	}
+
+	// Unrecognized errors are returned at once, recognized ones give up after max resends
+	for _, c := range []struct {
+		info  string
+		sends int
+	}{{"tx already done", 1}, {"nonce too low", MAX_RESEND + 1}} {
+		sends := 0
+		_, err := resendOnErrors(DEFAULT_RESEND_ERRORS, func() (string, error) {
+			sends++
+			return "", errors.New(c.info)
+		})
+		if err == nil || sends != c.sends {
+			t.Fatalf("Expect %d sends on %q, got %d", c.sends, c.info, sends)
+		}
+	}
+
+	// Configured patterns replace the defaults
+	s := &Submitter{config: &config.PolySubmitterConfig{ResendErrors: []string{"txpool full"}}}
+	if matchError(errors.New("TxPool full"), s.resendErrors()) == "" || matchError(errors.New("nonce too low"), s.resendErrors()) != "" {
+		t.Fatal("Expect configured resend errors only")
+	}
+}
+
+type memChainStore struct{ height uint64 }
+
+func (s *memChainStore) UpdateHeight(_ context.Context, height uint64) error {
+	s.height = height
+	return nil
+}
+func (s *memChainStore) GetHeight(context.Context) (uint64, error) { return s.height, nil }
+func (s *memChainStore) HeightMark(height uint64) error            { s.height = height; return nil }
+
+func TestSyncProgress(t *testing.T) {
+	cases := []struct {
+		batch    int
+		progress [][2]uint64
+	}{
+		{1, [][2]uint64{{10, 1}, {11, 1}, {12, 1}, {13, 1}, {14, 1}}},
+		{2, [][2]uint64{{11, 2}, {13, 2}, {14, 1}}},
+	}
+	for _, c := range cases {
+		// Harmony headers skip the existence check, dry run skips the poly submit
+		s := &Submitter{
+			config: &config.PolySubmitterConfig{DryRun: true},
+			sync:   &config.HeaderSyncConfig{Batch: c.batch, Timeout: 10, ListenerConfig: &config.ListenerConfig{ChainId: base.HARMONY}},
+			state:  new(memChainStore),
+		}
+		s.Context, s.cancel = context.WithCancel(context.Background())
+		progress := [][2]uint64{}
+		s.OnProgress(func(height uint64, count int) { progress = append(progress, [2]uint64{height, uint64(count)}) })
+
+		ch := make(chan msg.Header, 5)
+		for h := uint64(10); h < 15; h++ {
+			ch <- msg.Header{Height: h, Data: []byte{byte(h)}}
+		}
+		close(ch)
+		s.startSync(ch, nil)
+		s.cancel()
+		if fmt.Sprint(progress) != fmt.Sprint(c.progress) {
+			t.Fatalf("Batch %d expect progress %v, got %v", c.batch, c.progress, progress)
+		}
+	}
+}
+
+func TestSyncEpochBatch(t *testing.T) {
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{DryRun: true},
+		sync:   &config.HeaderSyncConfig{Batch: 4, Timeout: 10, ListenerConfig: &config.ListenerConfig{ChainId: base.ONT}},
+		state:  new(memChainStore),
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 1000, nil
+			case "getheaderbyheight":
+				return hex.EncodeToString((&types.Header{}).ToArray()), nil
+			case "getstorage":
+				return "", nil // Header not synced yet
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	progress := [][2]uint64{}
+	s.OnProgress(func(height uint64, count int) { progress = append(progress, [2]uint64{height, uint64(count)}) })
+
+	// Epoch header at 11 mid-batch
+	ch := make(chan msg.Header, 6)
+	for h := uint32(10); h < 16; h++ {
+		hdr := &otypes.Header{Height: h}
+		if h == 11 {
+			hdr.NextBookkeeper = ocom.Address{1}
+		}
+		ch <- msg.Header{Height: uint64(h), Data: hdr.ToArray()}
+	}
+	close(ch)
+	s.startSync(ch, nil)
+	expected := [][2]uint64{{11, 2}, {15, 4}}
+	if fmt.Sprint(progress) != fmt.Sprint(expected) {
+		t.Fatalf("Expect batches %v, got %v", expected, progress)
+	}
+}
+
+func TestSyncBatchBytes(t *testing.T) {
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{DryRun: true},
+		sync: &config.HeaderSyncConfig{Batch: 10, MaxBatchBytes: 2500, Timeout: 10,
+			ListenerConfig: &config.ListenerConfig{ChainId: base.HARMONY}},
+		state: new(memChainStore),
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	progress := [][2]uint64{}
+	s.OnProgress(func(height uint64, count int) { progress = append(progress, [2]uint64{height, uint64(count)}) })
+
+	ch := make(chan msg.Header, 6)
+	for h := uint64(10); h < 16; h++ {
+		ch <- msg.Header{Height: h, Data: make([]byte, 1000)}
+	}
+	close(ch)
+	s.startSync(ch, nil)
+	expected := [][2]uint64{{11, 2}, {13, 2}, {15, 2}}
+	if fmt.Sprint(progress) != fmt.Sprint(expected) {
+		t.Fatalf("Expect batches %v, got %v", expected, progress)
+	}
+}
+
+func TestCommitPartial(t *testing.T) {
+	headers := []msg.Header{}
+	for h := uint64(100); h < 110; h++ {
+		headers = append(headers, msg.Header{Height: h, Data: []byte{byte(h)}})
+	}
+	cases := []struct {
+		synced   uint64 // Highest header accepted by poly
+		err      error
+		progress [][2]uint64
+		reset    []uint64
+	}{
+		{99, msg.ERR_HEADER_SUBMIT_FAILURE, [][2]uint64{}, []uint64{97}},
+		{103, msg.ERR_HEADER_SUBMIT_FAILURE, [][2]uint64{{103, 4}}, []uint64{104}},
+		{109, msg.ERR_HEADER_MISSING, [][2]uint64{{109, 10}}, []uint64{}},
+		{103, msg.ERR_HEADER_INCONSISTENT, [][2]uint64{}, []uint64{97}},
+		{99, nil, [][2]uint64{{109, 10}}, []uint64{}},
+	}
+	for i, c := range cases {
+		state := new(memChainStore)
+		s := &Submitter{
+			sync:  &config.HeaderSyncConfig{ListenerConfig: &config.ListenerConfig{ChainId: base.ETH}},
+			state: state,
+		}
+		s.Context, s.cancel = context.WithCancel(context.Background())
+		progress := [][2]uint64{}
+		s.OnProgress(func(height uint64, count int) { progress = append(progress, [2]uint64{height, uint64(count)}) })
+		reset := make(chan uint64, 1)
+		exists := func(header *msg.Header) (bool, error) { return header.Height <= c.synced, nil }
+		s.onCommit(headers, 109, c.err, exists, reset)
+		s.cancel()
+		close(reset)
+		resets := []uint64{}
+		for h := range reset {
+			resets = append(resets, h)
+		}
+		if fmt.Sprint(progress) != fmt.Sprint(c.progress) || fmt.Sprint(resets) != fmt.Sprint(c.reset) {
+			t.Fatalf("Case %d expect progress %v reset %v, got %v %v", i, c.progress, c.reset, progress, resets)
+		}
+		if len(c.progress) > 0 && c.err != nil && state.height != c.synced {
+			t.Fatalf("Case %d expect sync height marked %d, got %d", i, c.synced, state.height)
+		}
+	}
+}
+
+func TestSyncCompressedHeaders(t *testing.T) {
+	s := &Submitter{
+		config: &config.PolySubmitterConfig{DryRun: true},
+		sync:   &config.HeaderSyncConfig{Batch: 2, Timeout: 10, ListenerConfig: &config.ListenerConfig{ChainId: base.HARMONY}},
+		state:  new(memChainStore),
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	progress := [][2]uint64{}
+	s.OnProgress(func(height uint64, count int) { progress = append(progress, [2]uint64{height, uint64(count)}) })
+
+	codecs := []string{msg.HEADER_CODEC_GZIP, msg.HEADER_CODEC_SNAPPY, "", msg.HEADER_CODEC_GZIP}
+	ch := make(chan msg.Header, len(codecs))
+	for i, codec := range codecs {
+		header := msg.Header{Height: uint64(10 + i), Data: []byte{byte(i)}}
+		if err := header.Compress(codec); err != nil {
+			t.Fatal(err)
+		}
+		ch <- header
+	}
+	close(ch)
+	reset := make(chan uint64, 1)
+	s.startSync(ch, reset)
+	if fmt.Sprint(progress) != "[[11 2] [13 2]]" || len(reset) != 0 {
+		t.Fatalf("Unexpected progress %v", progress)
+	}
+
+	// Corrupted header resets the sync to it
+	ch = make(chan msg.Header, 1)
+	ch <- msg.Header{Height: 14, Data: []byte{14}, Codec: msg.HEADER_CODEC_GZIP}
+	close(ch)
+	s.startSync(ch, reset)
+	if len(reset) != 1 || <-reset != 14 {
+		t.Fatal("Expect reset to the corrupted header")
+	}
+}
+
+func TestClassifyHeaderError(t *testing.T) {
+	cases := []struct {
+		err      error
+		sentinel error
+	}{
+		{errors.New("JsonRpcResponse error code:47001 desc:parent header not exist"), msg.ERR_HEADER_FORK},
+		{errors.New("VerifySpan err: span not correct"), msg.ERR_HEADER_FORK},
+		{errors.New("rlp: missing required field"), msg.ERR_HEADER_MALFORMED},
+		{errors.New("http post request error"), nil},
+	}
+	for _, c := range cases {
+		err := classifyHeaderError(c.err)
+		if c.sentinel == nil {
+			if err != c.err || headerInconsistent(err) {
+				t.Fatalf("Expect %v unclassified, got %v", c.err, err)
+			}
+			continue
+		}
+		if !errors.Is(err, c.sentinel) || !headerInconsistent(err) {
+			t.Fatalf("Expect %v classified as %v, got %v", c.err, c.sentinel, err)
+		}
+		if classifyHeaderError(err) != err {
+			t.Fatalf("Expect classified error kept as is")
+		}
+	}
+
+	// Sentinels match regardless of the message, raw errors fall back to message matching
+	if !headerInconsistent(fmt.Errorf("%w: node reports unknown parent", msg.ERR_HEADER_FORK)) {
+		t.Fatal("Expect sentinel wrapped error matched")
+	}
+	if !headerInconsistent(errors.New("parent block failed")) {
+		t.Fatal("Expect raw error matched by message")
+	}
+}
+
+func TestIsImported(t *testing.T) {
+	done := "0a0b0c"
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		if method == "getstorage" && strings.HasSuffix(params[1].(string), done) {
+			return done, nil
+		}
+		return "", nil
+	})
+	cases := []struct {
+		chain    uint64
+		txId     string
+		imported bool
+	}{
+		{2, "0x0A0B0C", true},
+		{2, "0c0b0a", false},
+		{base.ONT, "0c0b0a", true}, // Reversed tx id for ONT and NEO
+		{base.NEO, "0a0b0c", false},
+	}
+	for _, c := range cases {
+		imported, _, err := isImported(node, poly.CCM_ADDRESS, c.chain, c.txId)
+		if err != nil || imported != c.imported {
+			t.Fatalf("Expect tx %s of chain %d imported %v, got %v %v", c.txId, c.chain, c.imported, imported, err)
+		}
+	}
+	if _, _, err := isImported(node, poly.CCM_ADDRESS, 2, "xyz"); err == nil {
+		t.Fatal("Expect invalid tx id error")
+	}
+}
+
+func TestForkRetry(t *testing.T) {
+	cases := []struct {
+		limit, failures int
+		sends           int
+		err             error
+	}{
+		{0, 1, 1, msg.ERR_HEADER_INCONSISTENT},
+		{2, 2, 3, nil},
+		{2, 5, 3, msg.ERR_HEADER_INCONSISTENT},
+	}
+	for _, c := range cases {
+		var sends int64
+		var delays []time.Duration
+		s := &Submitter{
+			config: &config.PolySubmitterConfig{},
+			sync:   &config.HeaderSyncConfig{ForkRetryLimit: c.limit},
+			signer: sdk.NewAccount(),
+			after: func(d time.Duration) <-chan time.Time {
+				delays = append(delays, d)
+				ch := make(chan time.Time, 1)
+				ch <- time.Now()
+				return ch
+			},
+			sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+				switch method {
+				case "getblockcount":
+					return 100, nil
+				case "getheaderbyheight":
+					return hex.EncodeToString((&types.Header{}).ToArray()), nil
+				case "sendrawtransaction":
+					if int(atomic.AddInt64(&sends, 1)) <= c.failures {
+						return nil, errors.New("parent header not exist")
+					}
+					return fmt.Sprintf("%064x", 1), nil
+				case "getblockheightbytxhash":
+					return 100, nil
+				}
+				return nil, fmt.Errorf("unexpected method %s", method)
+			}),
+		}
+		s.Context, s.cancel = context.WithCancel(context.Background())
+		err := s.submitHeadersWithLoop(base.ETH, [][]byte{{1}}, nil)
+		s.cancel()
+		if err != c.err || int(sends) != c.sends {
+			t.Fatalf("Limit %d with %d failures expect %d sends err %v, got %d sends err %v", c.limit, c.failures, c.sends, c.err, sends, err)
+		}
+		if c.limit == 2 && fmt.Sprint(delays) != "[1s 2s]" {
+			t.Fatalf("Expect backoff doubling between fork retries, got %v", delays)
+		}
+	}
+	if d := forkBackoff(10); d != HEADER_FORK_MAX_BACKOFF {
+		t.Fatalf("Expect backoff capped at %v, got %v", HEADER_FORK_MAX_BACKOFF, d)
+	}
+}
+
+func TestSyncStopHeight(t *testing.T) {
+	for _, batch := range []int{1, 4} {
+		s := &Submitter{
+			config: &config.PolySubmitterConfig{DryRun: true},
+			sync: &config.HeaderSyncConfig{Batch: batch, Timeout: 10, StopHeight: 12,
+				ListenerConfig: &config.ListenerConfig{ChainId: base.HARMONY}},
+			state: new(memChainStore),
+		}
+		s.Context, s.cancel = context.WithCancel(context.Background())
+		var submitted, completed uint64
+		s.OnProgress(func(height uint64, count int) { submitted = height })
+		s.OnSyncComplete(func(height uint64) { completed = height })
+
+		// The channel stays open, the sync exits on its own once the stop height is submitted
+		ch := make(chan msg.Header, 10)
+		for h := uint64(10); h < 16; h++ {
+			ch <- msg.Header{Height: h, Data: []byte{byte(h)}}
+		}
+		done := make(chan struct{})
+		go func() {
+			s.startSync(ch, nil)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Batch %d expect header sync stopped", batch)
+		}
+		s.cancel()
+		// Dry run leaves the header sync mark untouched
+		if submitted != 12 || completed != 12 || s.state.(*memChainStore).height != 0 {
+			t.Fatalf("Batch %d expect stop at 12, got submitted %d completed %d", batch, submitted, completed)
+		}
+	}
+}
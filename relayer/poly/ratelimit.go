@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Token bucket limiter of node calls, a nil limiter never throttles
+type rateLimiter struct {
+	limiter *rate.Limiter
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+func newRateLimiter(limit float64, burst int) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{limiter: rate.NewLimiter(rate.Limit(limit), burst), now: time.Now, sleep: time.Sleep}
+}
+
+// Block till a call is allowed
+func (r *rateLimiter) Wait() {
+	delay := r.Reserve()
+	if delay > 0 {
+		r.sleep(delay)
+	}
+}
+
+// Reserve a call and return the delay before it is allowed
+func (r *rateLimiter) Reserve() time.Duration {
+	if r == nil {
+		return 0
+	}
+	now := r.now()
+	return r.limiter.ReserveN(now, 1).DelayFrom(now)
+}
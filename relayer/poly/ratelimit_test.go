@@ -0,0 +1,160 @@
+package poly
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := new(Listener)
+	l.Init(&config.ListenerConfig{RateLimit: 10, RateBurst: 2}, new(poly.SDK))
+	if l.limiter == nil {
+		t.Fatal("Expect rate limiter configured")
+	}
+	clock := time.Unix(1600000000, 0)
+	start := clock
+	sleeps := 0
+	l.limiter.now = func() time.Time { return clock }
+	l.limiter.sleep = func(d time.Duration) {
+		sleeps++
+		clock = clock.Add(d)
+	}
+
+	// Burst passes through, then calls are spaced by the rate
+	for i := 0; i < 12; i++ {
+		l.limiter.Wait()
+		if i == 1 && sleeps != 0 {
+			t.Fatalf("Expect burst calls not throttled")
+		}
+	}
+	if sleeps != 10 || clock.Sub(start) != time.Second {
+		t.Fatalf("Expect 10 calls throttled over 1s, got %d over %s", sleeps, clock.Sub(start))
+	}
+
+	// Idle time refills the bucket
+	clock = clock.Add(time.Second)
+	sleeps = 0
+	l.limiter.Wait()
+	l.limiter.Wait()
+	if sleeps != 0 {
+		t.Fatalf("Expect refilled burst not throttled")
+	}
+
+	l.Init(&config.ListenerConfig{}, new(poly.SDK))
+	if l.limiter != nil {
+		t.Fatal("Expect no rate limiter by default")
+	}
+	l.limiter.Wait()
+}
+
+func TestRequeueRate(t *testing.T) {
+	useTestConfig(t)
+	var (
+		mu       sync.Mutex
+		clock    = time.Unix(1600000000, 0)
+		start    = clock
+		requeues int
+	)
+	// Every submit fails as in a total poly outage
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{DryRun: true, RetryInterval: 10, MaxRetryInterval: 100},
+		composer: &testComposer{fail: "a"},
+		requeues: newRateLimiter(2, 1),
+		after: func(d time.Duration) <-chan time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			requeues++
+			clock = clock.Add(d)
+			ch := make(chan time.Time, 1)
+			ch <- clock
+			return ch
+		},
+	}
+	s.requeues.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+	mq := &memTxBus{txs: []*msg.Tx{{SrcHash: "a", SrcChainId: base.ONT}, {SrcHash: "a", SrcChainId: base.ONT}}}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	go s.run(mq)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return requeues >= 20
+	})
+	s.cancel()
+	s.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if elapsed := clock.Sub(start); float64(requeues-1) > 2*elapsed.Seconds() {
+		t.Fatalf("Requeued %d txs in %v beyond the rate limit", requeues, elapsed)
+	}
+
+	// Tx backoff doubles with attempts up to the max retry interval
+	for attempts, delay := range map[int]time.Duration{0: 10, 1: 10, 3: 40, 10: 100} {
+		if d := s.requeueBackoff(attempts); d != delay*time.Millisecond {
+			t.Fatalf("Attempts %d expect backoff %v, got %v", attempts, delay*time.Millisecond, d)
+		}
+	}
+}
+
+func TestConsumeRequeueRate(t *testing.T) {
+	useTestConfig(t)
+	var (
+		mu       sync.Mutex
+		clock    = time.Unix(1600000000, 0)
+		start    = clock
+		reserves int
+	)
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{DryRun: true, RetryInterval: 10},
+		composer: &testComposer{fail: "a"},
+		requeues: newRateLimiter(2, 1),
+		after: func(d time.Duration) <-chan time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			clock = clock.Add(d)
+			ch := make(chan time.Time, 1)
+			ch <- clock
+			return ch
+		},
+	}
+	s.requeues.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		reserves++
+		return clock
+	}
+	// Failed txs of the sorted bus consumers are throttled before pushed back
+	mq := new(memSortedTxBus)
+	for i := 0; i < 6; i++ {
+		mq.Push(context.Background(), &msg.Tx{SrcHash: "a", SrcChainId: base.ONT}, 0)
+	}
+	s.Context, s.cancel = context.WithCancel(context.Background())
+	s.wg = new(sync.WaitGroup)
+	go s.consume(mq)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return reserves >= 6
+	})
+	s.cancel()
+	s.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if elapsed := clock.Sub(start); float64(reserves-1) > 2*elapsed.Seconds() {
+		t.Fatalf("Requeued %d txs in %v beyond the rate limit", reserves, elapsed)
+	}
+}
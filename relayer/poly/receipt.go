@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	"github.com/polynetwork/bridge-common/log"
+	scom "github.com/polynetwork/poly-go-sdk/common"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Rounds of one second to wait for the poly tx confirmation
+const CONFIRM_ROUNDS = 300
+
+// Interval between poly tx checks of the time confirm strategy
+const CONFIRM_INTERVAL = time.Second
+
+// Confirmation details of a poly tx. Poly native contract calls are not charged gas, the
+// node event carries no gas usage, so none is reported here.
+type PolyReceipt struct {
+	Hash     string
+	Height   uint64
+	State    byte                    // 1 for success, 0 for failure
+	Notifies []*scom.NotifyEventInfo // Notifies emitted by the cross chain manager
+}
+
+// Submit the src tx to poly and wait for the confirmation, the receipt is nil when the tx was
+// already imported or in dry run mode. The composer should be set by Start or ProcessTx.
+func (s *Submitter) SubmitWithReceipt(tx *msg.Tx) (receipt *PolyReceipt, err error) {
+	tx.PolyHash = ""
+	err = s.submit(tx)
+	if err != nil || tx.PolyHash == "" || s.dryRun() {
+		return
+	}
+	return polyReceipt(s.sdk.Node(), s.ccm(), tx.PolyHash)
+}
+
+// Wait for the poly tx confirmation and fetch its receipt
+func polyReceipt(node *poly.Client, contract, hash string) (receipt *PolyReceipt, err error) {
+	height, err := node.Confirm(hash, 0, CONFIRM_ROUNDS)
+	if err != nil {
+		return nil, fmt.Errorf("Wait poly tx %s confirmation error %v", hash, err)
+	}
+	evt, err := node.GetSmartContractEvent(hash)
+	if err != nil {
+		return nil, fmt.Errorf("Fetch poly tx %s event error %v", hash, err)
+	}
+	if evt == nil {
+		return nil, fmt.Errorf("Poly tx %s event not found at height %d", hash, height)
+	}
+	receipt = parsePolyReceipt(hash, height, evt, contract)
+	if receipt.State == 0 {
+		err = fmt.Errorf("%w, poly tx %s at height %d", msg.ERR_TX_EXEC_FAILURE, hash, height)
+	}
+	return
+}
+
+// Wait for the poly tx confirmation by the configured strategy within timeout seconds
+func (s *Submitter) confirm(node *poly.Client, hash string, timeout int) error {
+	if s.config == nil {
+		return confirmTx(node, hash, 0, timeout)
+	}
+	if s.config.ConfirmStrategy == config.CONFIRM_BY_TIME {
+		return confirmTxFor(node, hash, time.Duration(s.config.ConfirmDuration)*time.Second, timeout)
+	}
+	return confirmTx(node, hash, s.config.ConfirmDepth, timeout)
+}
+
+// Wait for the poly tx to stay on chain for the duration within timeout seconds, the wait restarts when the tx
+// can not be found, as the block may be dropped on networks finalizing by time.
+func confirmTxFor(node *poly.Client, hash string, duration time.Duration, timeout int) (err error) {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	var since time.Time
+	for {
+		_, err = node.GetBlockHeightByTxHash(hash)
+		if err == nil {
+			if since.IsZero() {
+				since = time.Now()
+			}
+			if time.Since(since) >= duration {
+				return nil
+			}
+		} else {
+			if !since.IsZero() {
+				log.Warn("Poly tx not found while waiting for the confirmation", "hash", hash, "err", err)
+			}
+			since = time.Time{}
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(CONFIRM_INTERVAL)
+	}
+	if err == nil {
+		err = fmt.Errorf("present for %s only", time.Since(since).Truncate(time.Second))
+	}
+	return fmt.Errorf("%w, poly tx %s in %ds: %v", msg.ERR_TX_UNCONFIRMED, hash, timeout, err)
+}
+
+// Wait for the poly tx to be confirmed with depth blocks on top within timeout seconds
+func confirmTx(node *poly.Client, hash string, depth uint64, timeout int) error {
+	height, err := node.Confirm(hash, depth, timeout)
+	if err == nil && height == 0 {
+		// Confirm gives up without error when the depth is not reached in the last round
+		err = fmt.Errorf("depth %d not reached", depth)
+	}
+	if err != nil {
+		return fmt.Errorf("%w, poly tx %s in %ds: %v", msg.ERR_TX_UNCONFIRMED, hash, timeout, err)
+	}
+	return nil
+}
+
+func parsePolyReceipt(hash string, height uint64, evt *scom.SmartContactEvent, contract string) *PolyReceipt {
+	receipt := &PolyReceipt{Hash: hash, Height: height, State: evt.State}
+	for _, notify := range evt.Notify {
+		if notify.ContractAddress == contract {
+			receipt.Notifies = append(receipt.Notifies, notify)
+		}
+	}
+	return receipt
+}
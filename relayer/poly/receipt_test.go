@@ -0,0 +1,150 @@
+package poly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+const testReceiptEvent = `{
+	"TxHash": "8d3b7ff2d9c1fbdbf04a5a7ba1e3a7c0c0ed2a7f7bfbbd0de7f1c3e6a4a11a01",
+	"State": 1,
+	"Notify": [
+		{"ContractAddress": "0300000000000000000000000000000000000000", "States": ["makeProof", "2", "6", "abcd", 100, "key"]},
+		{"ContractAddress": "0100000000000000000000000000000000000000", "States": ["transfer"]}
+	]
+}`
+
+func TestPolyReceipt(t *testing.T) {
+	var state float64 = 1
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockheightbytxhash":
+			return 1200, nil
+		case "getsmartcodeevent":
+			evt := map[string]interface{}{}
+			json.Unmarshal([]byte(testReceiptEvent), &evt)
+			evt["State"] = state
+			return evt, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	hash := "8d3b7ff2d9c1fbdbf04a5a7ba1e3a7c0c0ed2a7f7bfbbd0de7f1c3e6a4a11a01"
+	receipt, err := polyReceipt(node, poly.CCM_ADDRESS, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Hash != hash || receipt.Height != 1200 || receipt.State != 1 || len(receipt.Notifies) != 1 {
+		t.Fatalf("Unexpected receipt %+v", receipt)
+	}
+	notify := receipt.Notifies[0]
+	states, _ := notify.States.([]interface{})
+	if notify.ContractAddress != poly.CCM_ADDRESS || len(states) != 6 || states[0] != "makeProof" {
+		t.Fatalf("Unexpected cross chain notify %+v", notify)
+	}
+
+	state = 0
+	receipt, err = polyReceipt(node, poly.CCM_ADDRESS, hash)
+	if !errors.Is(err, msg.ERR_TX_EXEC_FAILURE) || receipt == nil || receipt.State != 0 {
+		t.Fatalf("Expect exec failure with receipt, got %+v %v", receipt, err)
+	}
+}
+
+func TestConfirmTx(t *testing.T) {
+	dropped := false
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockheightbytxhash":
+			if dropped {
+				return nil, errors.New("unknown transaction")
+			}
+			return 100, nil
+		case "getblockcount":
+			return 103, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	if err := confirmTx(node, "hash", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := confirmTx(node, "hash", 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := confirmTx(node, "hash", 5, 1); !errors.Is(err, msg.ERR_TX_UNCONFIRMED) {
+		t.Fatalf("Expect unconfirmed tx below depth, got %v", err)
+	}
+	dropped = true
+	if err := confirmTx(node, "hash", 0, 1); !errors.Is(err, msg.ERR_TX_UNCONFIRMED) {
+		t.Fatalf("Expect unconfirmed dropped tx, got %v", err)
+	}
+}
+
+func TestConfirmStrategy(t *testing.T) {
+	var checks int
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockheightbytxhash":
+			checks++
+			if checks == 2 {
+				return nil, errors.New("unknown transaction")
+			}
+			return 100, nil
+		case "getblockcount":
+			return 103, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+
+	// Blocks strategy waits for the depth
+	s := &Submitter{config: &config.PolySubmitterConfig{ConfirmStrategy: config.CONFIRM_BY_BLOCKS, ConfirmDepth: 2}}
+	if err := s.confirm(node, "hash", 1); err != nil {
+		t.Fatal(err)
+	}
+	s.config.ConfirmDepth = 5
+	if err := s.confirm(node, "hash", 1); !errors.Is(err, msg.ERR_TX_UNCONFIRMED) {
+		t.Fatalf("Expect unconfirmed tx below depth, got %v", err)
+	}
+
+	// Time strategy waits for the tx to stay on chain, restarting when it is gone
+	checks = 0
+	s.config = &config.PolySubmitterConfig{ConfirmStrategy: config.CONFIRM_BY_TIME, ConfirmDuration: 1, ConfirmDepth: 5}
+	start := time.Now()
+	if err := s.confirm(node, "hash", 5); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); checks != 4 || elapsed < 2*time.Second {
+		t.Fatalf("Expect tx present for the duration after gone, got %d checks in %s", checks, elapsed)
+	}
+	checks = 0
+	s.config.ConfirmDuration = 3
+	if err := s.confirm(node, "hash", 1); !errors.Is(err, msg.ERR_TX_UNCONFIRMED) {
+		t.Fatalf("Expect unconfirmed tx within timeout, got %v", err)
+	}
+
+	if err := (&config.PolySubmitterConfig{ConfirmStrategy: config.CONFIRM_BY_TIME}).CheckConfirmStrategy(CONFIRM_ROUNDS); err == nil {
+		t.Fatal("Expect confirm duration required")
+	}
+	strategy := &config.PolySubmitterConfig{ConfirmStrategy: config.CONFIRM_BY_TIME, ConfirmDuration: 10, ConfirmTimeout: 10}
+	if err := strategy.CheckConfirmStrategy(CONFIRM_ROUNDS); err == nil {
+		t.Fatal("Expect confirm duration not below confirm timeout rejected")
+	}
+	strategy.ConfirmTimeout = 0
+	strategy.ConfirmDuration = CONFIRM_ROUNDS
+	if err := strategy.CheckConfirmStrategy(CONFIRM_ROUNDS); err == nil {
+		t.Fatal("Expect confirm duration not below header sync confirm timeout rejected")
+	}
+	strategy.ConfirmDuration = 10
+	if err := strategy.CheckConfirmStrategy(CONFIRM_ROUNDS); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&config.PolySubmitterConfig{ConfirmStrategy: "epochs"}).CheckConfirmStrategy(CONFIRM_ROUNDS); err == nil {
+		t.Fatal("Expect unknown strategy rejected")
+	}
+}
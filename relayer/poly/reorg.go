@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/msg"
+	pcom "github.com/polynetwork/poly/common"
+)
+
+// Number of recent scanned block hashes kept for reorg detection
+const REORG_TRACK_DEPTH = 64
+
+// Hashes of recently scanned blocks by height
+type reorgTracker struct {
+	sync.Mutex
+	hashes map[uint64]pcom.Uint256
+}
+
+// Record the block hash, returns the fork height if the parent differs from the tracked one
+func (t *reorgTracker) Observe(height uint64, hash, parent pcom.Uint256) (fork uint64, ok bool) {
+	t.Lock()
+	defer t.Unlock()
+	if t.hashes == nil {
+		t.hashes = map[uint64]pcom.Uint256{}
+	}
+	if height > 0 {
+		if prev, tracked := t.hashes[height-1]; tracked && prev != parent {
+			// Drop tracked blocks of the stale fork, so they are tracked again when rescanned
+			for h := range t.hashes {
+				if h >= height-1 {
+					delete(t.hashes, h)
+				}
+			}
+			return height - 1, false
+		}
+	}
+	t.hashes[height] = hash
+	for h := range t.hashes {
+		if h+REORG_TRACK_DEPTH < height {
+			delete(t.hashes, h)
+		}
+	}
+	return 0, true
+}
+
+func (l *Listener) checkReorg(node *poly.Client, height uint64) (err error) {
+	hdr, err := node.GetHeaderByHeight(uint32(height))
+	if err != nil {
+		return fmt.Errorf("Fetch poly header %d error %v", height, err)
+	}
+	fork, ok := l.reorg.Observe(height, hdr.Hash(), hdr.PrevBlockHash)
+	if !ok {
+		log.Warn("Poly chain reorg detected", "height", height, "fork", fork)
+		return &msg.ReorgError{Height: fork}
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package poly
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/msg"
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+)
+
+func TestCheckReorg(t *testing.T) {
+	chain := map[uint32]*types.Header{}
+	build := func(from uint32, count int, fork byte) {
+		prev := chain[from-1].Hash()
+		for i := 0; i < count; i++ {
+			hdr := &types.Header{Height: from + uint32(i), PrevBlockHash: prev, NextBookkeeper: pcom.Address{fork}}
+			chain[hdr.Height] = hdr
+			prev = hdr.Hash()
+		}
+	}
+	chain[0] = &types.Header{}
+	build(1, 5, 1)
+
+	node := testPolyNode(t, func(method string, params []interface{}) (interface{}, error) {
+		if hdr, ok := chain[uint32(params[0].(float64))]; ok && method == "getheaderbyheight" {
+			return hex.EncodeToString(hdr.ToArray()), nil
+		}
+		return nil, errors.New("unknown block")
+	})
+
+	l := new(Listener)
+	for h := uint64(1); h <= 3; h++ {
+		if err := l.checkReorg(node, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Blocks from 2 on are replaced by another fork
+	build(2, 4, 2)
+	err := l.checkReorg(node, 4)
+	reorg := new(msg.ReorgError)
+	if !errors.As(err, &reorg) || !errors.Is(err, msg.ERR_REORG) || reorg.Height != 3 {
+		t.Fatalf("Expect reorg at 3, got %v", err)
+	}
+	err = l.checkReorg(node, 3)
+	if !errors.As(err, &reorg) || reorg.Height != 2 {
+		t.Fatalf("Expect reorg at 2, got %v", err)
+	}
+	for h := uint64(2); h <= 5; h++ {
+		if err := l.checkReorg(node, h); err != nil {
+			t.Fatalf("Rescan at %d after rewind failed: %v", h, err)
+		}
+	}
+}
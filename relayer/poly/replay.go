@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Dst chain submitter used to replay poly txs
+type TxReplayer interface {
+	ProcessTx(*msg.Tx, msg.PolyComposer) error
+	SubmitTx(*msg.Tx) error
+}
+
+// Set the resolver of dst chain submitters for replaying poly txs
+func (s *Submitter) SetReplayer(resolver func(dstChainId uint64) (TxReplayer, error)) {
+	s.replayer = resolver
+}
+
+// ReplayPolyTx rebuilds the poly tx by hash and submits it to the dst chain directly, bypassing the bus.
+// Returns the tx with the composed dst parameters.
+func (s *Submitter) ReplayPolyTx(hash string) (tx *msg.Tx, err error) {
+	tx, err = (&Listener{sdk: s.sdk}).scanTx(s.sdk.Node(), hash)
+	if err != nil {
+		return nil, fmt.Errorf("Scan poly tx %s error %v", hash, err)
+	}
+	err = s.replay(tx, s.ComposeTx)
+	return
+}
+
+func (s *Submitter) replay(tx *msg.Tx, compose msg.PolyComposer) (err error) {
+	if s.replayer == nil {
+		return fmt.Errorf("No dst chain replayer available for poly tx %s", tx.PolyHash)
+	}
+	key := "replay:" + tx.IdempotencyKey()
+	if s.seenKey(tx, key) {
+		return fmt.Errorf("%w, poly tx %s already processed", msg.ERR_TX_BYPASS, tx.PolyHash)
+	}
+	sub, err := s.replayer(tx.DstChainId)
+	if err != nil {
+		return fmt.Errorf("Init dst chain %d submitter error %v", tx.DstChainId, err)
+	}
+	err = sub.ProcessTx(tx, compose)
+	if err != nil {
+		return fmt.Errorf("Process poly tx %s error %w", tx.PolyHash, err)
+	}
+	err = sub.SubmitTx(tx)
+	if err != nil {
+		return fmt.Errorf("Submit poly tx %s error %w", tx.PolyHash, err)
+	}
+	s.markKey(tx, key)
+	log.Info("Replayed poly tx", "poly_hash", tx.PolyHash, "dst_chain", tx.DstChainId, "dst_hash", tx.DstHash)
+	return
+}
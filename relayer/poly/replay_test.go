@@ -0,0 +1,60 @@
+package poly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type testReplayer struct {
+	submitted []*msg.Tx
+}
+
+func (r *testReplayer) ProcessTx(tx *msg.Tx, compose msg.PolyComposer) error {
+	return compose(tx)
+}
+
+func (r *testReplayer) SubmitTx(tx *msg.Tx) error {
+	tx.DstHash = "dst"
+	r.submitted = append(r.submitted, tx)
+	return nil
+}
+
+func TestReplayPolyTx(t *testing.T) {
+	s := &Submitter{seen: bus.NewMemorySeenSet(time.Minute)}
+	compose := func(tx *msg.Tx) error {
+		tx.DstProxy = "proxy"
+		return nil
+	}
+	tx := &msg.Tx{PolyHash: "hash", SrcChainId: 2, DstChainId: 6, TxId: "01"}
+	if err := s.replay(tx, compose); err == nil {
+		t.Fatal("Expect failure without replayer")
+	}
+
+	r := new(testReplayer)
+	var dst uint64
+	s.SetReplayer(func(chain uint64) (TxReplayer, error) {
+		dst = chain
+		return r, nil
+	})
+	if err := s.replay(tx, compose); err != nil {
+		t.Fatal(err)
+	}
+	if dst != 6 || len(r.submitted) != 1 || tx.DstProxy != "proxy" || tx.DstHash != "dst" {
+		t.Fatalf("Unexpected replay result %+v", tx)
+	}
+
+	err := s.replay(&msg.Tx{PolyHash: "hash", SrcChainId: 2, DstChainId: 6, TxId: "01"}, compose)
+	if !errors.Is(err, msg.ERR_TX_BYPASS) || len(r.submitted) != 1 {
+		t.Fatalf("Expect duplicated replay skipped, got %v", err)
+	}
+
+	fail := errors.New("compose")
+	err = s.replay(&msg.Tx{PolyHash: "other", DstChainId: 6}, func(*msg.Tx) error { return fail })
+	if !errors.Is(err, fail) {
+		t.Fatalf("Expect compose error, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/polynetwork/bridge-common/chains/poly"
+)
+
+// Max delay honored from a Retry-After hint of a throttling poly node
+const RETRY_AFTER_MAX = 5 * time.Minute
+
+// Poly sdk flattens the http errors into the message, so the hint is carried by the text
+const RATE_LIMITED = "poly node rate limited"
+
+var retryAfterPattern = regexp.MustCompile(RATE_LIMITED + `(?:, retry after (\S+))?`)
+
+// Http transport turning throttled poly rpc responses into errors carrying the Retry-After hint
+type rateLimitTransport struct {
+	base http.RoundTripper
+	now  func() time.Time
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err != nil || (res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable) {
+		return res, err
+	}
+	delay, ok := parseRetryAfter(res.Header.Get("Retry-After"), t.now())
+	if res.StatusCode == http.StatusServiceUnavailable && !ok {
+		return res, nil
+	}
+	res.Body.Close()
+	if !ok {
+		return nil, fmt.Errorf("%s, status %d", RATE_LIMITED, res.StatusCode)
+	}
+	return nil, fmt.Errorf("%s, retry after %s", RATE_LIMITED, delay)
+}
+
+// Parse the Retry-After header in delay seconds or http date
+func parseRetryAfter(value string, now time.Time) (delay time.Duration, ok bool) {
+	if value == "" {
+		return
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	} else {
+		return
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > RETRY_AFTER_MAX {
+		delay = RETRY_AFTER_MAX
+	}
+	return delay, true
+}
+
+// Check if the error is a throttled poly rpc response, delay is the suggested wait or zero without a hint
+func retryAfter(err error) (delay time.Duration, limited bool) {
+	if err == nil {
+		return
+	}
+	match := retryAfterPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return
+	}
+	if match[1] != "" {
+		delay, _ = time.ParseDuration(match[1])
+	}
+	return delay, true
+}
+
+// Install the rate limit detection on the rpc clients of the poly nodes, keeping the sdk client defaults
+func detectRateLimits(sdk *poly.SDK) {
+	if sdk == nil {
+		return
+	}
+	for _, node := range sdk.AllNodes() {
+		if node == nil || node.GetRpcClient() == nil {
+			continue
+		}
+		node.GetRpcClient().SetHttpClient(&http.Client{
+			Transport: &rateLimitTransport{
+				base: &http.Transport{
+					MaxIdleConnsPerHost:   5,
+					IdleConnTimeout:       300 * time.Second,
+					ResponseHeaderTimeout: 300 * time.Second,
+				},
+				now: time.Now,
+			},
+			Timeout: 300 * time.Second,
+		})
+	}
+}
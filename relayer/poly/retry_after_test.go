@@ -0,0 +1,102 @@
+package poly
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/poly"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRetryAfter(t *testing.T) {
+	var throttle int32
+	node, _ := url.Parse(testPolyServer(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return hex.EncodeToString((&types.Header{}).ToArray()), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}))
+	proxy := httputil.NewSingleHostReverseProxy(node)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.LoadInt32(&throttle) {
+		case 1:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			proxy.ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+	sdk, err := poly.NewSDK(base.POLY, []string{server.URL}, time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detectRateLimits(sdk)
+
+	// Throttled responses carry the hint through the sdk errors
+	atomic.StoreInt32(&throttle, 1)
+	_, err = sdk.Node().GetCurrentBlockHeight()
+	if delay, limited := retryAfter(err); !limited || delay != 2*time.Second {
+		t.Fatalf("Expect rate limited with 2s hint, got %v %v err %v", delay, limited, err)
+	}
+	atomic.StoreInt32(&throttle, 2)
+	_, err = sdk.Node().GetCurrentBlockHeight()
+	if delay, limited := retryAfter(err); !limited || delay != 0 {
+		t.Fatalf("Expect rate limited without hint, got %v %v err %v", delay, limited, err)
+	}
+
+	// Requeue waits the hint without a requeue rate limit, and nothing for other errors
+	var waits []time.Duration
+	s := &Submitter{after: func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}}
+	s.Context = context.Background()
+	s.requeueWait(new(msg.Tx), err)
+	s.requeueWait(new(msg.Tx), nil)
+	atomic.StoreInt32(&throttle, 1)
+	_, err = sdk.Node().GetCurrentBlockHeight()
+	s.requeueWait(new(msg.Tx), err)
+	if len(waits) != 1 || waits[0] != 2*time.Second {
+		t.Fatalf("Expect single wait of the hint, got %v", waits)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"abc", 0, false},
+		{"30", 30 * time.Second, true},
+		{"3600", RETRY_AFTER_MAX, true},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, c := range cases {
+		if delay, ok := parseRetryAfter(c.value, now); delay != c.delay || ok != c.ok {
+			t.Fatalf("Retry-After %q expect %v %v, got %v %v", c.value, c.delay, c.ok, delay, ok)
+		}
+	}
+}
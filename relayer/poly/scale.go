@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"context"
+	"time"
+
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/bus"
+)
+
+// Workers each running with a child context, so single workers can be stopped
+type workerPool struct {
+	ctx     context.Context
+	spawn   func(context.Context)
+	cancels []context.CancelFunc
+}
+
+func newWorkerPool(ctx context.Context, spawn func(context.Context)) *workerPool {
+	return &workerPool{ctx: ctx, spawn: spawn}
+}
+
+func (p *workerPool) Size() int {
+	return len(p.cancels)
+}
+
+func (p *workerPool) Grow() {
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancels = append(p.cancels, cancel)
+	go p.spawn(ctx)
+}
+
+// Stop the last worker, which exits after the tx in process
+func (p *workerPool) Shrink() {
+	if len(p.cancels) == 0 {
+		return
+	}
+	last := len(p.cancels) - 1
+	p.cancels[last]()
+	p.cancels = p.cancels[:last]
+}
+
+// Worker count for the bus depth, moving one worker at a time within [min, max]
+func scaleTarget(current, min, max int, depth, high, low uint64) int {
+	switch {
+	case current < min:
+		return min
+	case current > max:
+		return max
+	case depth > high && current < max:
+		return current + 1
+	case depth < low && current > min:
+		return current - 1
+	}
+	return current
+}
+
+func (s *Submitter) scaleWorkers(pool *workerPool, depth uint64) {
+	target := scaleTarget(pool.Size(), s.config.Procs, s.config.MaxProcs, depth, s.config.ScaleUpDepth, s.config.ScaleDownDepth)
+	if target == pool.Size() {
+		return
+	}
+	log.Info("Scaling poly submitter workers", "chain", s.name, "depth", depth, "from", pool.Size(), "to", target)
+	for pool.Size() < target {
+		pool.Grow()
+	}
+	for pool.Size() > target {
+		pool.Shrink()
+	}
+	record(pool.Size(), "%s.poly_submitter_workers", s.name)
+}
+
+// Run workers consuming the tx bus, scaled between Procs and MaxProcs by the bus depth
+func (s *Submitter) autoscale(mq bus.SortedTxBus) {
+	interval := 10 * time.Second
+	if s.config.ScaleInterval > 0 {
+		interval = time.Duration(s.config.ScaleInterval) * time.Second
+	}
+	pool := newWorkerPool(s.Context, func(ctx context.Context) { s.consumeWith(ctx, mq) })
+	s.scaleWorkers(pool, 0)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.Done():
+			return
+		case <-ticker.C:
+			depth, err := mq.Len(s.Context)
+			if err != nil {
+				log.Error("Failed to get tx bus depth for scaling", "chain", s.name, "err", err)
+				continue
+			}
+			s.scaleWorkers(pool, depth)
+		}
+	}
+}
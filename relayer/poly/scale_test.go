@@ -0,0 +1,51 @@
+package poly
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+func TestScaleWorkers(t *testing.T) {
+	s := &Submitter{name: "test", config: &config.PolySubmitterConfig{Procs: 1, MaxProcs: 3, ScaleUpDepth: 10, ScaleDownDepth: 2}}
+	ctx, cancel := context.WithCancel(context.Background())
+	var live int64
+	pool := newWorkerPool(ctx, func(ctx context.Context) {
+		atomic.AddInt64(&live, 1)
+		<-ctx.Done()
+		atomic.AddInt64(&live, -1)
+	})
+	expect := func(size int) {
+		t.Helper()
+		if pool.Size() != size {
+			t.Fatalf("Expect %d workers, got %d", size, pool.Size())
+		}
+		waitFor(t, func() bool { return atomic.LoadInt64(&live) == int64(size) })
+	}
+
+	// Backlog grows workers one at a time up to the max
+	for i, depth := range []uint64{0, 100, 100, 100, 100} {
+		s.scaleWorkers(pool, depth)
+		size := i + 1
+		if size > 3 {
+			size = 3
+		}
+		expect(size)
+	}
+	// Depth between the marks keeps the workers
+	s.scaleWorkers(pool, 5)
+	expect(3)
+	// Drained bus shrinks workers down to the min
+	for _, size := range []int{2, 1, 1} {
+		s.scaleWorkers(pool, 0)
+		expect(size)
+	}
+
+	// Workers exit with the submitter context
+	s.scaleWorkers(pool, 100)
+	expect(2)
+	cancel()
+	waitFor(t, func() bool { return atomic.LoadInt64(&live) == 0 })
+}
@@ -0,0 +1,97 @@
+package poly
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/wallet"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/core/types"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestSetSigner(t *testing.T) {
+	useTestConfig(t)
+	type sent struct {
+		address string
+		nonce   uint32
+	}
+	var (
+		mu      sync.Mutex
+		txs     []sent
+		release = make(chan struct{})
+		block   = make(chan struct{}, 1)
+	)
+	s := &Submitter{
+		config:   &config.PolySubmitterConfig{},
+		composer: &testComposer{},
+		signer:   sdk.NewAccount(),
+		sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+			switch method {
+			case "getblockcount":
+				return 100, nil
+			case "getheaderbyheight":
+				return hex.EncodeToString((&types.Header{}).ToArray()), nil
+			case "sendrawtransaction":
+				raw, _ := hex.DecodeString(params[0].(string))
+				tx, err := types.TransactionFromRawBytes(raw)
+				if err != nil {
+					return nil, err
+				}
+				addr := types.AddressFromPubKey(tx.Sigs[0].PubKeys[0])
+				mu.Lock()
+				txs = append(txs, sent{addr.ToBase58(), tx.Nonce})
+				mu.Unlock()
+				select {
+				case block <- struct{}{}:
+					<-release // Hold the first tx in flight
+				default:
+				}
+				hash := tx.Hash()
+				return hash.ToHexString(), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}),
+	}
+	old, next := s.signer, sdk.NewAccount()
+
+	done := make(chan error)
+	go func() { done <- s.submit(&msg.Tx{SrcChainId: base.ONT, SrcHash: "before"}) }()
+	for len(block) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	rotated := make(chan struct{})
+	go func() {
+		s.setSigner(next)
+		close(rotated)
+	}()
+	select {
+	case <-rotated:
+		t.Fatal("Signer rotated with a tx in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	<-rotated
+	if err := s.submit(&msg.Tx{SrcChainId: base.ONT, SrcHash: "after"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txs) != 2 || txs[0].address != old.Address.ToBase58() || txs[1].address != next.Address.ToBase58() {
+		t.Fatalf("Unexpected signers of sent txs %+v", txs)
+	}
+	if txs[0].nonce == txs[1].nonce {
+		t.Fatalf("Nonce reused across the rotation %+v", txs)
+	}
+	if err := s.SetSigner(&wallet.Config{Path: "missing.dat"}); err == nil || s.account() != next {
+		t.Fatal("Expect the signer kept on wallet failure")
+	}
+}
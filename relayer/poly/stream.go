@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly/common/serialization"
+)
+
+// Max size of a single header read from a header stream
+const MAX_STREAM_HEADER_SIZE = 4 << 20
+
+// Handle header stream progress with the count of headers submitted so far
+func (s *Submitter) OnStreamProgress(handler func(submitted int)) {
+	s.onStream = handler
+}
+
+// Submit headers read from the reader to poly in batches, so bulk catch-up does not hold all
+// headers in memory. Each header in the stream is prefixed with its var uint length.
+func (s *Submitter) SubmitHeadersFrom(chainId uint64, r io.Reader, batch int) error {
+	return s.submitHeadersFrom(r, batch, func(headers [][]byte) error {
+		_, err := s.SubmitHeaders(chainId, headers)
+		return err
+	})
+}
+
+func (s *Submitter) submitHeadersFrom(r io.Reader, batch int, submit func([][]byte) error) error {
+	if batch < 1 {
+		batch = 1
+	}
+	reader := bufio.NewReader(r)
+	submitted := 0
+	headers := make([][]byte, 0, batch)
+	flush := func() error {
+		if len(headers) == 0 {
+			return nil
+		}
+		err := submit(headers)
+		if err != nil {
+			return fmt.Errorf("Submit streamed headers %d-%d error %w", submitted, submitted+len(headers)-1, err)
+		}
+		submitted += len(headers)
+		headers = make([][]byte, 0, batch)
+		log.Info("Submitted streamed headers to poly", "chain", s.name, "submitted", submitted)
+		if s.onStream != nil {
+			s.onStream(submitted)
+		}
+		return nil
+	}
+	for {
+		select {
+		case <-s.ctx().Done():
+			return s.ctx().Err()
+		default:
+		}
+		header, err := readStreamHeader(reader)
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return fmt.Errorf("Read streamed header %d error %v", submitted+len(headers), err)
+		}
+		headers = append(headers, header)
+		if len(headers) >= batch {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Read a length prefixed header, returns io.EOF only at the end of the stream
+func readStreamHeader(r io.Reader) (header []byte, err error) {
+	size, err := serialization.ReadVarUint(r, MAX_STREAM_HEADER_SIZE)
+	if err != nil {
+		return
+	}
+	header = make([]byte, size)
+	_, err = io.ReadFull(r, header)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
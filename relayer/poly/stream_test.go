@@ -0,0 +1,58 @@
+package poly
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/polynetwork/poly/common/serialization"
+)
+
+func TestSubmitHeadersFrom(t *testing.T) {
+	stream := new(bytes.Buffer)
+	for i := 0; i < 7; i++ {
+		serialization.WriteVarBytes(stream, bytes.Repeat([]byte{byte(i)}, i+1))
+	}
+	data := stream.Bytes()
+
+	s := new(Submitter)
+	var (
+		batches  [][][]byte
+		progress []int
+	)
+	s.OnStreamProgress(func(submitted int) { progress = append(progress, submitted) })
+	err := s.submitHeadersFrom(bytes.NewReader(data), 3, func(headers [][]byte) error {
+		batches = append(batches, headers)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || len(batches[0]) != 3 || len(batches[2]) != 1 {
+		t.Fatalf("Unexpected header batches %v", batches)
+	}
+	for i, header := range append(append(batches[0], batches[1]...), batches[2]...) {
+		if !bytes.Equal(header, bytes.Repeat([]byte{byte(i)}, i+1)) {
+			t.Fatalf("Unexpected header %d %x", i, header)
+		}
+	}
+	if len(progress) != 3 || progress[0] != 3 || progress[2] != 7 {
+		t.Fatalf("Unexpected progress %v", progress)
+	}
+
+	// Submit failures stop the stream
+	calls := 0
+	err = s.submitHeadersFrom(bytes.NewReader(data), 3, func([][]byte) error {
+		calls++
+		return errors.New("node down")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("Expect stream stopped on submit failure, got %v after %d calls", err, calls)
+	}
+
+	// Truncated stream
+	err = s.submitHeadersFrom(bytes.NewReader(data[:len(data)-1]), 3, func([][]byte) error { return nil })
+	if err == nil {
+		t.Fatal("Expect error on truncated header")
+	}
+}
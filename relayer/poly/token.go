@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Time to remember request tokens when no shared seen set is used
+const REQUEST_TOKEN_TTL = time.Hour
+
+// SubmitExternalProof guarded by the request token against replayed calls, see claimToken
+func (s *Submitter) SubmitExternalProofWithToken(token string, srcChainId uint64, value, proof []byte, proofHeight uint32) (polyHash string, err error) {
+	if err = s.claimToken(token); err != nil {
+		return
+	}
+	return s.SubmitExternalProof(srcChainId, value, proof, proofHeight)
+}
+
+// ReplayPolyTx guarded by the request token against replayed calls, see claimToken
+func (s *Submitter) ReplayPolyTxWithToken(token, hash string) (tx *msg.Tx, err error) {
+	if err = s.claimToken(token); err != nil {
+		return
+	}
+	return s.ReplayPolyTx(hash)
+}
+
+// Claim the request token against the shared seen set, or a local one if not set, failing with
+// msg.ERR_REQUEST_REPLAYED when claimed already. The token is claimed before the call runs, so a failed
+// call is retried with a new token. The check is skipped for empty tokens.
+func (s *Submitter) claimToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	s.tokenLock.Lock()
+	defer s.tokenLock.Unlock()
+	store := s.seen
+	if store == nil {
+		if s.tokens == nil {
+			s.tokens = bus.NewMemorySeenSet(REQUEST_TOKEN_TTL)
+		}
+		store = s.tokens
+	}
+	key := "token:" + token
+	seen, err := store.Seen(s.ctx(), key)
+	if err != nil {
+		return fmt.Errorf("Check request token %s error %v", token, err)
+	}
+	if seen {
+		return fmt.Errorf("%w, token %s", msg.ERR_REQUEST_REPLAYED, token)
+	}
+	err = store.Mark(s.ctx(), key)
+	if err != nil {
+		return fmt.Errorf("Claim request token %s error %v", token, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package poly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRequestToken(t *testing.T) {
+	s := new(Submitter)
+	if err := s.claimToken("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.claimToken("a"); !errors.Is(err, msg.ERR_REQUEST_REPLAYED) {
+		t.Fatalf("Expect replayed token rejected, got %v", err)
+	}
+	if s.claimToken("") != nil || s.claimToken("") != nil || s.claimToken("b") != nil {
+		t.Fatal("Expect empty and new tokens accepted")
+	}
+
+	// The failed call keeps the token claimed, the replay is rejected before the call
+	_, err := s.SubmitExternalProofWithToken("c", base.ETH, []byte{1}, []byte{2}, 100)
+	if !errors.Is(err, msg.ERR_INVALID_TX) {
+		t.Fatalf("Expect malformed proof rejected, got %v", err)
+	}
+	_, err = s.SubmitExternalProofWithToken("c", base.ETH, []byte{1}, []byte{2}, 100)
+	if !errors.Is(err, msg.ERR_REQUEST_REPLAYED) {
+		t.Fatalf("Expect replayed proof submit rejected, got %v", err)
+	}
+	if _, err = s.ReplayPolyTxWithToken("a", "hash"); !errors.Is(err, msg.ERR_REQUEST_REPLAYED) {
+		t.Fatalf("Expect replayed poly tx replay rejected, got %v", err)
+	}
+
+	// Tokens are shared across submitters with the shared seen set
+	seen := bus.NewMemorySeenSet(time.Minute)
+	a, b := &Submitter{seen: seen}, &Submitter{seen: seen}
+	if err = a.claimToken("d"); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.claimToken("d"); !errors.Is(err, msg.ERR_REQUEST_REPLAYED) {
+		t.Fatalf("Expect token claimed on another submitter rejected, got %v", err)
+	}
+}
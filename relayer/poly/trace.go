@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package poly
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+const TRACER_NAME = "github.com/polynetwork/poly-relayer/relayer/poly"
+
+// Trace submits and compose steps with spans from the provider, tracing is a no-op if not set
+func (s *Submitter) SetTracerProvider(provider trace.TracerProvider) {
+	s.tracer = provider.Tracer(TRACER_NAME)
+}
+
+func (s *Submitter) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if s.tracer == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return s.tracer.Start(ctx, name)
+}
+
+// Start the root span of the tx, continuing the trace carried on the tx if any
+func (s *Submitter) startTxSpan(tx *msg.Tx, name string) (context.Context, trace.Span) {
+	ctx := context.Background()
+	if tx.TraceParent != "" {
+		carrier := propagation.HeaderCarrier{}
+		carrier.Set("traceparent", tx.TraceParent)
+		ctx = propagation.TraceContext{}.Extract(ctx, carrier)
+	}
+	ctx, span := s.startSpan(ctx, name)
+	span.SetAttributes(
+		attribute.Int64("src_chain", int64(tx.SrcChainId)),
+		attribute.Int64("dst_chain", int64(tx.DstChainId)),
+		attribute.String("src_hash", tx.SrcHash),
+		attribute.String("poly_hash", tx.PolyHash),
+		attribute.Int("attempts", tx.Attempts),
+	)
+	return ctx, span
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,72 @@
+package poly
+
+import (
+	"fmt"
+	"testing"
+
+	ccom "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestComposeTxSpans(t *testing.T) {
+	useTestConfig(t)
+	value := &ccom.ToMerkleValue{MakeTxParam: &ccom.MakeTxParam{Method: "unlock"}}
+	s := &Submitter{sdk: testPolySDK(t, func(method string, params []interface{}) (interface{}, error) {
+		switch method {
+		case "getblockcount":
+			return 1000, nil
+		case "getheaderbyheight":
+			return testSignedHeader(t, uint32(params[0].(float64))), nil
+		case "getcrossstatesproof", "getmerkleproof":
+			return map[string]string{"Type": "MerkleProof", "AuditPath": testAuditPath(value)}, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})}
+	exporter := tracetest.NewInMemoryExporter()
+	s.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	cases := []struct {
+		tx    *msg.Tx
+		spans []string
+	}{
+		{
+			&msg.Tx{PolyHash: "a", PolyKey: "key", PolyHeight: 100, DstChainId: 2, DstPolyEpochStartHeight: 200,
+				TraceParent: "00-" + traceId + "-00f067aa0ba902b7-01"},
+			[]string{"GetHeader", "GetHeader", "GetMerkleProof", "ComposeTx"},
+		},
+		{
+			// Headers not switching the keepers skip the epoch check
+			&msg.Tx{PolyHash: "b", PolyKey: "key", PolyHeight: 100, DstChainId: 2},
+			[]string{"GetHeader", "ComposeTx"},
+		},
+	}
+	for i, c := range cases {
+		exporter.Reset()
+		if err := s.ComposeTx(c.tx); err != nil {
+			t.Fatal(err)
+		}
+		spans := exporter.GetSpans()
+		if len(spans) != len(c.spans) {
+			t.Fatalf("Case %d expect spans %v, got %d", i, c.spans, len(spans))
+		}
+		root := spans[len(spans)-1]
+		for j, span := range spans {
+			if span.Name != c.spans[j] {
+				t.Fatalf("Case %d expect span %s at %d, got %s", i, c.spans[j], j, span.Name)
+			}
+			if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+				t.Fatalf("Case %d span %s not in the tx trace", i, span.Name)
+			}
+			if j < len(spans)-1 && span.Parent.SpanID() != root.SpanContext.SpanID() {
+				t.Fatalf("Case %d span %s not a child of the compose span", i, span.Name)
+			}
+		}
+		if c.tx.TraceParent != "" && root.SpanContext.TraceID().String() != traceId {
+			t.Fatalf("Case %d expect trace carried on tx, got %s", i, root.SpanContext.TraceID())
+		}
+	}
+}
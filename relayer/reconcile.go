@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2022 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Check whether the src tx was imported to poly
+type ImportChecker interface {
+	IsImported(srcChainId uint64, txId string) (bool, string, error)
+}
+
+// Progress of reconciling src txs in a block range
+type ReconcileProgress struct {
+	From     uint64
+	To       uint64
+	Height   uint64 // Last block reconciled
+	Scanned  int    // Src txs scanned
+	Imported int    // Src txs already imported to poly
+	Missing  int    // Src txs pushed back to the tx bus
+}
+
+// Scan the src chain blocks from `from` to `to` and push the src txs not imported to poly back to the tx bus,
+// progress is reported after each block, and reconciling can resume after the last height reported on failure.
+func Reconcile(ctx context.Context, listener IChainListener, checker ImportChecker, mq bus.SortedTxBus,
+	from, to uint64, onProgress func(ReconcileProgress)) (progress ReconcileProgress, err error) {
+	progress = ReconcileProgress{From: from, To: to}
+	for height := from; height <= to; height++ {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		txs, err := listener.Scan(height)
+		if err != nil {
+			return progress, fmt.Errorf("Failed to scan src txs at height %d, %v", height, err)
+		}
+		for _, tx := range txs {
+			progress.Scanned++
+			if tx.TxId == "" {
+				log.Warn("Skipping src tx without cross chain id", "chain", tx.SrcChainId, "hash", tx.SrcHash)
+				continue
+			}
+			imported, _, err := checker.IsImported(tx.SrcChainId, tx.TxId)
+			if err != nil {
+				return progress, fmt.Errorf("Failed to check src tx %s import, %v", tx.SrcHash, err)
+			}
+			if imported {
+				progress.Imported++
+				continue
+			}
+			proofHeight := tx.SrcProofHeight
+			if tx.SrcChainId == base.NEO {
+				proofHeight = tx.SrcHeight
+			}
+			err = mq.Push(ctx, tx, proofHeight)
+			if err != nil {
+				return progress, fmt.Errorf("Failed to push back src tx %s, %v", tx.SrcHash, err)
+			}
+			progress.Missing++
+			log.Info("Pushed back src tx missing from poly", "chain", tx.SrcChainId, "hash", tx.SrcHash, "height", height)
+		}
+		progress.Height = height
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	return
+}
+
+func ReconcileTxs(ctx *cli.Context) (err error) {
+	chain := ctx.Uint64("chain")
+	from := ctx.Uint64("from")
+	to := ctx.Uint64("to")
+	if from == 0 || to < from {
+		return fmt.Errorf("Invalid reconcile range from %d to %d", from, to)
+	}
+	conf := config.CONFIG.Chains[chain]
+	if conf == nil || conf.SrcTxSync == nil || conf.SrcTxSync.Bus == nil {
+		return fmt.Errorf("No src tx sync config available for chain %d", chain)
+	}
+	sub, err := PolySubmitter()
+	if err != nil {
+		return
+	}
+	lis, err := ChainListener(chain, sub.Poly())
+	if err != nil {
+		return
+	}
+	mq := bus.NewRedisSortedTxBus(bus.New(conf.SrcTxSync.Bus.Redis), chain, msg.SRC)
+	progress, err := Reconcile(context.Background(), lis, sub, mq, from, to, func(p ReconcileProgress) {
+		log.Info("Reconciling src txs", "chain", chain, "height", p.Height, "to", p.To, "scanned", p.Scanned, "missing", p.Missing)
+	})
+	log.Info("Reconciled src txs", "chain", chain, "from", from, "to", progress.Height, "scanned", progress.Scanned,
+		"imported", progress.Imported, "missing", progress.Missing, "err", err)
+	return
+}
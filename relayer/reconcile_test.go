@@ -0,0 +1,71 @@
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type memSrcListener struct {
+	IChainListener
+	blocks map[uint64][]*msg.Tx
+}
+
+func (l *memSrcListener) Scan(height uint64) ([]*msg.Tx, error) {
+	txs, ok := l.blocks[height]
+	if !ok {
+		return nil, fmt.Errorf("block %d not available", height)
+	}
+	return txs, nil
+}
+
+type memImportChecker map[string]bool
+
+func (c memImportChecker) IsImported(srcChainId uint64, txId string) (bool, string, error) {
+	return c[txId], "", nil
+}
+
+type memSortedTxBus struct {
+	bus.SortedTxBus
+	txs []*msg.Tx
+}
+
+func (b *memSortedTxBus) Push(_ context.Context, tx *msg.Tx, _ uint64) error {
+	b.txs = append(b.txs, tx)
+	return nil
+}
+
+func TestReconcile(t *testing.T) {
+	lis := &memSrcListener{blocks: map[uint64][]*msg.Tx{
+		10: {{SrcChainId: 2, TxId: "01", SrcHash: "a"}, {SrcChainId: 2, TxId: "02", SrcHash: "b"}},
+		11: {},
+		12: {{SrcChainId: 2, TxId: "03", SrcHash: "c"}},
+	}}
+	checker := memImportChecker{"01": true, "03": true}
+	mq := new(memSortedTxBus)
+	heights := []uint64{}
+	progress, err := Reconcile(context.Background(), lis, checker, mq, 10, 12, func(p ReconcileProgress) {
+		heights = append(heights, p.Height)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Scanned != 3 || progress.Imported != 2 || progress.Missing != 1 || progress.Height != 12 {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	if len(mq.txs) != 1 || mq.txs[0].SrcHash != "b" {
+		t.Fatalf("Expect only the missing tx pushed back, got %v", mq.txs)
+	}
+	if fmt.Sprint(heights) != "[10 11 12]" {
+		t.Fatalf("Unexpected progress heights %v", heights)
+	}
+
+	// Stops at the failed block with progress of the blocks before
+	progress, err = Reconcile(context.Background(), lis, checker, new(memSortedTxBus), 11, 13, nil)
+	if err == nil || progress.Height != 12 {
+		t.Fatalf("Expect failure after height 12, got %+v err %v", progress, err)
+	}
+}
@@ -120,6 +120,7 @@ func GetSubmitter(chain uint64) (submitter IChainSubmitter) {
 func PolySubmitter() (sub *po.Submitter, err error) {
 	sub = new(po.Submitter)
 	err = sub.Init(&config.CONFIG.Poly.PolySubmitterConfig)
+	sub.SetReplayer(func(chain uint64) (po.TxReplayer, error) { return ChainSubmitter(chain) })
 	return
 }
 
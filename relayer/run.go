@@ -19,6 +19,8 @@ package relayer
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"reflect"
 	"sync"
 	"time"
@@ -26,6 +28,7 @@ import (
 	"github.com/polynetwork/bridge-common/base"
 	"github.com/polynetwork/bridge-common/log"
 	"github.com/polynetwork/poly-relayer/config"
+	po "github.com/polynetwork/poly-relayer/relayer/poly"
 )
 
 type Server struct {
@@ -41,6 +44,15 @@ func Start(ctx context.Context, wg *sync.WaitGroup, config *config.Config) error
 }
 
 func (s *Server) Start() (err error) {
+	// Metrics are set up before any role records
+	po.InitMetrics()
+	if s.config.MetricsPort > 0 {
+		go func() {
+			err := http.ListenAndServe(fmt.Sprintf("%v:%v", s.config.Host, s.config.MetricsPort), nil)
+			log.Error("Metrics server stopped", "err", err)
+		}()
+	}
+
 	// Create poly tx sync handler
 	if s.config.Active(base.POLY) && s.config.Poly != nil {
 		s.parseHandlers(base.POLY, s.config.Poly.PolyTxSync)
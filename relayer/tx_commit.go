@@ -77,6 +77,9 @@ func (h *PolyTxCommitHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err
 	if err != nil {
 		return
 	}
+	if k, ok := h.submitter.(interface{ GetPolyKeepers() ([]byte, error) }); ok {
+		h.composer.SetKeepersResolver(func(uint64) ([]byte, error) { return k.GetPolyKeepers() })
+	}
 
 	h.bus = bus.NewRedisTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId, msg.POLY)
 	h.queue = bus.NewRedisDelayedTxBus(bus.New(h.config.Bus.Redis))
@@ -300,6 +303,12 @@ func (h *SrcTxCommitHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err
 	}
 
 	h.bus = bus.NewRedisSortedTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId, msg.SRC)
+	if h.config.RetryBus != nil {
+		h.submitter.SetRetryBus(bus.NewRedisRetryTxBus(bus.New(h.config.RetryBus.Redis), h.config.ChainId, msg.SRC))
+	}
+	if h.config.Poly.DedupTTL > 0 {
+		h.submitter.SetSeenSet(bus.NewRedisSeenSet(bus.New(h.config.Bus.Redis), time.Duration(h.config.Poly.DedupTTL)*time.Second))
+	}
 	err = h.listener.Init(h.config.ListenerConfig, h.submitter.Poly())
 	return
 }
@@ -19,6 +19,7 @@ package relayer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -293,10 +294,20 @@ func (h *PolyTxSyncHandler) start() (err error) {
 					return h.bus.PushToChain(context.Background(), tx)
 				})
 			}
-			h.state.HeightMark(h.height)
+			mark := h.height
+			if c, ok := h.listener.(interface{ Checkpoint(uint64) uint64 }); ok {
+				mark = c.Checkpoint(h.height)
+			}
+			h.state.HeightMark(mark)
 			continue
 		} else {
-			log.Error("Fetch block header error", "chain", h.config.ChainId, "height", h.height, "err", err)
+			var reorg *msg.ReorgError
+			if errors.As(err, &reorg) && reorg.Height > 0 {
+				log.Warn("Rewinding poly tx sync for chain reorg", "chain", h.config.ChainId, "height", h.height, "fork", reorg.Height)
+				h.height = reorg.Height
+			} else {
+				log.Error("Fetch block header error", "chain", h.config.ChainId, "height", h.height, "err", err)
+			}
 		}
 		h.height--
 	}
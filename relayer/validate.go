@@ -100,7 +100,7 @@ func (v *Validator) start() (err error) {
 						print = log.Error
 					}
 					print("Validating tx", "chain", chainID, "origin", tx.SrcChainId, "hash", hash, "err", err)
-					if err == nil || errors.Is(err, msg.ERR_TX_VOILATION) { break }
+					if err == nil || errors.Is(err, msg.ERR_TX_VOILATION) || errors.Is(err, msg.ERR_MISSING_DST_PROXY) { break }
 					time.Sleep(time.Second * 5)
 				}
 				if err != nil {